@@ -0,0 +1,503 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/yaml.v2"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	DEVELOPMENT_ENV = "development"
+	PRODUCTION_ENV = "production"
+	DEFAULT_JWT_SIGNING_KEY = "sectet_keys_to_hash_jwt_token"
+	DEFAULT_LOG_FORMAT = "${method}-${status} at > ${uri} < in ${response_time} - ${response_size} bytes\n"
+	REDACTED_VALUE = "REDACTED"
+	DEFAULT_JWT_AUDIENCE = "golang-rest-api-sample"
+	JWT_KEY_ACTIVE_STATUS = "active"
+	JWT_KEY_VERIFY_STATUS = "verify"
+	JWT_KEY_RETIRED_STATUS = "retired"
+	MAIL_SMTP_DRIVER = "smtp"
+	MAIL_FILE_DRIVER = "file"
+	MAIL_LOG_DRIVER = "log"
+	STORAGE_LOCAL_DRIVER = "local"
+	STORAGE_S3_DRIVER = "s3"
+)
+
+//the whole application configuration, loaded from file then overridden by environment variables
+type Config struct {
+	Environment   string        `yaml:"environment" toml:"environment" env:"APP_ENV"`
+	ListenAddress string        `yaml:"listen_address" toml:"listen_address" env:"APP_LISTEN_ADDRESS"`
+	Debug         bool          `yaml:"debug" toml:"debug" env:"APP_DEBUG"`
+	Recover       bool          `yaml:"recover" toml:"recover" env:"APP_RECOVER"`
+	GzipLevel     int           `yaml:"gzip_level" toml:"gzip_level" env:"APP_GZIP_LEVEL"`
+	Mongo         MongoConfig   `yaml:"mongo" toml:"mongo"`
+	JWT           JWTConfig     `yaml:"jwt" toml:"jwt"`
+	CORS          CORSConfig    `yaml:"cors" toml:"cors"`
+	Log           LogConfig     `yaml:"log" toml:"log"`
+	Mail          MailConfig    `yaml:"mail" toml:"mail"`
+	Account       AccountConfig `yaml:"account" toml:"account"`
+	Media         MediaConfig   `yaml:"media" toml:"media"`
+	Article       ArticleConfig `yaml:"article" toml:"article"`
+}
+
+type MongoConfig struct {
+	Addrs      []string `yaml:"addrs" toml:"addrs" env:"APP_MONGO_ADDRS"`
+	Timeout    Duration `yaml:"timeout" toml:"timeout" env:"APP_MONGO_TIMEOUT"`
+	Database   string   `yaml:"database" toml:"database" env:"APP_MONGO_DATABASE"`
+	Username   string   `yaml:"username" toml:"username" env:"APP_MONGO_USERNAME"`
+	Password   string   `yaml:"password" toml:"password" env:"APP_MONGO_PASSWORD" secret:"true"`
+	AuthSource string   `yaml:"auth_source" toml:"auth_source" env:"APP_MONGO_AUTH_SOURCE"`
+	ReplicaSet string   `yaml:"replica_set" toml:"replica_set" env:"APP_MONGO_REPLICA_SET"`
+}
+
+type JWTConfig struct {
+	//base URL of this server, set as iss claim and used for discovery endpoints
+	Issuer                       string         `yaml:"issuer" toml:"issuer" env:"APP_JWT_ISSUER"`
+	//aud claim of the access tokens
+	Audience                     string         `yaml:"audience" toml:"audience" env:"APP_JWT_AUDIENCE"`
+	//HS256 secret, only used when there isn't any key in keys
+	SigningKey                   string         `yaml:"signing_key" toml:"signing_key" env:"APP_JWT_SIGNING_KEY" secret:"true"`
+	Keys                         []JWTKeyConfig `yaml:"keys" toml:"keys"`
+	AccessTokenMinLifetime       Duration       `yaml:"access_token_min_lifetime" toml:"access_token_min_lifetime" env:"APP_JWT_ACCESS_TOKEN_MIN_LIFETIME"`
+	AccessTokenMaxLifetime       Duration       `yaml:"access_token_max_lifetime" toml:"access_token_max_lifetime" env:"APP_JWT_ACCESS_TOKEN_MAX_LIFETIME"`
+	//refresh token family can't be used after this time from sign in
+	RefreshTokenAbsoluteLifetime Duration       `yaml:"refresh_token_absolute_lifetime" toml:"refresh_token_absolute_lifetime" env:"APP_JWT_REFRESH_TOKEN_ABSOLUTE_LIFETIME"`
+	//refresh token can't be used when trusted app didn't use for this time
+	RefreshTokenIdleLifetime     Duration       `yaml:"refresh_token_idle_lifetime" toml:"refresh_token_idle_lifetime" env:"APP_JWT_REFRESH_TOKEN_IDLE_LIFETIME"`
+	//number of rotated refresh tokens that kept for reuse detection
+	RetiredRefreshTokensLimit    int            `yaml:"retired_refresh_tokens_limit" toml:"retired_refresh_tokens_limit" env:"APP_JWT_RETIRED_REFRESH_TOKENS_LIMIT"`
+	//the OAuth2 authorization code should be exchanged for token in this time
+	AuthorizationCodeLifetime    Duration       `yaml:"authorization_code_lifetime" toml:"authorization_code_lifetime" env:"APP_JWT_AUTHORIZATION_CODE_LIFETIME"`
+}
+
+//signing key on disk, the file is PEM private key for RS and ES algorithms and the secret for HS algorithms
+//tokens signed by the active key and verified by active and verify keys, the retired keys are rejected
+type JWTKeyConfig struct {
+	Id        string `yaml:"id" toml:"id"`
+	Algorithm string `yaml:"algorithm" toml:"algorithm"`
+	File      string `yaml:"file" toml:"file"`
+	Status    string `yaml:"status" toml:"status"`
+}
+
+type CORSConfig struct {
+	Enabled          bool     `yaml:"enabled" toml:"enabled" env:"APP_CORS_ENABLED"`
+	AllowOrigins     []string `yaml:"allow_origins" toml:"allow_origins" env:"APP_CORS_ALLOW_ORIGINS"`
+	AllowMethods     []string `yaml:"allow_methods" toml:"allow_methods" env:"APP_CORS_ALLOW_METHODS"`
+	AllowHeaders     []string `yaml:"allow_headers" toml:"allow_headers" env:"APP_CORS_ALLOW_HEADERS"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" env:"APP_CORS_ALLOW_CREDENTIALS"`
+	MaxAge           int      `yaml:"max_age" toml:"max_age" env:"APP_CORS_MAX_AGE"`
+}
+
+type LogConfig struct {
+	Enabled bool   `yaml:"enabled" toml:"enabled" env:"APP_LOG_ENABLED"`
+	Format  string `yaml:"format" toml:"format" env:"APP_LOG_FORMAT"`
+}
+
+//how the emails sent, smtp in production, file and log drivers write the messages for development and testing
+type MailConfig struct {
+	Driver string     `yaml:"driver" toml:"driver" env:"APP_MAIL_DRIVER"`
+	From   string     `yaml:"from" toml:"from" env:"APP_MAIL_FROM"`
+	//messages appended to this file by file driver
+	File   string     `yaml:"file" toml:"file" env:"APP_MAIL_FILE"`
+	SMTP   SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"APP_MAIL_SMTP_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"APP_MAIL_SMTP_PORT"`
+	Username string `yaml:"username" toml:"username" env:"APP_MAIL_SMTP_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"APP_MAIL_SMTP_PASSWORD" secret:"true"`
+}
+
+//the account flows that send token to user by email
+type AccountConfig struct {
+	//page of the web client that get new password, the token appended as query parameter
+	PasswordResetURL               string   `yaml:"password_reset_url" toml:"password_reset_url" env:"APP_ACCOUNT_PASSWORD_RESET_URL"`
+	PasswordResetTokenLifetime     Duration `yaml:"password_reset_token_lifetime" toml:"password_reset_token_lifetime" env:"APP_ACCOUNT_PASSWORD_RESET_TOKEN_LIFETIME"`
+	//page of the web client that verify email, the token appended as query parameter
+	EmailVerificationURL           string   `yaml:"email_verification_url" toml:"email_verification_url" env:"APP_ACCOUNT_EMAIL_VERIFICATION_URL"`
+	EmailVerificationTokenLifetime Duration `yaml:"email_verification_token_lifetime" toml:"email_verification_token_lifetime" env:"APP_ACCOUNT_EMAIL_VERIFICATION_TOKEN_LIFETIME"`
+	//users should verify their email before writing articles
+	RequireVerifiedEmail           bool     `yaml:"require_verified_email" toml:"require_verified_email" env:"APP_ACCOUNT_REQUIRE_VERIFIED_EMAIL"`
+	//how often the roles reloaded from database to get the changes of other instances, zero disable it
+	RoleReloadInterval             Duration `yaml:"role_reload_interval" toml:"role_reload_interval" env:"APP_ACCOUNT_ROLE_RELOAD_INTERVAL"`
+}
+
+//uploaded images and the blob storage that keep them
+type MediaConfig struct {
+	//local or s3, s3 driver works with any S3 compatible storage such as minio
+	Driver            string   `yaml:"driver" toml:"driver" env:"APP_MEDIA_DRIVER"`
+	//directory of local driver
+	Dir               string   `yaml:"dir" toml:"dir" env:"APP_MEDIA_DIR"`
+	S3                S3Config `yaml:"s3" toml:"s3"`
+	//max size of uploaded image in bytes
+	MaxImageSize      int      `yaml:"max_image_size" toml:"max_image_size" env:"APP_MEDIA_MAX_IMAGE_SIZE"`
+	//max width and height of uploaded image in pixels
+	MaxImageDimension int      `yaml:"max_image_dimension" toml:"max_image_dimension" env:"APP_MEDIA_MAX_IMAGE_DIMENSION"`
+	//max-age of Cache-Control header of GET /media, the keys never change so it can be long
+	CacheMaxAge       Duration `yaml:"cache_max_age" toml:"cache_max_age" env:"APP_MEDIA_CACHE_MAX_AGE"`
+}
+
+//the background jobs of articles
+type ArticleConfig struct {
+	//how often the scheduled articles published, zero disable the scheduler in this instance
+	SchedulerInterval Duration `yaml:"scheduler_interval" toml:"scheduler_interval" env:"APP_ARTICLE_SCHEDULER_INTERVAL"`
+	//how long the deleted articles kept in trash, then the TTL index of mongodb removes them
+	TrashRetention    Duration `yaml:"trash_retention" toml:"trash_retention" env:"APP_ARTICLE_TRASH_RETENTION"`
+}
+
+//the objects addressed in path style as endpoint/bucket/key
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint" env:"APP_MEDIA_S3_ENDPOINT"`
+	Region    string `yaml:"region" toml:"region" env:"APP_MEDIA_S3_REGION"`
+	Bucket    string `yaml:"bucket" toml:"bucket" env:"APP_MEDIA_S3_BUCKET"`
+	AccessKey string `yaml:"access_key" toml:"access_key" env:"APP_MEDIA_S3_ACCESS_KEY"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key" env:"APP_MEDIA_S3_SECRET_KEY" secret:"true"`
+}
+
+//default configuration of each environment, the same values that was hardcoded in main
+func Default(environment string) *Config {
+	cfg := &Config{
+		Environment:   DEVELOPMENT_ENV,
+		ListenAddress: ":8090",
+		Debug:         true,
+		Mongo: MongoConfig{
+			Addrs:    []string{"localhost:27017"},
+			Timeout:  Duration{60 * time.Second},
+			Database: "golang_sample_dev",
+		},
+		JWT: JWTConfig{
+			Issuer:                       "http://localhost:8090",
+			Audience:                     DEFAULT_JWT_AUDIENCE,
+			SigningKey:                   DEFAULT_JWT_SIGNING_KEY,
+			AccessTokenMinLifetime:       Duration{24 * time.Hour},
+			AccessTokenMaxLifetime:       Duration{72 * time.Hour},
+			RefreshTokenAbsoluteLifetime: Duration{90 * 24 * time.Hour},
+			RefreshTokenIdleLifetime:     Duration{30 * 24 * time.Hour},
+			RetiredRefreshTokensLimit:    20,
+			AuthorizationCodeLifetime:    Duration{10 * time.Minute},
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"*"},
+			AllowMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			AllowHeaders: []string{"Authorization", "Content-Type"},
+		},
+		Log: LogConfig{
+			Enabled: true,
+			Format:  DEFAULT_LOG_FORMAT,
+		},
+		Mail: MailConfig{
+			Driver: MAIL_LOG_DRIVER,
+			From:   "no-reply@localhost",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+		Account: AccountConfig{
+			PasswordResetURL:               "http://localhost:8090/reset-password",
+			PasswordResetTokenLifetime:     Duration{time.Hour},
+			EmailVerificationURL:           "http://localhost:8090/verify-email",
+			EmailVerificationTokenLifetime: Duration{48 * time.Hour},
+			RoleReloadInterval:             Duration{time.Minute},
+		},
+		Media: MediaConfig{
+			Driver: STORAGE_LOCAL_DRIVER,
+			Dir:    "media",
+			S3: S3Config{
+				Region: "us-east-1",
+			},
+			MaxImageSize:      5 << 20,
+			MaxImageDimension: 4096,
+			CacheMaxAge:       Duration{30 * 24 * time.Hour},
+		},
+		Article: ArticleConfig{
+			SchedulerInterval: Duration{time.Minute},
+			TrashRetention:    Duration{30 * 24 * time.Hour},
+		},
+	}
+	if environment == PRODUCTION_ENV {
+		cfg.Environment = PRODUCTION_ENV
+		cfg.Debug = false
+		cfg.Recover = true
+		cfg.GzipLevel = 5
+		cfg.Mongo.Addrs = []string{"localhost"}
+		cfg.Mongo.Database = "golang_sample"
+		//production must set its own signing key
+		cfg.JWT.SigningKey = ""
+		//production must set its public URL as issuer
+		cfg.JWT.Issuer = ""
+		cfg.Log.Enabled = false
+		//the emails have secret tokens, so never write them in production
+		cfg.Mail.Driver = MAIL_SMTP_DRIVER
+	}
+	return cfg
+}
+
+//load the configuration, first the defaults of APP_ENV, then the file if path is not empty and at the end environment variables
+func Load(path string) (*Config, error) {
+	cfg := Default(os.Getenv("APP_ENV"))
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	//PORT is still supported since some platforms only set it
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.ListenAddress = fmt.Sprint(":", port)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+//decode YAML or TOML file base on file extension over the current values
+func (cfg *Config) loadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: can't read %s: %s", path, err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		_, err = toml.Decode(string(data), cfg)
+	default:
+		return fmt.Errorf("config: %s should be .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: can't decode %s: %s", path, err)
+	}
+	return nil
+}
+
+//check the configuration values, it's called at startup to fail fast
+func (cfg *Config) Validate() error {
+	errs := []string{}
+	if cfg.Environment != DEVELOPMENT_ENV && cfg.Environment != PRODUCTION_ENV {
+		errs = append(errs, fmt.Sprintf("environment should be %s or %s", DEVELOPMENT_ENV, PRODUCTION_ENV))
+	}
+	if cfg.ListenAddress == "" {
+		errs = append(errs, "listen_address is required")
+	}
+	if cfg.GzipLevel < -1 || cfg.GzipLevel > 9 {
+		errs = append(errs, "gzip_level should be between -1 and 9, 0 disable gzip")
+	}
+	if len(cfg.Mongo.Addrs) == 0 {
+		errs = append(errs, "mongo.addrs is required")
+	}
+	if cfg.Mongo.Database == "" {
+		errs = append(errs, "mongo.database is required")
+	}
+	if cfg.Mongo.Timeout.Duration <= 0 {
+		errs = append(errs, "mongo.timeout should be positive")
+	}
+	if cfg.Mongo.Password != "" && cfg.Mongo.Username == "" {
+		errs = append(errs, "mongo.username is required when mongo.password is set")
+	}
+	if cfg.JWT.Issuer == "" {
+		errs = append(errs, "jwt.issuer is required")
+	} else if !isAbsoluteURL(cfg.JWT.Issuer) {
+		errs = append(errs, "jwt.issuer should be absolute http or https URL")
+	}
+	if cfg.JWT.Audience == "" {
+		errs = append(errs, "jwt.audience is required")
+	}
+	if len(cfg.JWT.Keys) == 0 {
+		if cfg.JWT.SigningKey == "" {
+			errs = append(errs, "jwt.signing_key is required when there isn't any jwt.keys")
+		} else if cfg.Environment == PRODUCTION_ENV && cfg.JWT.SigningKey == DEFAULT_JWT_SIGNING_KEY {
+			errs = append(errs, "jwt.signing_key can't be the default key in production")
+		}
+	} else {
+		errs = append(errs, validateJWTKeys(cfg.JWT.Keys)...)
+	}
+	if cfg.JWT.AccessTokenMinLifetime.Duration <= 0 {
+		errs = append(errs, "jwt.access_token_min_lifetime should be positive")
+	}
+	if cfg.JWT.AccessTokenMaxLifetime.Duration < cfg.JWT.AccessTokenMinLifetime.Duration {
+		errs = append(errs, "jwt.access_token_max_lifetime should not be less than jwt.access_token_min_lifetime")
+	}
+	if cfg.JWT.RefreshTokenIdleLifetime.Duration <= 0 {
+		errs = append(errs, "jwt.refresh_token_idle_lifetime should be positive")
+	}
+	if cfg.JWT.RefreshTokenAbsoluteLifetime.Duration < cfg.JWT.RefreshTokenIdleLifetime.Duration {
+		errs = append(errs, "jwt.refresh_token_absolute_lifetime should not be less than jwt.refresh_token_idle_lifetime")
+	}
+	if cfg.JWT.RetiredRefreshTokensLimit < 1 {
+		errs = append(errs, "jwt.retired_refresh_tokens_limit should be at least 1")
+	}
+	if cfg.JWT.AuthorizationCodeLifetime.Duration <= 0 {
+		errs = append(errs, "jwt.authorization_code_lifetime should be positive")
+	}
+	if cfg.CORS.Enabled && len(cfg.CORS.AllowOrigins) == 0 {
+		errs = append(errs, "cors.allow_origins is required when cors is enabled")
+	}
+	if cfg.Log.Enabled && cfg.Log.Format == "" {
+		errs = append(errs, "log.format is required when log is enabled")
+	}
+	errs = append(errs, validateMail(cfg.Mail)...)
+	errs = append(errs, validateMedia(cfg.Media)...)
+	if !isAbsoluteURL(cfg.Account.PasswordResetURL) {
+		errs = append(errs, "account.password_reset_url should be absolute http or https URL")
+	}
+	if cfg.Account.PasswordResetTokenLifetime.Duration <= 0 {
+		errs = append(errs, "account.password_reset_token_lifetime should be positive")
+	}
+	if !isAbsoluteURL(cfg.Account.EmailVerificationURL) {
+		errs = append(errs, "account.email_verification_url should be absolute http or https URL")
+	}
+	if cfg.Account.EmailVerificationTokenLifetime.Duration <= 0 {
+		errs = append(errs, "account.email_verification_token_lifetime should be positive")
+	}
+	if cfg.Account.RoleReloadInterval.Duration < 0 {
+		errs = append(errs, "account.role_reload_interval should not be negative")
+	}
+	if cfg.Article.SchedulerInterval.Duration < 0 {
+		errs = append(errs, "article.scheduler_interval should not be negative")
+	}
+	if cfg.Article.TrashRetention.Duration < time.Second {
+		errs = append(errs, "article.trash_retention should be at least one second")
+	}
+	if len(errs) != 0 {
+		return errors.New("config: " + strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func validateJWTKeys(keys []JWTKeyConfig) []string {
+	errs := []string{}
+	activeCount := 0
+	ids := map[string]bool{}
+	for i, key := range keys {
+		if key.Id == "" {
+			errs = append(errs, fmt.Sprintf("jwt.keys[%d].id is required", i))
+		} else if ids[key.Id] {
+			errs = append(errs, fmt.Sprintf("jwt.keys[%d].id %s is duplicated", i, key.Id))
+		}
+		ids[key.Id] = true
+		if key.Algorithm == "" {
+			errs = append(errs, fmt.Sprintf("jwt.keys[%d].algorithm is required", i))
+		}
+		switch key.Status {
+		case JWT_KEY_ACTIVE_STATUS:
+			activeCount++
+		case JWT_KEY_VERIFY_STATUS, JWT_KEY_RETIRED_STATUS:
+		default:
+			errs = append(errs, fmt.Sprintf("jwt.keys[%d].status should be %s, %s or %s", i, JWT_KEY_ACTIVE_STATUS, JWT_KEY_VERIFY_STATUS, JWT_KEY_RETIRED_STATUS))
+		}
+		//the retired key never loaded so don't need the file
+		if key.File == "" && key.Status != JWT_KEY_RETIRED_STATUS {
+			errs = append(errs, fmt.Sprintf("jwt.keys[%d].file is required", i))
+		}
+	}
+	if activeCount != 1 {
+		errs = append(errs, "jwt.keys should have exactly one active key")
+	}
+	return errs
+}
+
+func validateMail(mail MailConfig) []string {
+	errs := []string{}
+	if mail.From == "" {
+		errs = append(errs, "mail.from is required")
+	}
+	switch mail.Driver {
+	case MAIL_SMTP_DRIVER:
+		if mail.SMTP.Host == "" {
+			errs = append(errs, "mail.smtp.host is required for smtp driver")
+		}
+		if mail.SMTP.Port <= 0 || mail.SMTP.Port > 65535 {
+			errs = append(errs, "mail.smtp.port is not valid")
+		}
+	case MAIL_FILE_DRIVER:
+		if mail.File == "" {
+			errs = append(errs, "mail.file is required for file driver")
+		}
+	case MAIL_LOG_DRIVER:
+	default:
+		errs = append(errs, fmt.Sprintf("mail.driver should be %s, %s or %s", MAIL_SMTP_DRIVER, MAIL_FILE_DRIVER, MAIL_LOG_DRIVER))
+	}
+	return errs
+}
+
+func validateMedia(media MediaConfig) []string {
+	errs := []string{}
+	switch media.Driver {
+	case STORAGE_LOCAL_DRIVER:
+		if media.Dir == "" {
+			errs = append(errs, "media.dir is required for local driver")
+		}
+	case STORAGE_S3_DRIVER:
+		if !isAbsoluteURL(media.S3.Endpoint) {
+			errs = append(errs, "media.s3.endpoint should be absolute http or https URL")
+		}
+		if media.S3.Region == "" {
+			errs = append(errs, "media.s3.region is required for s3 driver")
+		}
+		if media.S3.Bucket == "" {
+			errs = append(errs, "media.s3.bucket is required for s3 driver")
+		}
+		if media.S3.AccessKey == "" || media.S3.SecretKey == "" {
+			errs = append(errs, "media.s3.access_key and media.s3.secret_key are required for s3 driver")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("media.driver should be %s or %s", STORAGE_LOCAL_DRIVER, STORAGE_S3_DRIVER))
+	}
+	if media.MaxImageSize <= 0 {
+		errs = append(errs, "media.max_image_size should be positive")
+	}
+	if media.MaxImageDimension <= 0 {
+		errs = append(errs, "media.max_image_dimension should be positive")
+	}
+	if media.CacheMaxAge.Duration < 0 {
+		errs = append(errs, "media.cache_max_age should not be negative")
+	}
+	return errs
+}
+
+func isAbsoluteURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+//dial info for create mongodb session
+func (m MongoConfig) DialInfo() *mgo.DialInfo {
+	return &mgo.DialInfo{
+		Addrs:          m.Addrs,
+		Timeout:        m.Timeout.Duration,
+		Database:       m.Database,
+		Username:       m.Username,
+		Password:       m.Password,
+		Source:         m.AuthSource,
+		ReplicaSetName: m.ReplicaSet,
+	}
+}
+
+//the effective configuration as YAML, the secret values replaced
+func (cfg *Config) Redacted() ([]byte, error) {
+	//copy the config via YAML so redaction doesn't change the slices of cfg
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cp := Config{}
+	if err := yaml.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	redact(&cp)
+	return yaml.Marshal(&cp)
+}
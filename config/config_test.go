@@ -0,0 +1,188 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	yamlPath := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(yamlPath, []byte("listen_address: \":9000\"\nmongo:\n  addrs: [\"db1:27017\", \"db2:27017\"]\n  timeout: 10s\n  replica_set: rs0\n"), 0600)
+	tomlPath := filepath.Join(dir, "config.toml")
+	ioutil.WriteFile(tomlPath, []byte("listen_address = \":9001\"\n[jwt]\naccess_token_min_lifetime = \"1h\"\naccess_token_max_lifetime = \"2h\"\n"), 0600)
+	keysPath := filepath.Join(dir, "keys.yaml")
+	ioutil.WriteFile(keysPath, []byte("jwt:\n  keys:\n    - {id: k1, algorithm: RS256, file: k1.pem, status: active}\n    - {id: k2, algorithm: ES256, file: k2.pem, status: active}\n"), 0600)
+	jsonPath := filepath.Join(dir, "config.json")
+	ioutil.WriteFile(jsonPath, []byte("{}"), 0600)
+	//define different cases
+	cases := []struct {
+		path          string
+		env           map[string]string
+		expectedError bool
+		check         func(cfg *Config) bool
+	}{
+		{
+			path:  "",
+			check: func(cfg *Config) bool { return cfg.ListenAddress == ":8090" && cfg.Mongo.Database == "golang_sample_dev" },
+		},
+		{
+			path: yamlPath,
+			check: func(cfg *Config) bool {
+				return cfg.ListenAddress == ":9000" && len(cfg.Mongo.Addrs) == 2 && cfg.Mongo.Timeout.Duration == 10*time.Second && cfg.Mongo.ReplicaSet == "rs0"
+			},
+		},
+		{
+			path:  tomlPath,
+			check: func(cfg *Config) bool { return cfg.ListenAddress == ":9001" && cfg.JWT.AccessTokenMaxLifetime.Duration == 2*time.Hour },
+		},
+		{
+			path: yamlPath,
+			env:  map[string]string{"APP_MONGO_ADDRS": "db3:27017", "PORT": "7000", "APP_CORS_ENABLED": "true"},
+			check: func(cfg *Config) bool {
+				return cfg.ListenAddress == ":7000" && len(cfg.Mongo.Addrs) == 1 && cfg.Mongo.Addrs[0] == "db3:27017" && cfg.CORS.Enabled
+			},
+		},
+		{
+			path:          jsonPath,
+			expectedError: true,
+		},
+		{
+			//only one key can be active
+			path:          keysPath,
+			expectedError: true,
+		},
+		{
+			env:           map[string]string{"APP_MONGO_TIMEOUT": "ten seconds"},
+			expectedError: true,
+		},
+		{
+			//production needs its own signing key
+			env:           map[string]string{"APP_ENV": PRODUCTION_ENV},
+			expectedError: true,
+		},
+		{
+			//production needs its own issuer
+			env:           map[string]string{"APP_ENV": PRODUCTION_ENV, "APP_JWT_SIGNING_KEY": "production key"},
+			expectedError: true,
+		},
+		{
+			env:           map[string]string{"APP_JWT_ISSUER": "localhost:8090"},
+			expectedError: true,
+		},
+		{
+			//production sends emails by smtp
+			env:           map[string]string{"APP_ENV": PRODUCTION_ENV, "APP_JWT_SIGNING_KEY": "production key", "APP_JWT_ISSUER": "https://api.example.com"},
+			expectedError: true,
+		},
+		{
+			env: map[string]string{"APP_ENV": PRODUCTION_ENV, "APP_JWT_SIGNING_KEY": "production key", "APP_JWT_ISSUER": "https://api.example.com", "APP_MAIL_SMTP_HOST": "smtp.example.com"},
+			check: func(cfg *Config) bool {
+				return cfg.GzipLevel == 5 && cfg.Recover && !cfg.Debug && cfg.Mongo.Database == "golang_sample" && cfg.Mail.Driver == MAIL_SMTP_DRIVER
+			},
+		},
+		{
+			env:           map[string]string{"APP_MAIL_DRIVER": MAIL_FILE_DRIVER},
+			expectedError: true,
+		},
+		{
+			env:   map[string]string{"APP_MAIL_DRIVER": MAIL_FILE_DRIVER, "APP_MAIL_FILE": "/tmp/mails.txt", "APP_MAIL_SMTP_PORT": "25"},
+			check: func(cfg *Config) bool { return cfg.Mail.File == "/tmp/mails.txt" && cfg.Mail.SMTP.Port == 25 },
+		},
+		{
+			//s3 driver needs the bucket and credential
+			env:           map[string]string{"APP_MEDIA_DRIVER": STORAGE_S3_DRIVER, "APP_MEDIA_S3_ENDPOINT": "http://localhost:9000"},
+			expectedError: true,
+		},
+		{
+			env:           map[string]string{"APP_MEDIA_MAX_IMAGE_SIZE": "0"},
+			expectedError: true,
+		},
+		{
+			env: map[string]string{"APP_MEDIA_DRIVER": STORAGE_S3_DRIVER, "APP_MEDIA_S3_ENDPOINT": "http://localhost:9000", "APP_MEDIA_S3_BUCKET": "media", "APP_MEDIA_S3_ACCESS_KEY": "access", "APP_MEDIA_S3_SECRET_KEY": "secret"},
+			check: func(cfg *Config) bool {
+				return cfg.Media.S3.Bucket == "media" && cfg.Media.S3.Region == "us-east-1" && cfg.Media.MaxImageSize == 5<<20
+			},
+		},
+		{
+			env:   map[string]string{"APP_JWT_AUTHORIZATION_CODE_LIFETIME": "5m"},
+			check: func(cfg *Config) bool { return cfg.JWT.AuthorizationCodeLifetime.Duration == 5*time.Minute },
+		},
+		{
+			env:           map[string]string{"APP_JWT_AUTHORIZATION_CODE_LIFETIME": "0s"},
+			expectedError: true,
+		},
+		{
+			env:   map[string]string{"APP_ACCOUNT_ROLE_RELOAD_INTERVAL": "30s"},
+			check: func(cfg *Config) bool { return cfg.Account.RoleReloadInterval.Duration == 30*time.Second },
+		},
+		{
+			env:           map[string]string{"APP_ACCOUNT_ROLE_RELOAD_INTERVAL": "-1s"},
+			expectedError: true,
+		},
+		{
+			env:   map[string]string{"APP_ARTICLE_SCHEDULER_INTERVAL": "0s"},
+			check: func(cfg *Config) bool { return cfg.Article.SchedulerInterval.Duration == 0 },
+		},
+		{
+			env:           map[string]string{"APP_ARTICLE_SCHEDULER_INTERVAL": "-1m"},
+			expectedError: true,
+		},
+		{
+			env:   map[string]string{"APP_ARTICLE_TRASH_RETENTION": "168h"},
+			check: func(cfg *Config) bool { return cfg.Article.TrashRetention.Duration == 7*24*time.Hour },
+		},
+		{
+			env:           map[string]string{"APP_ARTICLE_TRASH_RETENTION": "0s"},
+			expectedError: true,
+		},
+	}
+	for i, c := range cases {
+		for k, v := range c.env {
+			os.Setenv(k, v)
+		}
+		cfg, err := Load(c.path)
+		for k := range c.env {
+			os.Unsetenv(k)
+		}
+		if (err != nil) != c.expectedError {
+			t.Errorf("case %d: Error should be %t \t but get %v", i, c.expectedError, err)
+			continue
+		}
+		if err == nil && !c.check(cfg) {
+			t.Errorf("case %d: loaded config is not as expected %+v", i, cfg)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default(DEVELOPMENT_ENV)
+	cfg.Mongo.Username = "admin"
+	cfg.Mongo.Password = "mongo password"
+	cfg.Mail.SMTP.Password = "smtp password"
+	cfg.Media.S3.SecretKey = "s3 secret"
+	out, err := cfg.Redacted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"mongo password", "smtp password", "s3 secret", DEFAULT_JWT_SIGNING_KEY} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("the printed config should not have %q", secret)
+		}
+	}
+	if !strings.Contains(string(out), "admin") {
+		t.Error("the printed config should have the not secret values")
+	}
+	//the config itself should not change
+	if cfg.Mongo.Password != "mongo password" {
+		t.Error("redaction should not change the config")
+	}
+}
@@ -0,0 +1,60 @@
+package article
+
+import (
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	READ_ARTICLE_ACTION = "read"
+	//change the title, content and tags
+	UPDATE_ARTICLE_ACTION = "update"
+	//move to trash, it's only for owner like the other actions such as publish
+	DELETE_ARTICLE_ACTION = "delete"
+	//add, change or remove the collaborators
+	SHARE_ARTICLE_ACTION = "share"
+)
+
+//the only place that decides what user can do with the article
+//the owner can do everything, the collaborators can read and the editors can update too
+//the others can only read the unlisted and public articles that are published
+func CanAccessArticle(a *models.Article, userId bson.ObjectId, action string) bool {
+	if a.UserId == userId {
+		return true
+	}
+	role := a.CollaboratorRole(userId)
+	switch action {
+	case READ_ARTICLE_ACTION:
+		return role != "" || (a.IsPublished() && (a.Visibility == models.UNLISTED_VISIBILITY || a.Visibility == models.PUBLIC_VISIBILITY))
+	case UPDATE_ARTICLE_ACTION:
+		return role == models.EDITOR_COLLABORATOR_ROLE
+	}
+	return false
+}
+
+//the article of id parameter if user can do the action, the articles that user can't read are not found
+func (ac ArticleController) findArticle(c echo.Context, userId bson.ObjectId, action string) (*models.Article, error) {
+	//first check is id valid or not
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return nil, specialerror.ErrNotValidItemId
+	}
+	article, err := ac.Articles.FindById(bson.ObjectIdHex(c.Param("id")))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	if !CanAccessArticle(article, userId, READ_ARTICLE_ACTION) {
+		return nil, specialerror.ErrNotFoundAnyItemWithThisId
+	}
+	if !CanAccessArticle(article, userId, action) {
+		return nil, specialerror.ErrCanNotAccessToTheseResource
+	}
+	return article, nil
+}
@@ -2,30 +2,58 @@ package article
 
 import (
 	"time"
+	"strconv"
+	"strings"
 	"net/http"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/labstack/echo"
 
 	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/etag"
+	"github.com/atahani/golang-rest-api-sample/util/pagination"
+	"github.com/atahani/golang-rest-api-sample/util/textsearch"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 	"github.com/atahani/golang-rest-api-sample/util/operationresult"
 	"github.com/atahani/golang-rest-api-sample/controller/user"
 )
 
 const (
-	ARTICLE_COLLECTION_NAME = "articles"
+	TITLE_PREFIX_PARAM = "title_prefix"
+	CREATED_FROM_PARAM = "created_from"
+	CREATED_TO_PARAM = "created_to"
+	UPDATED_FROM_PARAM = "updated_from"
+	UPDATED_TO_PARAM = "updated_to"
+	VISIBILITY_PARAM = "visibility"
+	STATUS_PARAM = "status"
+	TAG_PARAM = "tag"
+	SEARCH_QUERY_PARAM = "q"
+	//own (default) for the articles of user and shared for the articles that shared with user
+	VIEW_PARAM = "view"
+	OWN_VIEW = "own"
+	SHARED_VIEW = "shared"
+	//max characters of snippet in search results
+	SNIPPET_SIZE = 160
 )
 
+//sort query parameter of articles list and its field
+var articleSortFields = map[string]string{
+	"created": "created_at",
+	"updated": "updated_at",
+	"title":   "title",
+}
+
 type ArticleController struct {
-	Session *mgo.Session
-	DBName  string
+	Articles  store.ArticleStore
+	Revisions store.ArticleRevisionStore
+	Comments  store.CommentStore
+	Users     store.UserStore
 }
 
-func NewArticleController(s *mgo.Session, dbName string) *ArticleController {
-	return &ArticleController{s, dbName}
+func NewArticleController(stores *store.Stores) *ArticleController {
+	return &ArticleController{stores.Articles, stores.ArticleRevisions, stores.Comments, stores.Users}
 }
 
 func (ac ArticleController) CreateArticle(c echo.Context) error {
@@ -44,10 +72,23 @@ func (ac ArticleController) CreateArticle(c echo.Context) error {
 	if err := c.Bind(&article); err != nil {
 		return err
 	}
-	//get copy of db session
-	session := ac.Session.Copy()
-	defer session.Close()
-	if err := session.DB(ac.DBName).C(ARTICLE_COLLECTION_NAME).Insert(&article); err != nil {
+	article.Version = 1
+	//the external id only set by import
+	article.ExternalId = ""
+	//the new article is draft until its owner publish it
+	article.Status = models.DRAFT_STATUS
+	article.PublishedAt = nil
+	article.ScheduledFor = nil
+	article.CommentCount = 0
+	article.Collaborators = nil
+	if article.Visibility == "" {
+		article.Visibility = models.PRIVATE_VISIBILITY
+	}
+	if err := ac.Articles.Insert(&article); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	//the first revision of article
+	if err := ac.Revisions.Insert(models.NewArticleRevision(&article, userId)); err != nil {
 		return specialerror.ErrInternalServerError
 	}
 	//return the new article
@@ -56,20 +97,25 @@ func (ac ArticleController) CreateArticle(c echo.Context) error {
 }
 
 func (ac ArticleController) GetArticleById(c echo.Context) error {
-	//first check is id valid or not
-	if !bson.IsObjectIdHex(c.Param("id")) {
-		return specialerror.ErrNotValidItemId
-	}
-	//get copy of db session
-	session := ac.Session.Copy()
-	defer session.Close()
-	article := models.Article{}
-	if err := session.DB(ac.DBName).C(ARTICLE_COLLECTION_NAME).FindId(bson.ObjectIdHex(c.Param("id"))).One(&article); err != nil {
-		if err == mgo.ErrNotFound {
-			return specialerror.ErrNotFoundAnyItemWithThisId
-		}
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
 		return specialerror.ErrInternalServerError
 	}
+	//the private article of other users is not found for this user unless it's shared with user
+	article, err := ac.findArticle(c, userId, READ_ARTICLE_ACTION)
+	if err != nil {
+		return err
+	}
+	//only the owner see the collaborators
+	if article.UserId != userId {
+		article.Collaborators = nil
+	}
+	//the client can send the ETag in If-Match header of update and delete
+	tag := etag.Version(article.Version)
+	c.Response().Header().Set(etag.ETAG_HEADER, tag)
+	if etag.Match(c.Request().Header().Get(etag.IF_NONE_MATCH_HEADER), tag) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	//send the article
 	c.JSON(http.StatusOK, article)
 	return nil
@@ -81,17 +127,26 @@ func (ac ArticleController) DeleteArticleById(c echo.Context) error {
 	if !ok {
 		return specialerror.ErrInternalServerError
 	}
-	//first check is id valid or not
-	if !bson.IsObjectIdHex(c.Param("id")) {
-		return specialerror.ErrNotValidItemId
+	//the collaborators can't delete the article
+	if _, err := ac.findArticle(c, userId, DELETE_ARTICLE_ACTION); err != nil {
+		return err
 	}
-	//get copy of db session
-	session := ac.Session.Copy()
-	defer session.Close()
-	if err := session.DB(ac.DBName).C(ARTICLE_COLLECTION_NAME).Remove(bson.M{"_id":bson.ObjectIdHex(c.Param("id")), "user_id":userId}); err != nil {
-		if err == mgo.ErrNotFound {
+	versions := etag.IfMatchVersions(c.Request().Header().Get(etag.IF_MATCH_HEADER))
+	//the article moved to trash with its revisions and comments, so they purged together
+	deletedAt := time.Now()
+	if err := ac.Articles.TrashOwned(bson.ObjectIdHex(c.Param("id")), userId, deletedAt, versions); err != nil {
+		if err == store.ErrNotFound {
 			return specialerror.ErrNotFoundAnyItemWithThisId
 		}
+		if err == store.ErrVersionConflict {
+			return specialerror.ErrPreconditionFailed
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Revisions.SetDeletedAtByArticleId(bson.ObjectIdHex(c.Param("id")), &deletedAt); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Comments.SetDeletedAtByArticleId(bson.ObjectIdHex(c.Param("id")), &deletedAt); err != nil {
 		return specialerror.ErrInternalServerError
 	}
 	//inform user that this article removed successfully
@@ -105,49 +160,134 @@ func (ac ArticleController) UpdateArticleById(c echo.Context) error {
 	if !ok {
 		return specialerror.ErrInternalServerError
 	}
-	//first check is id valid or not
-	if !bson.IsObjectIdHex(c.Param("id")) {
-		return specialerror.ErrNotValidItemId
+	//the owner and the editors can update the article
+	article, err := ac.findArticle(c, userId, UPDATE_ARTICLE_ACTION)
+	if err != nil {
+		return err
 	}
 	updatedArticle := models.Article{}
 	//the binder check if struct is not valid return err
 	if err := c.Bind(&updatedArticle); err != nil {
 		return err
 	}
-	articleUpdateSet := bson.M{
-		"title": updatedArticle.Title,
-		"content":updatedArticle.Content,
-		"updated_at":time.Now(),
-	}
-	//get copy of db session
-	session := ac.Session.Copy()
-	defer session.Close()
-	//NOTE: since we want to update article with one query we don't check is article own by this user separately
-	if err := session.DB(ac.DBName).C(ARTICLE_COLLECTION_NAME).Update(bson.M{"_id":bson.ObjectIdHex(c.Param("id")), "user_id":userId}, bson.M{"$set":articleUpdateSet}); err != nil {
-		if err == mgo.ErrNotFound {
-			return specialerror.ErrCanNotAccessToTheseResource
+	updatedArticle.Id = article.Id
+	//the visibility is the sharing decision of owner, so the editors can't change it
+	if article.UserId != userId {
+		updatedArticle.Visibility = ""
+	}
+	versions := etag.IfMatchVersions(c.Request().Header().Get(etag.IF_MATCH_HEADER))
+	//keep the current text as the first revision, so it can be restored after this update
+	if err := ac.ensureFirstRevision(article); err != nil {
+		return err
+	}
+	if err := ac.Articles.UpdateOwned(updatedArticle.Id, article.UserId, &updatedArticle, versions); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		if err == store.ErrVersionConflict {
+			return specialerror.ErrPreconditionFailed
 		}
 		return specialerror.ErrInternalServerError
 	}
+	if err := ac.Revisions.Insert(models.NewArticleRevision(&updatedArticle, userId)); err != nil {
+		return specialerror.ErrInternalServerError
+	}
 	//inform user this article update successfully
 	c.JSON(http.StatusOK, operationresult.SuccessfullyUpdated)
 	return nil
 }
 
 func (ac ArticleController) GetArticlesOfUser(c echo.Context) error {
-	//get copy of db session
-	session := ac.Session.Copy()
-	defer session.Close()
 	//get user_id from Context
 	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId);
 	if !ok {
 		return specialerror.ErrInternalServerError
 	}
-	result := [] models.Article{}
-	if err := session.DB(ac.DBName).C(ARTICLE_COLLECTION_NAME).Find(bson.M{"user_id":userId}).All(&result); err != nil {
+	page, err := pagination.ParsePageRequest(c, articleSortFields, "created")
+	if err != nil {
+		return err
+	}
+	filter := store.ArticleFilter{UserId: userId, TitlePrefix: c.QueryParam(TITLE_PREFIX_PARAM)}
+	switch view := c.QueryParam(VIEW_PARAM); view {
+	case "", OWN_VIEW:
+	case SHARED_VIEW:
+		filter.SharedWith = userId
+	default:
+		return specialerror.ErrNotValidQueryParameter
+	}
+	switch visibility := c.QueryParam(VISIBILITY_PARAM); visibility {
+	case "":
+	case models.PRIVATE_VISIBILITY, models.UNLISTED_VISIBILITY, models.PUBLIC_VISIBILITY:
+		filter.Visibility = visibility
+	default:
+		return specialerror.ErrNotValidQueryParameter
+	}
+	switch status := c.QueryParam(STATUS_PARAM); status {
+	case "":
+	case models.DRAFT_STATUS, models.SCHEDULED_STATUS, models.PUBLISHED_STATUS, models.ARCHIVED_STATUS:
+		filter.Status = status
+	default:
+		return specialerror.ErrNotValidQueryParameter
+	}
+	if tag := c.QueryParam(TAG_PARAM); tag != "" {
+		filter.Tag = models.NormalizeTag(tag)
+		if !models.IsValidTag(filter.Tag) {
+			return specialerror.ErrNotValidQueryParameter
+		}
+	}
+	//the date ranges, from is inclusive and to is exclusive
+	if filter.CreatedFrom, err = pagination.ParseTimeParam(c, CREATED_FROM_PARAM); err != nil {
+		return err
+	}
+	if filter.CreatedTo, err = pagination.ParseTimeParam(c, CREATED_TO_PARAM); err != nil {
+		return err
+	}
+	if filter.UpdatedFrom, err = pagination.ParseTimeParam(c, UPDATED_FROM_PARAM); err != nil {
+		return err
+	}
+	if filter.UpdatedTo, err = pagination.ParseTimeParam(c, UPDATED_TO_PARAM); err != nil {
+		return err
+	}
+	result, err := ac.Articles.FindPage(filter, *page)
+	if err != nil {
 		return specialerror.ErrInternalServerError
 	}
-	//send articles
+	//only the owner see the collaborators
+	if articles, ok := result.Items.([]models.Article); ok && filter.SharedWith != "" {
+		for i := range articles {
+			articles[i].Collaborators = nil
+		}
+	}
+	//send page of articles
 	c.JSON(http.StatusOK, result)
 	return nil
 }
+
+//full text search in the articles of user, the results sorted by relevance and have highlighted snippet
+func (ac ArticleController) SearchArticles(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	query := strings.TrimSpace(c.QueryParam(SEARCH_QUERY_PARAM))
+	if query == "" {
+		return specialerror.ErrNotValidQueryParameter
+	}
+	limit := store.DEFAULT_PAGE_LIMIT
+	if value := c.QueryParam(pagination.LIMIT_PARAM); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > store.MAX_PAGE_LIMIT {
+			return specialerror.ErrNotValidQueryParameter
+		}
+	}
+	result, err := ac.Articles.Search(userId, query, limit)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	terms := textsearch.Terms(query)
+	for i := range result {
+		result[i].Snippet = textsearch.Snippet(result[i].Content, terms, SNIPPET_SIZE)
+	}
+	c.JSON(http.StatusOK, &models.Page{Items: result})
+	return nil
+}
@@ -6,6 +6,12 @@ import (
 	"testing"
 	"bytes"
 	"encoding/json"
+	"time"
+	"net/http"
+	"io"
+	"io/ioutil"
+	"strings"
+	"archive/zip"
 
 	"gopkg.in/mgo.v2/bson"
 
@@ -14,9 +20,11 @@ import (
 	"github.com/labstack/echo/engine"
 
 	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
 	"github.com/atahani/golang-rest-api-sample/util/testhelper"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/frontmatter"
 	"github.com/atahani/golang-rest-api-sample/controller/user"
 )
 
@@ -25,10 +33,7 @@ var newArticleIdStr string
 var userIdObj bson.ObjectId
 
 func TestCreateArticle(t *testing.T) {
-	//get copy of session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	articleController := NewArticleController(session, testhelper.DB_TEST_NAME)
+	articleController := NewArticleController(testingProvider.Stores)
 	//define different cases
 	path := "/api/article"
 	method := echo.POST
@@ -69,10 +74,7 @@ func TestCreateArticle(t *testing.T) {
 func TestGetArticleById(t *testing.T) {
 	//since the path have id param should add it to routeer
 	testingProvider.Router.Add(echo.GET, "/api/article/:id", nil, testingProvider.Echo)
-	//get copy of db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	articleController := NewArticleController(session, testhelper.DB_TEST_NAME)
+	articleController := NewArticleController(testingProvider.Stores)
 	path := fmt.Sprintf("/api/article/%s", newArticleIdStr)
 	method := echo.GET
 	cases := []struct {
@@ -109,9 +111,7 @@ func TestGetArticleById(t *testing.T) {
 func TestUpdateArticleById(t *testing.T) {
 	//add path with id to router
 	testingProvider.Router.Add(echo.PUT, "/api/article/:id", nil, testingProvider.Echo)
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	articleController := NewArticleController(session, testhelper.DB_TEST_NAME)
+	articleController := NewArticleController(testingProvider.Stores)
 	//define different cases
 	path := fmt.Sprintf("/api/article/%s", newArticleIdStr)
 	method := echo.PUT
@@ -157,9 +157,7 @@ func TestUpdateArticleById(t *testing.T) {
 }
 
 func TestGetArticlesOfUser(t *testing.T) {
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	articleController := NewArticleController(session, testhelper.DB_TEST_NAME)
+	articleController := NewArticleController(testingProvider.Stores)
 	path := "/api/article"
 	req := test.NewRequest(echo.GET, path, nil)
 	res := test.NewResponseRecorder()
@@ -167,24 +165,1049 @@ func TestGetArticlesOfUser(t *testing.T) {
 	//set the user_id for context
 	context.Set(user.USER_ID_KEY, userIdObj)
 	if err := articleController.GetArticlesOfUser(context); err != nil {
-		t.Errorf("Error should %q \t but get %q", nil, err)
+		t.Errorf("Error should %v \t but get %q", nil, err)
 	}
 	//check have at least one article
-	result := [] models.Article{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err == nil {
+	result := []models.Article{}
+	if err := json.NewDecoder(res.Body).Decode(&models.Page{Items: &result}); err == nil {
 		if len(result) == 0 {
 			t.Error("should at least one article in get articles request")
 		}
 	}
 }
 
+func TestGetArticlesOfUserPagination(t *testing.T) {
+	articleController := NewArticleController(testingProvider.Stores)
+	ownerId := bson.NewObjectId()
+	start := time.Date(2016, 9, 1, 0, 0, 0, 0, time.UTC)
+	titles := []string{"golang", "docker", "Go channels", "mongodb", "go echo"}
+	for i, title := range titles {
+		testingProvider.Stores.Articles.Insert(&models.Article{
+			Id:        bson.NewObjectId(),
+			Title:     title,
+			Content:   "some content ...",
+			UserId:    ownerId,
+			CreatedAt: start.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: start.Add(time.Duration(len(titles) - i) * time.Hour),
+		})
+	}
+	cases := []struct {
+		query          string
+		expectedTitles []string
+		expectedError  error
+	}{
+		{query: "limit=2", expectedTitles: []string{"go echo", "mongodb", "Go channels", "docker", "golang"}},
+		{query: "limit=2&sort=created&order=asc", expectedTitles: []string{"golang", "docker", "Go channels", "mongodb", "go echo"}},
+		{query: "limit=3&sort=updated", expectedTitles: []string{"golang", "docker", "Go channels", "mongodb", "go echo"}},
+		{query: "limit=2&sort=title&order=asc", expectedTitles: []string{"Go channels", "docker", "go echo", "golang", "mongodb"}},
+		{query: "title_prefix=go&order=asc", expectedTitles: []string{"golang", "Go channels", "go echo"}},
+		{query: "created_from=2016-09-01T01:00:00Z&created_to=2016-09-01T03:00:00Z", expectedTitles: []string{"Go channels", "docker"}},
+		{query: "limit=0", expectedError: specialerror.ErrNotValidQueryParameter},
+		{query: "sort=content", expectedError: specialerror.ErrNotValidQueryParameter},
+		{query: "created_from=yesterday", expectedError: specialerror.ErrNotValidQueryParameter},
+		{query: "cursor=notvalidcursor", expectedError: specialerror.ErrNotValidCursor},
+	}
+	for _, c := range cases {
+		titles := []string{}
+		cursor := ""
+		//get all of the pages
+		for {
+			path := "/api/article?total=true&" + c.query
+			if cursor != "" {
+				path += "&cursor=" + cursor
+			}
+			res := test.NewResponseRecorder()
+			context := echo.NewContext(test.NewRequest(echo.GET, path, nil), res, testingProvider.Echo)
+			context.Set(user.USER_ID_KEY, ownerId)
+			if err := articleController.GetArticlesOfUser(context); err != c.expectedError {
+				t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+			}
+			if c.expectedError != nil {
+				break
+			}
+			items := []models.Article{}
+			page := models.Page{Items: &items}
+			if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if page.Total == nil || *page.Total != len(c.expectedTitles) {
+				t.Errorf("Error should %d \t but get %v", len(c.expectedTitles), page.Total)
+			}
+			for _, item := range items {
+				titles = append(titles, item.Title)
+			}
+			if cursor = page.NextCursor; cursor == "" {
+				break
+			}
+		}
+		if c.expectedError == nil && fmt.Sprint(titles) != fmt.Sprint(c.expectedTitles) {
+			t.Errorf("%s: Error should %v \t but get %v", c.query, c.expectedTitles, titles)
+		}
+	}
+}
+
+func TestSearchArticles(t *testing.T) {
+	articleController := NewArticleController(testingProvider.Stores)
+	ownerId := bson.NewObjectId()
+	articles := []models.Article{
+		{Title: "channels in golang", Content: "the select statement waits on multiple channels"},
+		{Title: "docker compose", Content: "run golang services with docker"},
+		{Title: "mongodb text index", Content: "text search of mongodb"},
+	}
+	for _, article := range articles {
+		article.Id = bson.NewObjectId()
+		article.UserId = ownerId
+		article.CreatedAt = time.Now()
+		testingProvider.Stores.Articles.Insert(&article)
+	}
+	//the article of other user should not be found
+	testingProvider.Stores.Articles.Insert(&models.Article{Id: bson.NewObjectId(), UserId: bson.NewObjectId(), Title: "golang", Content: "golang"})
+	cases := []struct {
+		query            string
+		expectedTitles   []string
+		expectedSnippets []string
+		expectedError    error
+	}{
+		{
+			query:            "golang",
+			expectedTitles:   []string{"channels in golang", "docker compose"},
+			expectedSnippets: []string{"the select statement waits on multiple channels", "run <mark>golang</mark> services with docker"},
+		},
+		{
+			query:            "channel&limit=1",
+			expectedTitles:   []string{"channels in golang"},
+			expectedSnippets: []string{"...waits on multiple <mark>channels</mark>"},
+		},
+		{query: "kubernetes", expectedTitles: []string{}, expectedSnippets: []string{}},
+		{query: "", expectedError: specialerror.ErrNotValidQueryParameter},
+		{query: "golang&limit=1000", expectedError: specialerror.ErrNotValidQueryParameter},
+	}
+	for _, c := range cases {
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(test.NewRequest(echo.GET, "/api/article/search?q=" + c.query, nil), res, testingProvider.Echo)
+		context.Set(user.USER_ID_KEY, ownerId)
+		if err := articleController.SearchArticles(context); err != c.expectedError {
+			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+		}
+		if c.expectedError != nil {
+			continue
+		}
+		result := []models.ArticleSearchResult{}
+		if err := json.NewDecoder(res.Body).Decode(&models.Page{Items: &result}); err != nil {
+			t.Fatal(err)
+		}
+		titles, snippets := []string{}, []string{}
+		for _, item := range result {
+			titles = append(titles, item.Title)
+			snippets = append(snippets, item.Snippet)
+		}
+		if fmt.Sprint(titles) != fmt.Sprint(c.expectedTitles) || fmt.Sprint(snippets) != fmt.Sprint(c.expectedSnippets) {
+			t.Errorf("Error should %v %q \t but get %v %q", c.expectedTitles, c.expectedSnippets, titles, snippets)
+		}
+	}
+}
+
+func TestArticleVisibility(t *testing.T) {
+	testingProvider.Router.Add(echo.GET, "/api/article/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.GET, "/public/article/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.GET, "/public/user/:id/articles", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/publish", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	author := models.User{Id: bson.NewObjectId(), DisplayName: "Ahmad Tahani", IsEnable: true}
+	disabled := models.User{Id: bson.NewObjectId(), DisplayName: "disabled user", IsEnable: false}
+	testingProvider.Stores.Users.Insert(&author)
+	testingProvider.Stores.Users.Insert(&disabled)
+	ids := map[string]string{}
+	for _, visibility := range []string{models.PRIVATE_VISIBILITY, models.UNLISTED_VISIBILITY, models.PUBLIC_VISIBILITY} {
+		req := test.NewRequest(echo.POST, "/api/article", bytes.NewBufferString(`{"title":"` + visibility + ` article","content":"some content ...","visibility":"` + visibility + `"}`))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		context.Set(user.USER_ID_KEY, author.Id)
+		if err := articleController.CreateArticle(context); err != nil {
+			t.Fatal(err)
+		}
+		article := models.Article{}
+		json.NewDecoder(res.Body).Decode(&article)
+		ids[visibility] = article.Id.Hex()
+		//the new articles are draft
+		path := "/api/article/" + article.Id.Hex() + "/publish"
+		context = echo.NewContext(test.NewRequest(echo.POST, path, nil), test.NewResponseRecorder(), testingProvider.Echo)
+		testingProvider.Router.Find(echo.POST, path, context)
+		context.Set(user.USER_ID_KEY, author.Id)
+		if err := articleController.PublishArticle(context); err != nil {
+			t.Fatal(err)
+		}
+	}
+	draftArticle := models.Article{Id: bson.NewObjectId(), UserId: author.Id, Title: "draft", Content: "draft", Visibility: models.PUBLIC_VISIBILITY, Status: models.DRAFT_STATUS}
+	testingProvider.Stores.Articles.Insert(&draftArticle)
+	disabledArticle := models.Article{Id: bson.NewObjectId(), UserId: disabled.Id, Title: "public", Content: "public", Visibility: models.PUBLIC_VISIBILITY}
+	testingProvider.Stores.Articles.Insert(&disabledArticle)
+	//not valid visibility
+	req := test.NewRequest(echo.POST, "/api/article", bytes.NewBufferString(`{"title":"title","content":"content","visibility":"friends"}`))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	context := echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+	context.Set(user.USER_ID_KEY, author.Id)
+	if err := articleController.CreateArticle(context); err != specialerror.ErrSomeFieldAreNotValid {
+		t.Errorf("Error should %q \t but get %q", specialerror.ErrSomeFieldAreNotValid, err)
+	}
+	otherUserId := bson.NewObjectId()
+	cases := []struct {
+		path          string
+		userId        bson.ObjectId
+		handler       echo.HandlerFunc
+		expectedError error
+	}{
+		{path: "/api/article/" + ids[models.PRIVATE_VISIBILITY], userId: author.Id, handler: articleController.GetArticleById},
+		{path: "/api/article/" + ids[models.PRIVATE_VISIBILITY], userId: otherUserId, handler: articleController.GetArticleById, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{path: "/api/article/" + ids[models.UNLISTED_VISIBILITY], userId: otherUserId, handler: articleController.GetArticleById},
+		{path: "/api/article/" + ids[models.PUBLIC_VISIBILITY], userId: otherUserId, handler: articleController.GetArticleById},
+		{path: "/public/article/" + ids[models.PRIVATE_VISIBILITY], handler: articleController.GetPublicArticleById, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{path: "/public/article/" + ids[models.UNLISTED_VISIBILITY], handler: articleController.GetPublicArticleById},
+		{path: "/public/article/" + ids[models.PUBLIC_VISIBILITY], handler: articleController.GetPublicArticleById},
+		{path: "/api/article/" + draftArticle.Id.Hex(), userId: otherUserId, handler: articleController.GetArticleById, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{path: "/public/article/" + draftArticle.Id.Hex(), handler: articleController.GetPublicArticleById, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{path: "/public/article/" + disabledArticle.Id.Hex(), handler: articleController.GetPublicArticleById, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{path: "/public/user/" + disabled.Id.Hex() + "/articles", handler: articleController.GetPublicArticlesOfUser, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{path: "/public/user/notvalidid/articles", handler: articleController.GetPublicArticlesOfUser, expectedError: specialerror.ErrNotValidItemId},
+	}
+	for _, c := range cases {
+		context := echo.NewContext(test.NewRequest(echo.GET, c.path, nil), test.NewResponseRecorder(), testingProvider.Echo)
+		testingProvider.Router.Find(echo.GET, c.path, context)
+		if c.userId != "" {
+			context.Set(user.USER_ID_KEY, c.userId)
+		}
+		if err := c.handler(context); err != c.expectedError {
+			t.Errorf("%s: Error should %q \t but get %q", c.path, c.expectedError, err)
+		}
+	}
+	//only the public articles are listed with the author display name
+	path := "/public/user/" + author.Id.Hex() + "/articles"
+	res := test.NewResponseRecorder()
+	context = echo.NewContext(test.NewRequest(echo.GET, path, nil), res, testingProvider.Echo)
+	testingProvider.Router.Find(echo.GET, path, context)
+	if err := articleController.GetPublicArticlesOfUser(context); err != nil {
+		t.Fatal(err)
+	}
+	result := []models.PublicArticle{}
+	if err := json.NewDecoder(res.Body).Decode(&models.Page{Items: &result}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Id.Hex() != ids[models.PUBLIC_VISIBILITY] || result[0].Author.DisplayName != author.DisplayName {
+		t.Errorf("Error should only the public article of %s \t but get %+v", author.DisplayName, result)
+	}
+}
+
+func TestArticleRevisions(t *testing.T) {
+	testingProvider.Router.Add(echo.PUT, "/api/article/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.GET, "/api/article/:id/revisions", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.GET, "/api/article/:id/revisions/diff", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.GET, "/api/article/:id/revisions/:rev", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/revisions/:rev/restore", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	request := func(method, path, body string, userId bson.ObjectId, handler echo.HandlerFunc) (*test.ResponseRecorder, error) {
+		req := test.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(method, path, context)
+		context.Set(user.USER_ID_KEY, userId)
+		return res, handler(context)
+	}
+	res, err := request(echo.POST, "/api/article", `{"title":"revisions","content":"first line\nsecond line"}`, userIdObj, articleController.CreateArticle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	article := models.Article{}
+	json.NewDecoder(res.Body).Decode(&article)
+	path := "/api/article/" + article.Id.Hex()
+	if _, err := request(echo.PUT, path, `{"title":"revisions","content":"first line\nchanged line"}`, userIdObj, articleController.UpdateArticleById); err != nil {
+		t.Fatal(err)
+	}
+	//restore the first revision
+	res, err = request(echo.POST, path+"/revisions/1/restore", "", userIdObj, articleController.RestoreArticleRevision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := models.ArticleRevision{}
+	json.NewDecoder(res.Body).Decode(&restored)
+	if restored.Revision != 3 || restored.RestoredFrom != 1 || restored.Content != "first line\nsecond line" {
+		t.Errorf("Error should revision 3 restored from 1 \t but get %+v", restored)
+	}
+	if a, _ := testingProvider.Stores.Articles.FindById(article.Id); a.Content != restored.Content {
+		t.Errorf("Error should %q \t but get %q", restored.Content, a.Content)
+	}
+	res, err = request(echo.GET, path+"/revisions", "", userIdObj, articleController.GetArticleRevisions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisions := []models.ArticleRevision{}
+	json.NewDecoder(res.Body).Decode(&models.Page{Items: &revisions})
+	if len(revisions) != 3 || revisions[0].Revision != 3 || revisions[2].Revision != 1 || revisions[1].EditorId != userIdObj {
+		t.Errorf("Error should 3 revisions newest first \t but get %+v", revisions)
+	}
+	res, err = request(echo.GET, path+"/revisions/diff?from=1&to=2", "", userIdObj, articleController.GetArticleRevisionsDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := models.ArticleRevisionDiff{}
+	json.NewDecoder(res.Body).Decode(&diff)
+	expectedContent := []models.DiffLine{
+		{Op: models.EQUAL_DIFF_OP, Text: "first line"},
+		{Op: models.DELETE_DIFF_OP, Text: "second line"},
+		{Op: models.INSERT_DIFF_OP, Text: "changed line"},
+	}
+	if fmt.Sprint(diff.Content) != fmt.Sprint(expectedContent) || len(diff.Title) != 1 || diff.Title[0].Op != models.EQUAL_DIFF_OP {
+		t.Errorf("Error should %v \t but get %+v", expectedContent, diff)
+	}
+	//errors
+	otherUserId := bson.NewObjectId()
+	cases := []struct {
+		method        string
+		path          string
+		userId        bson.ObjectId
+		handler       echo.HandlerFunc
+		expectedError error
+	}{
+		{echo.GET, path + "/revisions/2", userIdObj, articleController.GetArticleRevision, nil},
+		{echo.GET, path + "/revisions/4", userIdObj, articleController.GetArticleRevision, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.GET, path + "/revisions/first", userIdObj, articleController.GetArticleRevision, specialerror.ErrNotValidItemId},
+		{echo.GET, path + "/revisions/diff?from=1", userIdObj, articleController.GetArticleRevisionsDiff, specialerror.ErrNotValidQueryParameter},
+		{echo.GET, path + "/revisions", otherUserId, articleController.GetArticleRevisions, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.POST, path + "/revisions/1/restore", otherUserId, articleController.RestoreArticleRevision, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.GET, "/api/article/notvalidid/revisions", userIdObj, articleController.GetArticleRevisions, specialerror.ErrNotValidItemId},
+	}
+	for _, c := range cases {
+		if _, err := request(c.method, c.path, "", c.userId, c.handler); err != c.expectedError {
+			t.Errorf("%s: Error should %q \t but get %q", c.path, c.expectedError, err)
+		}
+	}
+}
+
+func TestRevisionOfArticleWithoutRevisions(t *testing.T) {
+	testingProvider.Router.Add(echo.PUT, "/api/article/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/revisions/:rev/restore", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	request := func(method, path, body string, handler echo.HandlerFunc) (*test.ResponseRecorder, error) {
+		req := test.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(method, path, context)
+		context.Set(user.USER_ID_KEY, userIdObj)
+		return res, handler(context)
+	}
+	//the article that created before revisions
+	updatedAt := time.Now().Add(-time.Hour)
+	article := models.Article{
+		Id:        bson.NewObjectId(),
+		Title:     "before revisions",
+		Content:   "original content",
+		UserId:    userIdObj,
+		CreatedAt: updatedAt,
+		UpdatedAt: updatedAt,
+	}
+	if err := testingProvider.Stores.Articles.Insert(&article); err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/article/" + article.Id.Hex()
+	if _, err := request(echo.PUT, path, `{"title":"before revisions","content":"changed content"}`, articleController.UpdateArticleById); err != nil {
+		t.Fatal(err)
+	}
+	revisions, _ := testingProvider.Stores.ArticleRevisions.FindByArticleId(article.Id)
+	if len(revisions) != 2 || revisions[1].Content != article.Content || !revisions[1].CreatedAt.Equal(updatedAt) {
+		t.Errorf("Error should the original content as the first revision \t but get %+v", revisions)
+	}
+	res, err := request(echo.POST, path+"/revisions/1/restore", "", articleController.RestoreArticleRevision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := models.ArticleRevision{}
+	json.NewDecoder(res.Body).Decode(&restored)
+	if restored.Revision != 3 || restored.RestoredFrom != 1 || restored.Content != article.Content {
+		t.Errorf("Error should revision 3 restored from 1 \t but get %+v", restored)
+	}
+	if a, _ := testingProvider.Stores.Articles.FindById(article.Id); a.Content != article.Content {
+		t.Errorf("Error should %q \t but get %q", article.Content, a.Content)
+	}
+}
+
+func TestArticleConditionalRequests(t *testing.T) {
+	testingProvider.Router.Add(echo.GET, "/api/article/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.PUT, "/api/article/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.DELETE, "/api/article/:id", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	article := models.Article{Id: bson.NewObjectId(), UserId: userIdObj, Title: "versioned", Content: "versioned article", Version: 1}
+	testingProvider.Stores.Articles.Insert(&article)
+	path := "/api/article/" + article.Id.Hex()
+	cases := []struct {
+		method         string
+		header         string
+		value          string
+		handler        echo.HandlerFunc
+		expectedStatus int
+		expectedError  error
+	}{
+		{echo.GET, "", "", articleController.GetArticleById, http.StatusOK, nil},
+		{echo.GET, "If-None-Match", `"1"`, articleController.GetArticleById, http.StatusNotModified, nil},
+		{echo.PUT, "If-Match", `"1"`, articleController.UpdateArticleById, http.StatusOK, nil},
+		//the version 1 is changed by previous update
+		{echo.PUT, "If-Match", `"1"`, articleController.UpdateArticleById, 0, specialerror.ErrPreconditionFailed},
+		{echo.PUT, "If-Match", `W/"2"`, articleController.UpdateArticleById, 0, specialerror.ErrPreconditionFailed},
+		{echo.GET, "If-None-Match", `"1"`, articleController.GetArticleById, http.StatusOK, nil},
+		{echo.DELETE, "If-Match", `"1"`, articleController.DeleteArticleById, 0, specialerror.ErrPreconditionFailed},
+		{echo.DELETE, "If-Match", `"2"`, articleController.DeleteArticleById, http.StatusOK, nil},
+	}
+	for _, c := range cases {
+		req := test.NewRequest(c.method, path, bytes.NewBufferString(`{"title":"versioned","content":"updated versioned article"}`))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		if c.header != "" {
+			req.Header().Set(c.header, c.value)
+		}
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(c.method, path, context)
+		context.Set(user.USER_ID_KEY, userIdObj)
+		if err := c.handler(context); err != c.expectedError {
+			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+		}
+		if c.expectedError == nil && res.Status() != c.expectedStatus {
+			t.Errorf("Error should %v \t but get %v", c.expectedStatus, res.Status())
+		}
+		if c.method == echo.GET && res.Header().Get("ETag") == "" {
+			t.Errorf("Error should have ETag header \t but get %v", res.Header())
+		}
+	}
+}
+
+func TestArticleStatus(t *testing.T) {
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/publish", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/unpublish", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/archive", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	req := test.NewRequest(echo.POST, "/api/article", bytes.NewBufferString(`{"title":"status","content":"the status of article","status":"published"}`))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	res := test.NewResponseRecorder()
+	context := echo.NewContext(req, res, testingProvider.Echo)
+	context.Set(user.USER_ID_KEY, userIdObj)
+	if err := articleController.CreateArticle(context); err != nil {
+		t.Fatal(err)
+	}
+	article := models.Article{}
+	json.NewDecoder(res.Body).Decode(&article)
+	if article.Status != models.DRAFT_STATUS {
+		t.Errorf("Error should %v \t but get %v", models.DRAFT_STATUS, article.Status)
+	}
+	scheduledFor := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	path := "/api/article/" + article.Id.Hex()
+	cases := []struct {
+		action         string
+		body           string
+		userId         bson.ObjectId
+		handler        echo.HandlerFunc
+		expectedStatus string
+		expectedError  error
+	}{
+		{"/publish", `{"scheduled_for":"` + scheduledFor.Format(time.RFC3339) + `"}`, userIdObj, articleController.PublishArticle, models.SCHEDULED_STATUS, nil},
+		{"/publish", "", bson.NewObjectId(), articleController.PublishArticle, "", specialerror.ErrCanNotAccessToTheseResource},
+		{"/archive", "", userIdObj, articleController.ArchiveArticle, models.ARCHIVED_STATUS, nil},
+		{"/unpublish", "", userIdObj, articleController.UnpublishArticle, "", specialerror.ErrArticleStatusCanNotChange},
+		{"/archive", "", userIdObj, articleController.ArchiveArticle, "", specialerror.ErrArticleStatusCanNotChange},
+		//the past schedule publish it now
+		{"/publish", `{"scheduled_for":"2016-01-01T00:00:00Z"}`, userIdObj, articleController.PublishArticle, models.PUBLISHED_STATUS, nil},
+		{"/publish", "", userIdObj, articleController.PublishArticle, "", specialerror.ErrArticleStatusCanNotChange},
+		{"/unpublish", "", userIdObj, articleController.UnpublishArticle, models.DRAFT_STATUS, nil},
+		{"/publish", `{"scheduled_for":"` + scheduledFor.Format(time.RFC3339) + `"}`, userIdObj, articleController.PublishArticle, models.SCHEDULED_STATUS, nil},
+	}
+	for _, c := range cases {
+		req := test.NewRequest(echo.POST, path+c.action, bytes.NewBufferString(c.body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(echo.POST, path+c.action, context)
+		context.Set(user.USER_ID_KEY, c.userId)
+		if err := c.handler(context); err != c.expectedError {
+			t.Errorf("%s: Error should %q \t but get %q", c.action, c.expectedError, err)
+		}
+		if c.expectedError != nil {
+			continue
+		}
+		result := models.Article{}
+		json.NewDecoder(res.Body).Decode(&result)
+		if result.Status != c.expectedStatus {
+			t.Errorf("%s: Error should %v \t but get %v", c.action, c.expectedStatus, result.Status)
+		}
+	}
+	//the scheduler publish it at the scheduled time
+	if n, err := articleController.PublishScheduledArticles(time.Now()); err != nil || n != 0 {
+		t.Errorf("Error should 0 \t but get %v %v", n, err)
+	}
+	if n, err := articleController.PublishScheduledArticles(scheduledFor.Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("Error should 1 \t but get %v %v", n, err)
+	}
+	if n, _ := articleController.PublishScheduledArticles(scheduledFor.Add(time.Second)); n != 0 {
+		t.Errorf("Error should not publish again \t but get %v", n)
+	}
+	a, _ := testingProvider.Stores.Articles.FindById(article.Id)
+	if a.Status != models.PUBLISHED_STATUS || a.PublishedAt == nil || !a.PublishedAt.Equal(scheduledFor) || a.ScheduledFor != nil {
+		t.Errorf("Error should published at %v \t but get %+v", scheduledFor, a)
+	}
+}
+
+func TestArticleTags(t *testing.T) {
+	articleController := NewArticleController(testingProvider.Stores)
+	//the other user so the tags of other tests are not counted
+	tagsUserId := bson.NewObjectId()
+	request := func(method, path, body string, handler echo.HandlerFunc) (*test.ResponseRecorder, error) {
+		req := test.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		context.Set(user.USER_ID_KEY, tagsUserId)
+		return res, handler(context)
+	}
+	cases := []struct {
+		tags          string
+		expectedTags  []string
+		expectedError error
+	}{
+		{`[" Go ", "REST  api", "go"]`, []string{"go", "rest-api"}, nil},
+		{`["golang", "rest-api"]`, []string{"golang", "rest-api"}, nil},
+		{`["mongodb"]`, []string{"mongodb"}, nil},
+		{`["not_valid!"]`, nil, specialerror.ErrSomeFieldAreNotValid},
+		{`["1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"]`, nil, specialerror.ErrSomeFieldAreNotValid},
+	}
+	for _, c := range cases {
+		res, err := request(echo.POST, "/api/article", `{"title":"tags","content":"the tags of article","tags":`+c.tags+`}`, articleController.CreateArticle)
+		if err != c.expectedError {
+			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+		}
+		if err != nil {
+			continue
+		}
+		article := models.Article{}
+		json.NewDecoder(res.Body).Decode(&article)
+		if fmt.Sprint(article.Tags) != fmt.Sprint(c.expectedTags) {
+			t.Errorf("Error should %v \t but get %v", c.expectedTags, article.Tags)
+		}
+	}
+	//filter by tag, the tag parameter normalized too
+	res, err := request(echo.GET, "/api/article?tag=REST-API", "", articleController.GetArticlesOfUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	articles := []models.Article{}
+	json.NewDecoder(res.Body).Decode(&models.Page{Items: &articles})
+	if len(articles) != 2 {
+		t.Errorf("Error should 2 articles with rest-api tag \t but get %v", len(articles))
+	}
+	if _, err := request(echo.GET, "/api/article?tag=not_valid!", "", articleController.GetArticlesOfUser); err != specialerror.ErrNotValidQueryParameter {
+		t.Errorf("Error should %q \t but get %q", specialerror.ErrNotValidQueryParameter, err)
+	}
+	tagCounts := func() string {
+		res, err := request(echo.GET, "/api/tags", "", articleController.GetTags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := []models.TagCount{}
+		json.NewDecoder(res.Body).Decode(&models.Page{Items: &counts})
+		return fmt.Sprint(counts)
+	}
+	if counts := tagCounts(); counts != "[{rest-api 2} {go 1} {golang 1} {mongodb 1}]" {
+		t.Errorf("Error should the counts of tags \t but get %v", counts)
+	}
+	//merge go and golang to golang
+	res, err = request(echo.POST, "/api/tags/rename", `{"from":["Go","golang"],"to":"golang"}`, articleController.RenameTags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := models.RenameTagsResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	if result.To != "golang" || result.Updated != 1 {
+		t.Errorf("Error should 1 updated article \t but get %+v", result)
+	}
+	//rename mongodb
+	if _, err := request(echo.POST, "/api/tags/rename", `{"from":["mongodb"],"to":"mongo"}`, articleController.RenameTags); err != nil {
+		t.Fatal(err)
+	}
+	if counts := tagCounts(); counts != "[{golang 2} {rest-api 2} {mongo 1}]" {
+		t.Errorf("Error should the counts of renamed tags \t but get %v", counts)
+	}
+	for _, body := range []string{`{"from":[],"to":"golang"}`, `{"from":["go"],"to":"not valid!"}`} {
+		if _, err := request(echo.POST, "/api/tags/rename", body, articleController.RenameTags); err != specialerror.ErrSomeFieldAreNotValid {
+			t.Errorf("Error should %q \t but get %q", specialerror.ErrSomeFieldAreNotValid, err)
+		}
+	}
+}
+
+func TestArticleComments(t *testing.T) {
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/comments", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.GET, "/api/article/:id/comments", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.PUT, "/api/article/:id/comments/:comment_id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.DELETE, "/api/article/:id/comments/:comment_id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/comments/:comment_id/hide", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	ownerId, commenterId, strangerId, adminId := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	article := models.Article{Id: bson.NewObjectId(), UserId: ownerId, Title: "comments", Content: "comments", Visibility: models.PUBLIC_VISIBILITY, Status: models.PUBLISHED_STATUS}
+	privateArticle := models.Article{Id: bson.NewObjectId(), UserId: ownerId, Title: "private", Content: "private", Visibility: models.PRIVATE_VISIBILITY, Status: models.PUBLISHED_STATUS}
+	testingProvider.Stores.Articles.Insert(&article)
+	testingProvider.Stores.Articles.Insert(&privateArticle)
+	path := "/api/article/" + article.Id.Hex() + "/comments"
+	request := func(method, path, body string, userId bson.ObjectId, handler echo.HandlerFunc) (*test.ResponseRecorder, error) {
+		req := test.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(method, path, context)
+		context.Set(user.USER_ID_KEY, userId)
+		roles := []string{models.USER_ROLE}
+		if userId == adminId {
+			roles = append(roles, models.ADMIN_ROLE)
+		}
+		context.Set(user.ROLES_KEY, roles)
+		context.Set(user.PERMISSIONS_KEY, testingProvider.Policy.Permissions(roles))
+		return res, handler(context)
+	}
+	commentCount := func() int {
+		a, _ := testingProvider.Stores.Articles.FindById(article.Id)
+		return a.CommentCount
+	}
+	res, err := request(echo.POST, path, `{"content":"first comment"}`, commenterId, articleController.CreateComment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := models.Comment{}
+	json.NewDecoder(res.Body).Decode(&comment)
+	res, err = request(echo.POST, path, `{"content":"first reply","parent_id":"`+comment.Id.Hex()+`"}`, ownerId, articleController.CreateComment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := models.Comment{}
+	json.NewDecoder(res.Body).Decode(&reply)
+	if reply.ParentId != comment.Id || commentCount() != 2 {
+		t.Errorf("Error should reply of %v and 2 comments \t but get %+v %v", comment.Id, reply, commentCount())
+	}
+	commentPath := path + "/" + comment.Id.Hex()
+	replyPath := path + "/" + reply.Id.Hex()
+	cases := []struct {
+		method        string
+		path          string
+		body          string
+		userId        bson.ObjectId
+		handler       echo.HandlerFunc
+		expectedError error
+	}{
+		//only one level of replies
+		{echo.POST, path, `{"content":"reply of reply","parent_id":"` + reply.Id.Hex() + `"}`, commenterId, articleController.CreateComment, specialerror.ErrCommentCanNotBeReplied},
+		{echo.POST, path, `{"content":""}`, commenterId, articleController.CreateComment, specialerror.ErrSomeFieldAreNotValid},
+		{echo.POST, "/api/article/" + privateArticle.Id.Hex() + "/comments", `{"content":"private"}`, strangerId, articleController.CreateComment, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.PUT, commentPath, `{"content":"changed by stranger"}`, strangerId, articleController.UpdateComment, specialerror.ErrCanNotAccessToTheseResource},
+		{echo.PUT, commentPath, `{"content":"changed by owner"}`, ownerId, articleController.UpdateComment, specialerror.ErrCanNotAccessToTheseResource},
+		{echo.PUT, commentPath, `{"content":"first comment edited"}`, commenterId, articleController.UpdateComment, nil},
+		{echo.DELETE, commentPath, "", strangerId, articleController.DeleteComment, specialerror.ErrCanNotAccessToTheseResource},
+		{echo.POST, replyPath + "/hide", "", commenterId, articleController.HideComment, specialerror.ErrCanNotAccessToTheseResource},
+		{echo.POST, replyPath + "/hide", "", ownerId, articleController.HideComment, nil},
+		//the hidden comment of others is not found
+		{echo.PUT, replyPath, `{"content":"changed"}`, strangerId, articleController.UpdateComment, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.DELETE, path + "/notvalidid", "", commenterId, articleController.DeleteComment, specialerror.ErrNotValidItemId},
+	}
+	for _, c := range cases {
+		if _, err := request(c.method, c.path, c.body, c.userId, c.handler); err != c.expectedError {
+			t.Errorf("%s %s: Error should %q \t but get %q", c.method, c.path, c.expectedError, err)
+		}
+	}
+	if commentCount() != 1 {
+		t.Errorf("Error should 1 visible comment \t but get %v", commentCount())
+	}
+	//the hidden reply only listed for the owner of article
+	for userId, expectedReplies := range map[bson.ObjectId]int{strangerId: 0, ownerId: 1, adminId: 1} {
+		res, err := request(echo.GET, path, "", userId, articleController.GetComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		threads := []models.CommentThread{}
+		json.NewDecoder(res.Body).Decode(&models.Page{Items: &threads})
+		if len(threads) != 1 || threads[0].Content != "first comment edited" || len(threads[0].Replies) != expectedReplies {
+			t.Errorf("Error should 1 comment with %d replies \t but get %+v", expectedReplies, threads)
+		}
+	}
+	//admin can delete the comment, the reply removed with it
+	if _, err := request(echo.DELETE, commentPath, "", adminId, articleController.DeleteComment); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testingProvider.Stores.Comments.FindById(reply.Id); err != store.ErrNotFound || commentCount() != 0 {
+		t.Errorf("Error should remove the reply and 0 comments \t but get %v %v", err, commentCount())
+	}
+}
+
+func TestArticleTrash(t *testing.T) {
+	testingProvider.Router.Add(echo.DELETE, "/api/article/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/restore", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.DELETE, "/api/article/trash/:id", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	//the other user so the articles of other tests are not listed
+	trashUserId, strangerId := bson.NewObjectId(), bson.NewObjectId()
+	article := models.Article{Id: bson.NewObjectId(), UserId: trashUserId, Title: "trash", Content: "trash", Tags: []string{"trash"}, Version: 1}
+	testingProvider.Stores.Articles.Insert(&article)
+	testingProvider.Stores.ArticleRevisions.Insert(models.NewArticleRevision(&article, trashUserId))
+	testingProvider.Stores.Comments.Insert(&models.Comment{Id: bson.NewObjectId(), ArticleId: article.Id, UserId: trashUserId, Content: "trash"})
+	id := article.Id.Hex()
+	request := func(method, path string, userId bson.ObjectId, handler echo.HandlerFunc) (*test.ResponseRecorder, error) {
+		req := test.NewRequest(method, path, nil)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(method, path, context)
+		context.Set(user.USER_ID_KEY, userId)
+		return res, handler(context)
+	}
+	trash := func() []models.Article {
+		res, err := request(echo.GET, "/api/article/trash", trashUserId, articleController.GetTrash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		articles := []models.Article{}
+		json.NewDecoder(res.Body).Decode(&models.Page{Items: &articles})
+		return articles
+	}
+	//the revisions and comments are in trash with their article
+	inTrash := func() bool {
+		revisions, _ := testingProvider.Stores.ArticleRevisions.FindByArticleId(article.Id)
+		comments, _ := testingProvider.Stores.Comments.FindPage(store.CommentFilter{ArticleId: article.Id, IncludeHidden: true}, store.PageRequest{Limit: 10, SortField: "created_at"})
+		return len(revisions) == 1 && revisions[0].DeletedAt != nil && comments.Items.([]models.Comment)[0].DeletedAt != nil
+	}
+	if _, err := request(echo.DELETE, "/api/article/"+id, trashUserId, articleController.DeleteArticleById); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testingProvider.Stores.Articles.FindById(article.Id); err != store.ErrNotFound {
+		t.Errorf("Error should %q \t but get %q", store.ErrNotFound, err)
+	}
+	page, _ := testingProvider.Stores.Articles.FindPage(store.ArticleFilter{UserId: trashUserId}, store.PageRequest{Limit: 10, SortField: "created_at"})
+	if articles := page.Items.([]models.Article); len(articles) != 0 {
+		t.Errorf("Error should no articles out of trash \t but get %v", len(articles))
+	}
+	if counts, _ := testingProvider.Stores.Articles.TagCounts(trashUserId); len(counts) != 0 {
+		t.Errorf("Error should no tags of articles in trash \t but get %v", counts)
+	}
+	if articles := trash(); len(articles) != 1 || articles[0].Id != article.Id || articles[0].DeletedAt == nil {
+		t.Errorf("Error should the deleted article in trash \t but get %v", articles)
+	}
+	if !inTrash() {
+		t.Error("Error should the revisions and comments in trash")
+	}
+	cases := []struct {
+		method        string
+		path          string
+		userId        bson.ObjectId
+		handler       echo.HandlerFunc
+		expectedError error
+	}{
+		{echo.DELETE, "/api/article/" + id, trashUserId, articleController.DeleteArticleById, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.POST, "/api/article/someinvalid/restore", trashUserId, articleController.RestoreArticle, specialerror.ErrNotValidItemId},
+		{echo.POST, "/api/article/" + id + "/restore", strangerId, articleController.RestoreArticle, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.POST, "/api/article/" + id + "/restore", trashUserId, articleController.RestoreArticle, nil},
+		//it's not in trash anymore
+		{echo.POST, "/api/article/" + id + "/restore", trashUserId, articleController.RestoreArticle, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.DELETE, "/api/article/trash/" + id, trashUserId, articleController.PurgeArticle, specialerror.ErrNotFoundAnyItemWithThisId},
+	}
+	for _, c := range cases {
+		if _, err := request(c.method, c.path, c.userId, c.handler); err != c.expectedError {
+			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+		}
+	}
+	restored, err := testingProvider.Stores.Articles.FindById(article.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 || inTrash() || len(trash()) != 0 {
+		t.Errorf("Error should the restored article \t but get %+v", restored)
+	}
+	//delete again and purge
+	if _, err := request(echo.DELETE, "/api/article/"+id, trashUserId, articleController.DeleteArticleById); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := request(echo.DELETE, "/api/article/trash/"+id, strangerId, articleController.PurgeArticle); err != specialerror.ErrNotFoundAnyItemWithThisId {
+		t.Errorf("Error should %q \t but get %q", specialerror.ErrNotFoundAnyItemWithThisId, err)
+	}
+	if _, err := request(echo.DELETE, "/api/article/trash/"+id, trashUserId, articleController.PurgeArticle); err != nil {
+		t.Fatal(err)
+	}
+	revisions, _ := testingProvider.Stores.ArticleRevisions.FindByArticleId(article.Id)
+	if len(trash()) != 0 || len(revisions) != 0 {
+		t.Errorf("Error should the purged article without revisions \t but get %v revisions", len(revisions))
+	}
+	if _, err := request(echo.POST, "/api/article/"+id+"/restore", trashUserId, articleController.RestoreArticle); err != specialerror.ErrNotFoundAnyItemWithThisId {
+		t.Errorf("Error should %q \t but get %q", specialerror.ErrNotFoundAnyItemWithThisId, err)
+	}
+}
+
+func TestArticleImportExport(t *testing.T) {
+	articleController := NewArticleController(testingProvider.Stores)
+	//the other users so the articles of other tests are not exported
+	importUserId, otherUserId := bson.NewObjectId(), bson.NewObjectId()
+	request := func(method, path string, body io.Reader, userId bson.ObjectId, handler echo.HandlerFunc) (*test.ResponseRecorder, error) {
+		req := test.NewRequest(method, path, body)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		context.Set(user.USER_ID_KEY, userId)
+		return res, handler(context)
+	}
+	importArticles := func(path string, body []byte, userId bson.ObjectId) models.ImportResult {
+		res, err := request(echo.POST, path, bytes.NewReader(body), userId, articleController.ImportArticles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := models.ImportResult{}
+		json.NewDecoder(res.Body).Decode(&result)
+		return result
+	}
+	ndjson := strings.Join([]string{
+		`{"external_id":"post-1","title":"first","content":"hello","tags":["Go"],"status":"published"}`,
+		`{"external_id":"post-2","title":"second","content":"world","visibility":"public"}`,
+		``,
+		`{"external_id":"post-3","title":"","content":"without title"}`,
+		`not json`,
+	}, "\n")
+	cases := []struct {
+		body          string
+		expected      string
+		expectedItems string
+	}{
+		{ndjson, "2 0 0 2", "line 1:created line 2:created line 4:failed:SOME_FIELDS_ARE_NOT_VALID line 5:failed:SOME_FIELDS_ARE_NOT_VALID"},
+		//the same file doesn't change anything
+		{ndjson, "0 0 2 2", "line 1:unchanged line 2:unchanged line 4:failed:SOME_FIELDS_ARE_NOT_VALID line 5:failed:SOME_FIELDS_ARE_NOT_VALID"},
+		{`{"external_id":"post-1","title":"first","content":"hello again","status":"archived"}`, "0 1 0 0", "line 1:updated"},
+	}
+	for _, c := range cases {
+		result := importArticles("/api/article/import", []byte(c.body), importUserId)
+		items := []string{}
+		for _, item := range result.Items {
+			items = append(items, strings.TrimSuffix(item.Item+":"+item.Status+":"+item.Error, ":"))
+		}
+		if counts := fmt.Sprint(result.Created, result.Updated, result.Unchanged, result.Failed); counts != c.expected || strings.Join(items, " ") != c.expectedItems {
+			t.Errorf("Error should %v %v \t but get %v %v", c.expected, c.expectedItems, counts, items)
+		}
+	}
+	updated, err := testingProvider.Stores.Articles.FindByExternalId(importUserId, "post-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisions, _ := testingProvider.Stores.ArticleRevisions.FindByArticleId(updated.Id)
+	if updated.Content != "hello again" || updated.Status != models.ARCHIVED_STATUS || len(updated.Tags) != 0 || updated.PublishedAt == nil || len(revisions) != 2 {
+		t.Errorf("Error should the updated article with 2 revisions \t but get %+v %v", updated, len(revisions))
+	}
+	//the article in trash keeps its external id until it's restored or purged
+	second, err := testingProvider.Stores.Articles.FindByExternalId(importUserId, "post-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testingProvider.Stores.Articles.TrashOwned(second.Id, importUserId, time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+	trashedRecord := `{"external_id":"post-2","title":"second","content":"world","visibility":"public"}`
+	if result := importArticles("/api/article/import", []byte(trashedRecord), importUserId); fmt.Sprint(result.Failed) != "1" || result.Items[0].Error != specialerror.ErrArticleIsInTrash.Message {
+		t.Errorf("Error should %v \t but get %+v", specialerror.ErrArticleIsInTrash.Message, result)
+	}
+	if err := testingProvider.Stores.Articles.RestoreOwned(second.Id, importUserId); err != nil {
+		t.Fatal(err)
+	}
+	if result := importArticles("/api/article/import", []byte(trashedRecord), importUserId); fmt.Sprint(result.Unchanged) != "1" {
+		t.Errorf("Error should the restored article unchanged \t but get %+v", result)
+	}
+	//the articles that are not imported found by their id
+	article := models.Article{Id: bson.NewObjectId(), UserId: importUserId, Title: "native", Content: "native", Visibility: models.PRIVATE_VISIBILITY, Status: models.DRAFT_STATUS, Version: 1, CreatedAt: time.Now()}
+	testingProvider.Stores.Articles.Insert(&article)
+	//export NDJSON
+	res, err := request(echo.GET, "/api/article/export", nil, importUserId, articleController.ExportArticles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, _ := ioutil.ReadAll(res.Body)
+	lines := strings.Split(strings.TrimSpace(string(exported)), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], `"external_id":"`+article.Id.Hex()+`"`) {
+		t.Errorf("Error should 3 records \t but get %s", exported)
+	}
+	if result := importArticles("/api/article/import", exported, importUserId); fmt.Sprint(result.Created, result.Updated, result.Unchanged, result.Failed) != "0 0 3 0" {
+		t.Errorf("Error should the exported records unchanged \t but get %+v", result)
+	}
+	//export markdown zip and import it for other user
+	res, err = request(echo.GET, "/api/article/export?format=markdown", nil, importUserId, articleController.ExportArticles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, _ := ioutil.ReadAll(res.Body)
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.File) != 3 || reader.File[0].Name != "post-1.md" {
+		t.Fatalf("Error should 3 markdown files \t but get %v", len(reader.File))
+	}
+	f, _ := reader.File[1].Open()
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	record := models.ArticleRecord{}
+	if body, err := frontmatter.Decode(data, &record); err != nil || body != "world" || record.ExternalId != "post-2" || record.Visibility != models.PUBLIC_VISIBILITY {
+		t.Errorf("Error should the markdown of post-2 \t but get %s", data)
+	}
+	for _, expected := range []string{"3 0 0 0", "0 0 3 0"} {
+		if result := importArticles("/api/article/import?format=markdown", archive, otherUserId); fmt.Sprint(result.Created, result.Updated, result.Unchanged, result.Failed) != expected {
+			t.Errorf("Error should %v \t but get %+v", expected, result)
+		}
+	}
+	if _, err := request(echo.GET, "/api/article/export?format=csv", nil, importUserId, articleController.ExportArticles); err != specialerror.ErrNotValidQueryParameter {
+		t.Errorf("Error should %q \t but get %q", specialerror.ErrNotValidQueryParameter, err)
+	}
+	if _, err := request(echo.POST, "/api/article/import?format=markdown", strings.NewReader("not zip"), importUserId, articleController.ImportArticles); err != specialerror.ErrImportFileIsNotValid {
+		t.Errorf("Error should %q \t but get %q", specialerror.ErrImportFileIsNotValid, err)
+	}
+}
+
+func TestCanAccessArticle(t *testing.T) {
+	ownerId, viewerId, editorId, strangerId := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	collaborators := []models.Collaborator{
+		{UserId: viewerId, Role: models.VIEWER_COLLABORATOR_ROLE},
+		{UserId: editorId, Role: models.EDITOR_COLLABORATOR_ROLE},
+	}
+	privateDraft := &models.Article{UserId: ownerId, Visibility: models.PRIVATE_VISIBILITY, Status: models.DRAFT_STATUS, Collaborators: collaborators}
+	publicPublished := &models.Article{UserId: ownerId, Visibility: models.PUBLIC_VISIBILITY, Status: models.PUBLISHED_STATUS, Collaborators: collaborators}
+	unlistedDraft := &models.Article{UserId: ownerId, Visibility: models.UNLISTED_VISIBILITY, Status: models.DRAFT_STATUS}
+	cases := []struct {
+		article  *models.Article
+		userId   bson.ObjectId
+		action   string
+		expected bool
+	}{
+		//the owner can do everything
+		{privateDraft, ownerId, READ_ARTICLE_ACTION, true},
+		{privateDraft, ownerId, UPDATE_ARTICLE_ACTION, true},
+		{privateDraft, ownerId, DELETE_ARTICLE_ACTION, true},
+		{privateDraft, ownerId, SHARE_ARTICLE_ACTION, true},
+		//the viewer can only read, even the private drafts
+		{privateDraft, viewerId, READ_ARTICLE_ACTION, true},
+		{privateDraft, viewerId, UPDATE_ARTICLE_ACTION, false},
+		{privateDraft, viewerId, DELETE_ARTICLE_ACTION, false},
+		{privateDraft, viewerId, SHARE_ARTICLE_ACTION, false},
+		//the editor can read and update but can't delete or reshare
+		{privateDraft, editorId, READ_ARTICLE_ACTION, true},
+		{privateDraft, editorId, UPDATE_ARTICLE_ACTION, true},
+		{privateDraft, editorId, DELETE_ARTICLE_ACTION, false},
+		{privateDraft, editorId, SHARE_ARTICLE_ACTION, false},
+		//the others only read the published articles that are not private
+		{privateDraft, strangerId, READ_ARTICLE_ACTION, false},
+		{unlistedDraft, strangerId, READ_ARTICLE_ACTION, false},
+		{publicPublished, strangerId, READ_ARTICLE_ACTION, true},
+		{publicPublished, strangerId, UPDATE_ARTICLE_ACTION, false},
+		{publicPublished, strangerId, DELETE_ARTICLE_ACTION, false},
+		{publicPublished, strangerId, SHARE_ARTICLE_ACTION, false},
+		//unknown action is not allowed except for owner
+		{publicPublished, editorId, "publish", false},
+		{publicPublished, ownerId, "publish", true},
+	}
+	for i, c := range cases {
+		if allowed := CanAccessArticle(c.article, c.userId, c.action); allowed != c.expected {
+			t.Errorf("case %d: %s should be %t \t but get %t", i, c.action, c.expected, allowed)
+		}
+	}
+}
+
+func TestArticleSharing(t *testing.T) {
+	testingProvider.Router.Add(echo.PUT, "/api/article/:id/collaborators", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.DELETE, "/api/article/:id/collaborators/:user_id", nil, testingProvider.Echo)
+	articleController := NewArticleController(testingProvider.Stores)
+	owner := &models.User{Id: bson.NewObjectId(), Email: "owner.share@gmail.com", DisplayName: "Owner", IsEnable: true}
+	viewer := &models.User{Id: bson.NewObjectId(), Email: "viewer.share@gmail.com", DisplayName: "Viewer", IsEnable: true}
+	editor := &models.User{Id: bson.NewObjectId(), Email: "editor.share@gmail.com", DisplayName: "Editor", IsEnable: true}
+	for _, u := range []*models.User{owner, viewer, editor} {
+		testingProvider.Stores.Users.Insert(u)
+	}
+	article := models.Article{Id: bson.NewObjectId(), UserId: owner.Id, Title: "shared", Content: "shared", Visibility: models.PRIVATE_VISIBILITY, Status: models.DRAFT_STATUS, Version: 1}
+	testingProvider.Stores.Articles.Insert(&article)
+	articlePath := "/api/article/" + article.Id.Hex()
+	request := func(method, path, body string, userId bson.ObjectId, handler echo.HandlerFunc) (*test.ResponseRecorder, error) {
+		req := test.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(method, path, context)
+		context.Set(user.USER_ID_KEY, userId)
+		return res, handler(context)
+	}
+	cases := []struct {
+		method        string
+		path          string
+		body          string
+		userId        bson.ObjectId
+		handler       echo.HandlerFunc
+		expectedError error
+	}{
+		//the private article is not found before sharing
+		{echo.GET, articlePath, "", viewer.Id, articleController.GetArticleById, specialerror.ErrNotFoundAnyItemWithThisId},
+		{echo.PUT, articlePath + "/collaborators", `{"email":"viewer.share@gmail.com","role":"owner"}`, owner.Id, articleController.ShareArticle, specialerror.ErrSomeFieldAreNotValid},
+		{echo.PUT, articlePath + "/collaborators", `{"email":"not.registered@gmail.com","role":"viewer"}`, owner.Id, articleController.ShareArticle, specialerror.ErrNotFoundAnyUserWithThisEmail},
+		{echo.PUT, articlePath + "/collaborators", `{"email":"owner.share@gmail.com","role":"viewer"}`, owner.Id, articleController.ShareArticle, specialerror.ErrCanNotShareWithOwner},
+		{echo.PUT, articlePath + "/collaborators", `{"email":"viewer.share@gmail.com","role":"editor"}`, owner.Id, articleController.ShareArticle, nil},
+		//change the role of collaborator
+		{echo.PUT, articlePath + "/collaborators", `{"email":"Viewer.Share@gmail.com","role":"viewer"}`, owner.Id, articleController.ShareArticle, nil},
+		{echo.PUT, articlePath + "/collaborators", `{"email":"editor.share@gmail.com","role":"editor"}`, owner.Id, articleController.ShareArticle, nil},
+		//the collaborators can't reshare
+		{echo.PUT, articlePath + "/collaborators", `{"email":"viewer.share@gmail.com","role":"editor"}`, editor.Id, articleController.ShareArticle, specialerror.ErrCanNotAccessToTheseResource},
+		{echo.DELETE, articlePath + "/collaborators/" + viewer.Id.Hex(), "", editor.Id, articleController.RemoveCollaborator, specialerror.ErrCanNotAccessToTheseResource},
+		//the viewer can read but can't update
+		{echo.GET, articlePath, "", viewer.Id, articleController.GetArticleById, nil},
+		{echo.PUT, articlePath, `{"title":"by viewer","content":"by viewer"}`, viewer.Id, articleController.UpdateArticleById, specialerror.ErrCanNotAccessToTheseResource},
+		//the editor can update but can't change the visibility or delete
+		{echo.PUT, articlePath, `{"title":"by editor","content":"by editor","visibility":"public"}`, editor.Id, articleController.UpdateArticleById, nil},
+		{echo.DELETE, articlePath, "", editor.Id, articleController.DeleteArticleById, specialerror.ErrCanNotAccessToTheseResource},
+		{echo.POST, articlePath + "/publish", "", editor.Id, articleController.PublishArticle, specialerror.ErrCanNotAccessToTheseResource},
+	}
+	testingProvider.Router.Add(echo.POST, "/api/article/:id/publish", nil, testingProvider.Echo)
+	for i, c := range cases {
+		if _, err := request(c.method, c.path, c.body, c.userId, c.handler); err != c.expectedError {
+			t.Errorf("case %d: Error should %v \t but get %v", i, c.expectedError, err)
+		}
+	}
+	a, _ := testingProvider.Stores.Articles.FindById(article.Id)
+	if a.Title != "by editor" || a.Visibility != models.PRIVATE_VISIBILITY || a.CollaboratorRole(viewer.Id) != models.VIEWER_COLLABORATOR_ROLE || len(a.Collaborators) != 2 {
+		t.Errorf("the article should be updated by editor and shared with two users \t but get %+v", a)
+	}
+	revisions, _ := testingProvider.Stores.ArticleRevisions.FindByArticleId(article.Id)
+	//the article inserted without revision, so its text before the first update kept as the first revision
+	if len(revisions) != 2 || revisions[0].EditorId != editor.Id || revisions[1].EditorId != owner.Id || revisions[1].Title != "shared" {
+		t.Errorf("the revision should be saved with editor after the revision of owner \t but get %+v", revisions)
+	}
+	//the shared with me view hides the collaborators
+	res, err := request(echo.GET, "/api/article?view=shared", "", viewer.Id, articleController.GetArticlesOfUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := struct {
+		Items []models.Article `json:"items"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Id != article.Id || page.Items[0].Collaborators != nil {
+		t.Errorf("shared view should have the shared article without collaborators \t but get %+v", page.Items)
+	}
+	if _, err := request(echo.GET, "/api/article?view=unknown", "", viewer.Id, articleController.GetArticlesOfUser); err != specialerror.ErrNotValidQueryParameter {
+		t.Errorf("Error should %v \t but get %v", specialerror.ErrNotValidQueryParameter, err)
+	}
+	//stop sharing
+	if _, err := request(echo.DELETE, articlePath+"/collaborators/"+viewer.Id.Hex(), "", owner.Id, articleController.RemoveCollaborator); err != nil {
+		t.Errorf("Error should %v \t but get %v", nil, err)
+	}
+	if _, err := request(echo.DELETE, articlePath+"/collaborators/"+viewer.Id.Hex(), "", owner.Id, articleController.RemoveCollaborator); err != specialerror.ErrNotFoundAnyItemWithThisId {
+		t.Errorf("Error should %v \t but get %v", specialerror.ErrNotFoundAnyItemWithThisId, err)
+	}
+	if _, err := request(echo.GET, articlePath, "", viewer.Id, articleController.GetArticleById); err != specialerror.ErrNotFoundAnyItemWithThisId {
+		t.Errorf("Error should %v \t but get %v", specialerror.ErrNotFoundAnyItemWithThisId, err)
+	}
+}
+
 func TestDeleteArticleById(t *testing.T) {
 	//since the path have id param should add it to routeer
 	testingProvider.Router.Add(echo.DELETE, "/api/article/:id", nil, testingProvider.Echo)
-	//get copy of db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	articleController := NewArticleController(session, testhelper.DB_TEST_NAME)
+	articleController := NewArticleController(testingProvider.Stores)
 	path := fmt.Sprintf("/api/article/%s", newArticleIdStr)
 	method := echo.DELETE
 	cases := []struct {
@@ -0,0 +1,237 @@
+package article
+
+import (
+	"net/http"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/pagination"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	COMMENT_ID_PARAM = "comment_id"
+)
+
+//the comments only sorted by time
+var commentSortFields = map[string]string{
+	"created": "created_at",
+}
+
+//comment on the article or reply to a top level comment of it
+func (ac ArticleController) CreateComment(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.findArticle(c, userId, READ_ARTICLE_ACTION)
+	if err != nil {
+		return err
+	}
+	request := models.CommentRequest{}
+	//the binder check if struct is not valid return err
+	if err := c.Bind(&request); err != nil {
+		return err
+	}
+	if request.ParentId != "" {
+		parent, err := ac.Comments.FindById(request.ParentId)
+		if err != nil && err != store.ErrNotFound {
+			return specialerror.ErrInternalServerError
+		}
+		//only one level of replies
+		if err == store.ErrNotFound || parent.ArticleId != article.Id || parent.ParentId != "" || parent.IsHidden {
+			return specialerror.ErrCommentCanNotBeReplied
+		}
+	}
+	comment := models.Comment{
+		Id:        bson.NewObjectId(),
+		ArticleId: article.Id,
+		ParentId:  request.ParentId,
+		UserId:    userId,
+		Content:   request.Content,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := ac.Comments.Insert(&comment); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Articles.IncCommentCount(article.Id, 1); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusCreated, comment)
+	return nil
+}
+
+//page of the top level comments with their replies, the hidden comments only listed for the owner of article and admins
+func (ac ArticleController) GetComments(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.findArticle(c, userId, READ_ARTICLE_ACTION)
+	if err != nil {
+		return err
+	}
+	page, err := pagination.ParsePageRequest(c, commentSortFields, "created")
+	if err != nil {
+		return err
+	}
+	filter := store.CommentFilter{
+		ArticleId:     article.Id,
+		ViewerId:      userId,
+		IncludeHidden: canModerate(c, article, userId),
+	}
+	result, err := ac.Comments.FindPage(filter, *page)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	comments := result.Items.([]models.Comment)
+	parentIds := []bson.ObjectId{}
+	for _, comment := range comments {
+		parentIds = append(parentIds, comment.Id)
+	}
+	replies, err := ac.Comments.FindReplies(parentIds, filter)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	threads := []models.CommentThread{}
+	for _, comment := range comments {
+		thread := models.CommentThread{Comment: comment, Replies: []models.Comment{}}
+		for _, reply := range replies {
+			if reply.ParentId == comment.Id {
+				thread.Replies = append(thread.Replies, reply)
+			}
+		}
+		threads = append(threads, thread)
+	}
+	result.Items = threads
+	c.JSON(http.StatusOK, result)
+	return nil
+}
+
+//only the author of comment can edit it
+func (ac ArticleController) UpdateComment(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	_, comment, err := ac.findComment(c, userId)
+	if err != nil {
+		return err
+	}
+	request := models.CommentRequest{}
+	//the binder check if struct is not valid return err
+	if err := c.Bind(&request); err != nil {
+		return err
+	}
+	if err := ac.Comments.UpdateContentOwned(comment.Id, userId, request.Content); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrCanNotAccessToTheseResource
+		}
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyUpdated)
+	return nil
+}
+
+//the author of comment, the owner of article and admins can delete the comment, the replies removed with it
+func (ac ArticleController) DeleteComment(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, comment, err := ac.findComment(c, userId)
+	if err != nil {
+		return err
+	}
+	if comment.UserId != userId && !canModerate(c, article, userId) {
+		return specialerror.ErrCanNotAccessToTheseResource
+	}
+	removed, err := ac.Comments.Remove(comment.Id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Articles.IncCommentCount(article.Id, -removed); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyRemoved)
+	return nil
+}
+
+func (ac ArticleController) HideComment(c echo.Context) error {
+	return ac.setCommentHidden(c, true)
+}
+
+func (ac ArticleController) UnhideComment(c echo.Context) error {
+	return ac.setCommentHidden(c, false)
+}
+
+//only the owner of article and admins can hide the comments
+func (ac ArticleController) setCommentHidden(c echo.Context, hidden bool) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, comment, err := ac.findComment(c, userId)
+	if err != nil {
+		return err
+	}
+	if !canModerate(c, article, userId) {
+		return specialerror.ErrCanNotAccessToTheseResource
+	}
+	if err := ac.Comments.SetHidden(comment.Id, hidden); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		//already hidden or shown
+		if err == store.ErrStatusConflict {
+			c.JSON(http.StatusOK, operationresult.SuccessfullyUpdated)
+			return nil
+		}
+		return specialerror.ErrInternalServerError
+	}
+	delta := 1
+	if hidden {
+		delta = -1
+	}
+	if err := ac.Articles.IncCommentCount(article.Id, delta); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyUpdated)
+	return nil
+}
+
+//the comment of comment_id parameter in the article of id parameter
+func (ac ArticleController) findComment(c echo.Context, userId bson.ObjectId) (*models.Article, *models.Comment, error) {
+	article, err := ac.findArticle(c, userId, READ_ARTICLE_ACTION)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bson.IsObjectIdHex(c.Param(COMMENT_ID_PARAM)) {
+		return nil, nil, specialerror.ErrNotValidItemId
+	}
+	comment, err := ac.Comments.FindById(bson.ObjectIdHex(c.Param(COMMENT_ID_PARAM)))
+	if err != nil && err != store.ErrNotFound {
+		return nil, nil, specialerror.ErrInternalServerError
+	}
+	//the hidden comments of others are not found
+	if err == store.ErrNotFound || comment.ArticleId != article.Id || (comment.IsHidden && comment.UserId != userId && !canModerate(c, article, userId)) {
+		return nil, nil, specialerror.ErrNotFoundAnyItemWithThisId
+	}
+	return article, comment, nil
+}
+
+//the owner of article and the moderators can hide or delete the comments
+func canModerate(c echo.Context, article *models.Article, userId bson.ObjectId) bool {
+	return article.UserId == userId || user.HasPermission(c, models.COMMENT_MODERATE_PERMISSION)
+}
@@ -0,0 +1,337 @@
+package article
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/frontmatter"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	FORMAT_PARAM = "format"
+	//max size of import file and the uncompressed markdown files in zip
+	MAX_IMPORT_SIZE = 10 << 20
+	MARKDOWN_FILE_EXT = ".md"
+	NDJSON_MIME = "application/x-ndjson"
+	ZIP_MIME = "application/zip"
+)
+
+//the characters that are not safe in file names of zip
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+//the record of import file or the error of reading it
+type importItem struct {
+	name   string
+	record *models.ArticleRecord
+	err    error
+}
+
+//stream the articles of user as NDJSON or zip of markdown files, the articles in trash are not exported
+func (ac ArticleController) ExportArticles(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	format, err := transferFormat(c)
+	if err != nil {
+		return err
+	}
+	filter := store.ArticleFilter{UserId: userId}
+	res := c.Response()
+	if format == models.NDJSON_FORMAT {
+		res.Header().Set(echo.HeaderContentType, NDJSON_MIME)
+		res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="articles.ndjson"`)
+		res.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(res)
+		err = ac.Articles.ForEach(filter, func(a *models.Article) error {
+			return encoder.Encode(models.NewArticleRecord(a))
+		})
+	} else {
+		res.Header().Set(echo.HeaderContentType, ZIP_MIME)
+		res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="articles.zip"`)
+		res.WriteHeader(http.StatusOK)
+		archive := zip.NewWriter(res)
+		names := map[string]bool{}
+		err = ac.Articles.ForEach(filter, func(a *models.Article) error {
+			record := models.NewArticleRecord(a)
+			w, err := archive.Create(markdownFileName(record.ExternalId, names))
+			if err != nil {
+				return err
+			}
+			return frontmatter.Encode(w, &record, record.Content)
+		})
+		if err == nil {
+			err = archive.Close()
+		}
+	}
+	//the response is committed, so the client only get the incomplete file
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	return nil
+}
+
+//create or update the articles of the records in the same format of export, each record has its own result
+func (ac ArticleController) ImportArticles(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	format, err := transferFormat(c)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(c.Request().Body(), MAX_IMPORT_SIZE+1))
+	if err != nil {
+		return specialerror.ErrImportFileIsNotValid
+	}
+	if len(data) > MAX_IMPORT_SIZE {
+		return specialerror.ErrImportIsTooLarge
+	}
+	var items []importItem
+	if format == models.NDJSON_FORMAT {
+		items = readNDJSON(data)
+	} else if items, err = readMarkdownZip(data); err != nil {
+		return err
+	}
+	result := models.ImportResult{Items: []models.ImportItemResult{}}
+	for _, item := range items {
+		itemResult := models.ImportItemResult{Item: item.name, Status: models.FAILED_IMPORT_STATUS}
+		err := item.err
+		if err == nil {
+			itemResult.ExternalId = item.record.ExternalId
+			itemResult.ArticleId, itemResult.Status, err = ac.importRecord(userId, item.record)
+		}
+		if err != nil {
+			itemResult.Status = models.FAILED_IMPORT_STATUS
+			if e, ok := err.(*specialerror.Error); ok {
+				itemResult.Error = e.Message
+			}
+		}
+		result.Add(itemResult)
+	}
+	c.JSON(http.StatusOK, &result)
+	return nil
+}
+
+//create the article of external id or update it if the record is not the same as article
+func (ac ArticleController) importRecord(userId bson.ObjectId, record *models.ArticleRecord) (bson.ObjectId, string, error) {
+	//the same validation of request body
+	if err := util.ValidateStruct(record); err != nil {
+		return "", "", err
+	}
+	if record.Visibility == "" {
+		record.Visibility = models.PRIVATE_VISIBILITY
+	}
+	if record.Status == "" {
+		record.Status = models.DRAFT_STATUS
+	}
+	article, err := ac.Articles.FindByExternalId(userId, record.ExternalId)
+	if err == store.ErrNotFound {
+		article = newImportedArticle(userId, record)
+		if err := ac.Articles.Insert(article); err != nil {
+			if err == store.ErrDuplicate {
+				//the article of this external id is imported meanwhile
+				return "", "", specialerror.ErrPreconditionFailed
+			}
+			return "", "", specialerror.ErrInternalServerError
+		}
+		if err := ac.Revisions.Insert(models.NewArticleRevision(article, userId)); err != nil {
+			return "", "", specialerror.ErrInternalServerError
+		}
+		return article.Id, models.CREATED_IMPORT_STATUS, nil
+	}
+	if err != nil {
+		return "", "", specialerror.ErrInternalServerError
+	}
+	//the external id is still taken by the article in trash
+	if article.DeletedAt != nil {
+		return "", "", specialerror.ErrArticleIsInTrash
+	}
+	status := article.Status
+	if article.IsPublished() {
+		status = models.PUBLISHED_STATUS
+	}
+	contentChanged := article.Title != record.Title || article.Content != record.Content
+	if !contentChanged && article.Visibility == record.Visibility && fmt.Sprint(article.Tags) == fmt.Sprint(record.Tags) && status == record.Status {
+		return article.Id, models.UNCHANGED_IMPORT_STATUS, nil
+	}
+	//the record has all of tags, so nil tags remove the tags of article
+	tags := record.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	update := &models.Article{Title: record.Title, Content: record.Content, Visibility: record.Visibility, Tags: tags}
+	if contentChanged {
+		if err := ac.ensureFirstRevision(article); err != nil {
+			return "", "", err
+		}
+	}
+	if err := ac.Articles.UpdateOwned(article.Id, userId, update, []int{article.Version}); err != nil {
+		if err == store.ErrVersionConflict {
+			return "", "", specialerror.ErrPreconditionFailed
+		}
+		return "", "", specialerror.ErrInternalServerError
+	}
+	if status != record.Status {
+		change := store.ArticleStatusChange{Status: record.Status, PublishedAt: article.PublishedAt}
+		if record.Status == models.DRAFT_STATUS {
+			change.PublishedAt = nil
+		} else if record.Status == models.PUBLISHED_STATUS && record.PublishedAt != nil {
+			change.PublishedAt = record.PublishedAt
+		} else if change.PublishedAt == nil {
+			now := time.Now()
+			change.PublishedAt = &now
+		}
+		if err := ac.Articles.ChangeStatusOwned(article.Id, userId, []string{status}, change); err != nil {
+			if err == store.ErrStatusConflict {
+				return "", "", specialerror.ErrArticleStatusCanNotChange
+			}
+			return "", "", specialerror.ErrInternalServerError
+		}
+	}
+	if contentChanged {
+		article.Title = record.Title
+		article.Content = record.Content
+		if err := ac.Revisions.Insert(models.NewArticleRevision(article, userId)); err != nil {
+			return "", "", specialerror.ErrInternalServerError
+		}
+	}
+	return article.Id, models.UPDATED_IMPORT_STATUS, nil
+}
+
+//the valid record as new article, the published articles without published_at are published now
+func newImportedArticle(userId bson.ObjectId, record *models.ArticleRecord) *models.Article {
+	now := time.Now()
+	article := &models.Article{
+		Id:          bson.NewObjectId(),
+		ExternalId:  record.ExternalId,
+		Title:       record.Title,
+		Content:     record.Content,
+		Visibility:  record.Visibility,
+		Tags:        record.Tags,
+		UserId:      userId,
+		Version:     1,
+		Status:      record.Status,
+		PublishedAt: record.PublishedAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if record.CreatedAt != nil {
+		article.CreatedAt = *record.CreatedAt
+	}
+	if article.Status == models.DRAFT_STATUS {
+		article.PublishedAt = nil
+	} else if article.PublishedAt == nil {
+		article.PublishedAt = &now
+	}
+	return article
+}
+
+//ndjson by default
+func transferFormat(c echo.Context) (string, error) {
+	switch format := c.QueryParam(FORMAT_PARAM); format {
+	case "", models.NDJSON_FORMAT:
+		return models.NDJSON_FORMAT, nil
+	case models.MARKDOWN_FORMAT:
+		return models.MARKDOWN_FORMAT, nil
+	}
+	return "", specialerror.ErrNotValidQueryParameter
+}
+
+//each line is a record, the empty lines are skipped
+func readNDJSON(data []byte) []importItem {
+	items := []importItem{}
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		item := importItem{name: fmt.Sprintf("line %d", i+1), record: &models.ArticleRecord{}}
+		if err := json.Unmarshal(line, item.record); err != nil {
+			item.err = specialerror.ErrSomeFieldAreNotValid
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+//each markdown file is a record, the other files are skipped
+func readMarkdownZip(data []byte) ([]importItem, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, specialerror.ErrImportFileIsNotValid
+	}
+	files := []*zip.File{}
+	size := uint64(0)
+	for _, file := range archive.File {
+		if !file.FileInfo().IsDir() && strings.ToLower(path.Ext(file.Name)) == MARKDOWN_FILE_EXT {
+			files = append(files, file)
+			size += file.UncompressedSize64
+		}
+	}
+	if size > MAX_IMPORT_SIZE {
+		return nil, specialerror.ErrImportIsTooLarge
+	}
+	items := []importItem{}
+	for _, file := range files {
+		item := importItem{name: file.Name, record: &models.ArticleRecord{}}
+		item.err = readMarkdownFile(file, item.record)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+//the file name is the external id when the front matter doesn't have it
+func readMarkdownFile(file *zip.File, record *models.ArticleRecord) error {
+	f, err := file.Open()
+	if err != nil {
+		return specialerror.ErrImportFileIsNotValid
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(f, MAX_IMPORT_SIZE))
+	if err != nil {
+		return specialerror.ErrImportFileIsNotValid
+	}
+	body, err := frontmatter.Decode(data, record)
+	if err != nil {
+		return specialerror.ErrSomeFieldAreNotValid
+	}
+	record.Content = body
+	if record.ExternalId == "" {
+		name := path.Base(file.Name)
+		record.ExternalId = strings.TrimSuffix(name, path.Ext(name))
+	}
+	return nil
+}
+
+//the safe and unique name of markdown file in zip
+func markdownFileName(externalId string, names map[string]bool) string {
+	base := strings.Trim(unsafeFileNameChars.ReplaceAllString(externalId, "-"), "-.")
+	if base == "" {
+		base = "article"
+	}
+	name := base + MARKDOWN_FILE_EXT
+	for i := 2; names[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, MARKDOWN_FILE_EXT)
+	}
+	names[name] = true
+	return name
+}
@@ -0,0 +1,87 @@
+package article
+
+import (
+	"net/http"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/pagination"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+//published public or unlisted article for anyone that has the id, it doesn't need authentication
+func (ac ArticleController) GetPublicArticleById(c echo.Context) error {
+	//first check is id valid or not
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return specialerror.ErrNotValidItemId
+	}
+	article, err := ac.Articles.FindById(bson.ObjectIdHex(c.Param("id")))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if !article.IsPublished() || (article.Visibility != models.PUBLIC_VISIBILITY && article.Visibility != models.UNLISTED_VISIBILITY) {
+		return specialerror.ErrNotFoundAnyItemWithThisId
+	}
+	author, err := ac.findAuthor(article.UserId)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, models.NewPublicArticle(article, *author))
+	return nil
+}
+
+//the page of published public articles of user, it doesn't need authentication
+func (ac ArticleController) GetPublicArticlesOfUser(c echo.Context) error {
+	//first check is id valid or not
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return specialerror.ErrNotValidItemId
+	}
+	author, err := ac.findAuthor(bson.ObjectIdHex(c.Param("id")))
+	if err != nil {
+		return err
+	}
+	page, err := pagination.ParsePageRequest(c, articleSortFields, "created")
+	if err != nil {
+		return err
+	}
+	filter := store.ArticleFilter{
+		UserId:      author.Id,
+		Visibility:  models.PUBLIC_VISIBILITY,
+		Status:      models.PUBLISHED_STATUS,
+		TitlePrefix: c.QueryParam(TITLE_PREFIX_PARAM),
+	}
+	result, err := ac.Articles.FindPage(filter, *page)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	articles := []models.PublicArticle{}
+	for _, article := range result.Items.([]models.Article) {
+		articles = append(articles, models.NewPublicArticle(&article, *author))
+	}
+	result.Items = articles
+	c.JSON(http.StatusOK, result)
+	return nil
+}
+
+//the articles of disabled users are not public
+func (ac ArticleController) findAuthor(userId bson.ObjectId) (*models.Author, error) {
+	u, err := ac.Users.FindById(userId)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	if !u.IsEnable {
+		return nil, specialerror.ErrNotFoundAnyItemWithThisId
+	}
+	author := models.NewAuthor(u)
+	return &author, nil
+}
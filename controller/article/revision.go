@@ -0,0 +1,157 @@
+package article
+
+import (
+	"net/http"
+	"strconv"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+	"github.com/atahani/golang-rest-api-sample/util/textdiff"
+)
+
+const (
+	REVISION_PARAM      = "rev"
+	FROM_REVISION_PARAM = "from"
+	TO_REVISION_PARAM   = "to"
+)
+
+//the revisions of article, newest first
+func (ac ArticleController) GetArticleRevisions(c echo.Context) error {
+	article, err := ac.findOwnedArticle(c)
+	if err != nil {
+		return err
+	}
+	revisions, err := ac.Revisions.FindByArticleId(article.Id)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, &models.Page{Items: revisions})
+	return nil
+}
+
+func (ac ArticleController) GetArticleRevision(c echo.Context) error {
+	article, err := ac.findOwnedArticle(c)
+	if err != nil {
+		return err
+	}
+	revision, err := ac.findRevision(article.Id, c.Param(REVISION_PARAM), specialerror.ErrNotValidItemId)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, revision)
+	return nil
+}
+
+//the line changes from the revision of from query parameter to the revision of to query parameter
+func (ac ArticleController) GetArticleRevisionsDiff(c echo.Context) error {
+	article, err := ac.findOwnedArticle(c)
+	if err != nil {
+		return err
+	}
+	from, err := ac.findRevision(article.Id, c.QueryParam(FROM_REVISION_PARAM), specialerror.ErrNotValidQueryParameter)
+	if err != nil {
+		return err
+	}
+	to, err := ac.findRevision(article.Id, c.QueryParam(TO_REVISION_PARAM), specialerror.ErrNotValidQueryParameter)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, &models.ArticleRevisionDiff{
+		ArticleId: article.Id,
+		From:      from.Revision,
+		To:        to.Revision,
+		Title:     textdiff.Lines(from.Title, to.Title),
+		Content:   textdiff.Lines(from.Content, to.Content),
+	})
+	return nil
+}
+
+//set the title and content of article to the revision, the restore saved as a new revision
+func (ac ArticleController) RestoreArticleRevision(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.findOwnedArticle(c)
+	if err != nil {
+		return err
+	}
+	revision, err := ac.findRevision(article.Id, c.Param(REVISION_PARAM), specialerror.ErrNotValidItemId)
+	if err != nil {
+		return err
+	}
+	article.Title = revision.Title
+	article.Content = revision.Content
+	//the visibility is not part of revisions
+	if err := ac.Articles.UpdateOwned(article.Id, userId, &models.Article{Title: article.Title, Content: article.Content}, nil); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	restored := models.NewArticleRevision(article, userId)
+	restored.RestoredFrom = revision.Revision
+	if err := ac.Revisions.Insert(restored); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, restored)
+	return nil
+}
+
+//the article of id parameter, the revisions of article only available to its owner
+func (ac ArticleController) findOwnedArticle(c echo.Context) (*models.Article, error) {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return nil, specialerror.ErrInternalServerError
+	}
+	article, err := ac.findArticle(c, userId, READ_ARTICLE_ACTION)
+	if err != nil {
+		return nil, err
+	}
+	if article.UserId != userId {
+		return nil, specialerror.ErrCanNotAccessToTheseResource
+	}
+	return article, nil
+}
+
+//the revision by its number, errNotValid returned when the number is not valid
+func (ac ArticleController) findRevision(articleId bson.ObjectId, number string, errNotValid error) (*models.ArticleRevision, error) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return nil, errNotValid
+	}
+	revision, err := ac.Revisions.FindByRevision(articleId, n)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	return revision, nil
+}
+
+//the articles that created before revisions don't have any revision, so their current text saved as the first revision before it's changed
+func (ac ArticleController) ensureFirstRevision(article *models.Article) error {
+	revisions, err := ac.Revisions.FindByArticleId(article.Id)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if len(revisions) != 0 {
+		return nil
+	}
+	first := models.NewArticleRevision(article, article.UserId)
+	//the text of article is written at its last update
+	if !article.UpdatedAt.IsZero() {
+		first.CreatedAt = article.UpdatedAt
+	}
+	if err := ac.Revisions.Insert(first); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	return nil
+}
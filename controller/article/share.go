@@ -0,0 +1,122 @@
+package article
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	COLLABORATOR_ID_PARAM = "user_id"
+)
+
+//the collaborators of article with their public information, only for the owner
+func (ac ArticleController) GetCollaborators(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.findArticle(c, userId, SHARE_ARTICLE_ACTION)
+	if err != nil {
+		return err
+	}
+	collaborators, err := ac.collaboratorDetails(article.Collaborators)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, collaborators)
+	return nil
+}
+
+//share the article with the user of email or change the role of collaborator, only the owner can share
+func (ac ArticleController) ShareArticle(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.findArticle(c, userId, SHARE_ARTICLE_ACTION)
+	if err != nil {
+		return err
+	}
+	shareRequest := models.ShareArticleRequest{}
+	//the binder check if struct is not valid return err
+	if err := c.Bind(&shareRequest); err != nil {
+		return err
+	}
+	collaborator, err := ac.Users.FindByEmail(strings.ToLower(shareRequest.Email))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyUserWithThisEmail
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if collaborator.Id == article.UserId {
+		return specialerror.ErrCanNotShareWithOwner
+	}
+	change := models.Collaborator{UserId: collaborator.Id, Role: shareRequest.Role, SharedAt: time.Now()}
+	if err := ac.Articles.SetCollaboratorOwned(article.Id, userId, change); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	article, err = ac.Articles.FindById(article.Id)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	collaborators, err := ac.collaboratorDetails(article.Collaborators)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, collaborators)
+	return nil
+}
+
+//stop sharing the article with the collaborator, only the owner can do it
+func (ac ArticleController) RemoveCollaborator(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.findArticle(c, userId, SHARE_ARTICLE_ACTION)
+	if err != nil {
+		return err
+	}
+	if !bson.IsObjectIdHex(c.Param(COLLABORATOR_ID_PARAM)) {
+		return specialerror.ErrNotValidItemId
+	}
+	if err := ac.Articles.RemoveCollaboratorOwned(article.Id, userId, bson.ObjectIdHex(c.Param(COLLABORATOR_ID_PARAM))); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyRemoved)
+	return nil
+}
+
+//the removed and disabled users are skipped
+func (ac ArticleController) collaboratorDetails(collaborators []models.Collaborator) ([]models.CollaboratorDetail, error) {
+	result := []models.CollaboratorDetail{}
+	for _, collaborator := range collaborators {
+		author, err := ac.findAuthor(collaborator.UserId)
+		if err == specialerror.ErrNotFoundAnyItemWithThisId {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, models.CollaboratorDetail{Author: *author, Role: collaborator.Role, SharedAt: collaborator.SharedAt})
+	}
+	return result, nil
+}
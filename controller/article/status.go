@@ -0,0 +1,94 @@
+package article
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+//publish the article now or schedule it for the scheduled_for of body
+func (ac ArticleController) PublishArticle(c echo.Context) error {
+	request := models.PublishArticleRequest{}
+	//the body is optional
+	if c.Request().ContentLength() != 0 {
+		if err := c.Bind(&request); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	change := store.ArticleStatusChange{Status: models.PUBLISHED_STATUS, PublishedAt: &now}
+	if request.ScheduledFor != nil && request.ScheduledFor.After(now) {
+		change = store.ArticleStatusChange{Status: models.SCHEDULED_STATUS, ScheduledFor: request.ScheduledFor}
+	}
+	//the scheduled article can be rescheduled or published now
+	return ac.changeStatus(c, []string{models.DRAFT_STATUS, models.SCHEDULED_STATUS, models.ARCHIVED_STATUS}, change)
+}
+
+//the published or scheduled article back to draft
+func (ac ArticleController) UnpublishArticle(c echo.Context) error {
+	return ac.changeStatus(c, []string{models.PUBLISHED_STATUS, models.SCHEDULED_STATUS}, store.ArticleStatusChange{Status: models.DRAFT_STATUS})
+}
+
+//the archived article is not visible to others, it can be published again
+func (ac ArticleController) ArchiveArticle(c echo.Context) error {
+	return ac.changeStatus(c, []string{models.DRAFT_STATUS, models.SCHEDULED_STATUS, models.PUBLISHED_STATUS}, store.ArticleStatusChange{Status: models.ARCHIVED_STATUS})
+}
+
+//change the status of article if its current status is one of from statuses and send the changed article
+func (ac ArticleController) changeStatus(c echo.Context, from []string, change store.ArticleStatusChange) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	//first check is id valid or not
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return specialerror.ErrNotValidItemId
+	}
+	id := bson.ObjectIdHex(c.Param("id"))
+	if err := ac.Articles.ChangeStatusOwned(id, userId, from, change); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrCanNotAccessToTheseResource
+		}
+		if err == store.ErrStatusConflict {
+			return specialerror.ErrArticleStatusCanNotChange
+		}
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.Articles.FindById(id)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, article)
+	return nil
+}
+
+//publish the scheduled articles that their time is come, it returns the number of published articles
+func (ac ArticleController) PublishScheduledArticles(now time.Time) (int, error) {
+	published, err := ac.Articles.PublishScheduled(now)
+	return len(published), err
+}
+
+//run the scheduler every interval until the stop closed, nil stop runs it forever
+func (ac ArticleController) RunScheduler(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if _, err := ac.PublishScheduledArticles(now); err != nil {
+				fmt.Printf("article scheduler: %s\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package article
+
+import (
+	"net/http"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+//the tags of articles of user with the number of articles, the most used first
+func (ac ArticleController) GetTags(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	result, err := ac.Articles.TagCounts(userId)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, &models.Page{Items: result})
+	return nil
+}
+
+//rename a tag or merge some tags to one tag in all of articles of user
+func (ac ArticleController) RenameTags(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	request := models.RenameTagsRequest{}
+	//the binder normalize the tags and check if struct is not valid return err
+	if err := c.Bind(&request); err != nil {
+		return err
+	}
+	updated, err := ac.Articles.RenameTags(userId, request.From, request.To)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, &models.RenameTagsResult{To: request.To, Updated: updated})
+	return nil
+}
@@ -0,0 +1,96 @@
+package article
+
+import (
+	"net/http"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/pagination"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+//sort query parameter of trash and its field
+var trashSortFields = map[string]string{
+	"deleted": "deleted_at",
+	"created": "created_at",
+	"title":   "title",
+}
+
+//the deleted articles of user that are not purged yet, the last deleted first
+func (ac ArticleController) GetTrash(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	page, err := pagination.ParsePageRequest(c, trashSortFields, "deleted")
+	if err != nil {
+		return err
+	}
+	result, err := ac.Articles.FindPage(store.ArticleFilter{UserId: userId, Trashed: true}, *page)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, result)
+	return nil
+}
+
+//bring back the article from trash with its revisions and comments
+func (ac ArticleController) RestoreArticle(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return specialerror.ErrNotValidItemId
+	}
+	articleId := bson.ObjectIdHex(c.Param("id"))
+	if err := ac.Articles.RestoreOwned(articleId, userId); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Revisions.SetDeletedAtByArticleId(articleId, nil); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Comments.SetDeletedAtByArticleId(articleId, nil); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	article, err := ac.Articles.FindById(articleId)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, article)
+	return nil
+}
+
+//remove the article in trash with its revisions and comments before the retention
+func (ac ArticleController) PurgeArticle(c echo.Context) error {
+	userId, ok := c.Get(user.USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return specialerror.ErrNotValidItemId
+	}
+	articleId := bson.ObjectIdHex(c.Param("id"))
+	if err := ac.Articles.PurgeOwned(articleId, userId); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Revisions.RemoveByArticleId(articleId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := ac.Comments.RemoveByArticleId(articleId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyRemoved)
+	return nil
+}
@@ -4,44 +4,48 @@ import (
 	"time"
 	"net/http"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 	"gopkg.in/mcuadros/go-defaults.v1"
 
 	"github.com/labstack/echo"
 
 	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
 	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/etag"
+	"github.com/atahani/golang-rest-api-sample/util/pagination"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 	"github.com/atahani/golang-rest-api-sample/util/operationresult"
 )
 
 const (
-	CLIENT_COLLECTION_NAME = "clients"
 	WEB_PLATFORM_TYPE = "web"
 )
 
+//sort query parameter of clients list and its field
+var clientSortFields = map[string]string{
+	"created": "created_at",
+	"updated": "updated_at",
+	"name":    "name",
+}
+
 type ClientController struct {
-	Session *mgo.Session
-	DBName  string
+	Clients store.ClientStore
 }
 
-func NewClientController(s *mgo.Session, dbName string) *ClientController {
-	return &ClientController{s, dbName}
+func NewClientController(clients store.ClientStore) *ClientController {
+	return &ClientController{clients}
 }
 
-func (cc ClientController) ClientAuthorization(s *mgo.Session, dbName, appId, appKey string) (bool, error) {
-	//get copy of db session
-	session := s.Copy()
-	defer session.Close()
+func (cc ClientController) ClientAuthorization(appId, appKey string) (bool, error) {
 	//check the client id format
 	if !bson.IsObjectIdHex(appId) {
 		return false, specialerror.ErrNotValidClientInformation
 	}
 	//find client with this appId
-	client := models.Client{}
-	if err := session.DB(cc.DBName).C(CLIENT_COLLECTION_NAME).FindId(bson.ObjectIdHex(appId)).One(&client); err != nil {
-		if err == mgo.ErrNotFound {
+	client, err := cc.Clients.FindById(bson.ObjectIdHex(appId))
+	if err != nil {
+		if err == store.ErrNotFound {
 			return false, specialerror.ErrClientIsNotValidToCommunicate
 		}
 		return false, specialerror.ErrInternalServerError
@@ -62,9 +66,6 @@ func (cc ClientController) ClientAuthorization(s *mgo.Session, dbName, appId, ap
 }
 
 func (cc ClientController) CreateNewClient(c echo.Context) error {
-	//get copy of db session
-	session := cc.Session.Copy()
-	defer session.Close()
 	appKey := util.NewAppKey()
 	client := models.Client{
 		AppId: bson.NewObjectId(),
@@ -77,8 +78,9 @@ func (cc ClientController) CreateNewClient(c echo.Context) error {
 	if err := c.Bind(&client); err != nil {
 		return err
 	}
+	client.Version = 1
 	//save the client to DB
-	if err := session.DB(cc.DBName).C(CLIENT_COLLECTION_NAME).Insert(&client); err != nil {
+	if err := cc.Clients.Insert(&client); err != nil {
 		return specialerror.ErrInternalServerError
 	}
 	//replace the hashed App Key
@@ -98,21 +100,15 @@ func (cc ClientController) UpdateClientById(c echo.Context) error {
 	if err := c.Bind(&updatedClient); err != nil {
 		return err
 	}
-	//get copy of session
-	session := cc.Session.Copy()
-	defer session.Close()
-	clientUpdateSet := bson.M{
-		"name":updatedClient.Name,
-		"description":updatedClient.Description,
-		"is_enable":updatedClient.IsEnable,
-		"platform_type": updatedClient.PlatformType,
-		"updated_at": time.Now(),
-	}
+	versions := etag.IfMatchVersions(c.Request().Header().Get(etag.IF_MATCH_HEADER))
 	//update the client information by one query
-	if err := session.DB(cc.DBName).C(CLIENT_COLLECTION_NAME).UpdateId(bson.ObjectIdHex(c.Param("id")), clientUpdateSet); err != nil {
-		if err == mgo.ErrNotFound {
+	if err := cc.Clients.Update(bson.ObjectIdHex(c.Param("id")), &updatedClient, versions); err != nil {
+		if err == store.ErrNotFound {
 			return specialerror.ErrNotFoundAnyItemWithThisId
 		}
+		if err == store.ErrVersionConflict {
+			return specialerror.ErrPreconditionFailed
+		}
 		return specialerror.ErrInternalServerError
 	}
 	//inform the item successfully updated
@@ -125,16 +121,19 @@ func (cc ClientController) GetClientById(c echo.Context) error {
 	if !bson.IsObjectIdHex(c.Param("id")) {
 		return specialerror.ErrNotValidItemId
 	}
-	//get copy of session
-	session := cc.Session.Copy()
-	defer session.Close()
-	client := models.Client{}
-	if err := session.DB(cc.DBName).C(CLIENT_COLLECTION_NAME).FindId(bson.ObjectIdHex(c.Param("id"))).One(&client); err != nil {
-		if err == mgo.ErrNotFound {
+	client, err := cc.Clients.FindById(bson.ObjectIdHex(c.Param("id")))
+	if err != nil {
+		if err == store.ErrNotFound {
 			return specialerror.ErrNotFoundAnyItemWithThisId
 		}
 		return specialerror.ErrInternalServerError
 	}
+	//the client can send the ETag in If-Match header of update and delete
+	tag := etag.Version(client.Version)
+	c.Response().Header().Set(etag.ETAG_HEADER, tag)
+	if etag.Match(c.Request().Header().Get(etag.IF_NONE_MATCH_HEADER), tag) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	//replace the hashed app key
 	client.HashedAppKey()
 	c.JSON(http.StatusOK, client)
@@ -142,17 +141,19 @@ func (cc ClientController) GetClientById(c echo.Context) error {
 }
 
 func (cc ClientController) GetClients(c echo.Context) error {
-	//get copy of session
-	session := cc.Session.Copy()
-	defer session.Close()
-	result := [] models.Client{}
+	page, err := pagination.ParsePageRequest(c, clientSortFields, "created")
+	if err != nil {
+		return err
+	}
 	//get client from database
-	if err := session.DB(cc.DBName).C(CLIENT_COLLECTION_NAME).Find(nil).All(&result); err != nil {
+	result, err := cc.Clients.FindPage(*page)
+	if err != nil {
 		return specialerror.ErrInternalServerError
 	}
 	//should replace the hashed app key
-	for i, cli := range result {
-		result[i].AppKey = cli.HashedAppKey()
+	clients := result.Items.([]models.Client)
+	for i, cli := range clients {
+		clients[i].AppKey = cli.HashedAppKey()
 	}
 	c.JSON(http.StatusOK, result)
 	return nil
@@ -163,13 +164,14 @@ func (cc ClientController) DeleteClientById(c echo.Context) error {
 	if !bson.IsObjectIdHex(c.Param("id")) {
 		return specialerror.ErrNotValidItemId
 	}
-	//get copy of session
-	session := cc.Session.Copy()
-	defer session.Close()
-	if err := session.DB(cc.DBName).C(CLIENT_COLLECTION_NAME).RemoveId(bson.ObjectIdHex(c.Param("id"))); err != nil {
-		if err == mgo.ErrNotFound {
+	versions := etag.IfMatchVersions(c.Request().Header().Get(etag.IF_MATCH_HEADER))
+	if err := cc.Clients.Remove(bson.ObjectIdHex(c.Param("id")), versions); err != nil {
+		if err == store.ErrNotFound {
 			return specialerror.ErrNotFoundAnyItemWithThisId
 		}
+		if err == store.ErrVersionConflict {
+			return specialerror.ErrPreconditionFailed
+		}
 		return specialerror.ErrInternalServerError
 	}
 	//inform this item successfully removed
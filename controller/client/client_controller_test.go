@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"testing"
 	"encoding/json"
+	"net/http"
+
+	"gopkg.in/mgo.v2/bson"
 
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/engine"
@@ -21,10 +24,7 @@ var testingProvider testhelper.TestingProvider
 var newAppIdStrForClient string
 
 func TestCreateNewClient(t *testing.T) {
-	//get copy of session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	clientController := NewClientController(session, testhelper.DB_TEST_NAME)
+	clientController := NewClientController(testingProvider.Stores.Clients)
 	//define different case
 	path := "/api/manage/client"
 	method := echo.POST
@@ -33,6 +33,26 @@ func TestCreateNewClient(t *testing.T) {
 		res           *test.ResponseRecorder
 		expectedError error
 	}{
+		{
+			req:           test.NewRequest(method, path, bytes.NewBuffer([]byte(`{"name":"integration","platform_type":"server","redirect_uris":["https://app.example.com/callback","com.example.app:/oauth"],"allowed_scopes":["articles:read"]}`))),
+			res:           test.NewResponseRecorder(),
+			expectedError: nil,
+		},
+		{
+			req:           test.NewRequest(method, path, bytes.NewBuffer([]byte(`{"name":"integration","redirect_uris":["/callback"]}`))),
+			res:           test.NewResponseRecorder(),
+			expectedError: specialerror.ErrSomeFieldAreNotValid,
+		},
+		{
+			req:           test.NewRequest(method, path, bytes.NewBuffer([]byte(`{"name":"integration","redirect_uris":["https://app.example.com/callback#token"]}`))),
+			res:           test.NewResponseRecorder(),
+			expectedError: specialerror.ErrSomeFieldAreNotValid,
+		},
+		{
+			req:           test.NewRequest(method, path, bytes.NewBuffer([]byte(`{"name":"integration","allowed_scopes":["articles:delete"]}`))),
+			res:           test.NewResponseRecorder(),
+			expectedError: specialerror.ErrSomeFieldAreNotValid,
+		},
 		{
 			req:           test.NewRequest(method, path, bytes.NewBuffer([]byte(`{"name":"client for web"}`))),
 			res:           test.NewResponseRecorder(),
@@ -63,10 +83,7 @@ func TestCreateNewClient(t *testing.T) {
 func TestGetClientById(t *testing.T) {
 	//since the path have id param should add it to Router
 	testingProvider.Router.Add(echo.GET, "/api/manage/client/:id", nil, testingProvider.Echo)
-	//get session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	clientController := NewClientController(session, testhelper.DB_TEST_NAME)
+	clientController := NewClientController(testingProvider.Stores.Clients)
 	//define different cases
 	path := fmt.Sprintf("/api/manage/client/%s", newAppIdStrForClient)
 	method := echo.GET
@@ -102,10 +119,7 @@ func TestGetClientById(t *testing.T) {
 func TestUpdateClientById(t *testing.T) {
 	//since the path have id param should add it to Router
 	testingProvider.Router.Add(echo.PUT, "/api/manage/client/:id", nil, testingProvider.Echo)
-	//get session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	clientController := NewClientController(session, testhelper.DB_TEST_NAME)
+	clientController := NewClientController(testingProvider.Stores.Clients)
 	//define different case
 	path := fmt.Sprintf("/api/manage/client/%s", newAppIdStrForClient)
 	method := echo.PUT
@@ -150,34 +164,104 @@ func TestUpdateClientById(t *testing.T) {
 	}
 }
 
+func TestUpdateClientAllowedScopes(t *testing.T) {
+	testingProvider.Router.Add(echo.PUT, "/api/manage/client/:id", nil, testingProvider.Echo)
+	clientController := NewClientController(testingProvider.Stores.Clients)
+	client := models.Client{AppId: bson.NewObjectId(), Name: "third party client", AllowedScopes: []string{models.ARTICLES_READ_SCOPE}}
+	testingProvider.Stores.Clients.Insert(&client)
+	path := fmt.Sprintf("/api/manage/client/%s", client.AppId.Hex())
+	cases := []struct {
+		body           string
+		expectedScopes string
+	}{
+		//the client without allowed scopes has all of them, so they're kept when they're not in the request
+		{`{"name":"updated third party client"}`, "[articles:read]"},
+		{`{"name":"updated third party client","allowed_scopes":["articles:read","comments:write"]}`, "[articles:read comments:write]"},
+		{`{"name":"updated third party client","description":"without allowed scopes"}`, "[articles:read comments:write]"},
+	}
+	for _, c := range cases {
+		req := test.NewRequest(echo.PUT, path, bytes.NewBufferString(c.body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		context := echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+		testingProvider.Router.Find(echo.PUT, path, context)
+		if err := clientController.UpdateClientById(context); err != nil {
+			t.Errorf("Error should %v \t but get %q", nil, err)
+		}
+		updated, err := testingProvider.Stores.Clients.FindById(client.AppId)
+		if err != nil {
+			t.Fatalf("Error should %v \t but get %v", nil, err)
+		}
+		if fmt.Sprint(updated.AllowedScopes) != c.expectedScopes {
+			t.Errorf("Error should %v \t but get %v", c.expectedScopes, updated.AllowedScopes)
+		}
+	}
+}
+
 func TestGetClients(t *testing.T) {
-	//get session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	clientController := NewClientController(session, testhelper.DB_TEST_NAME)
+	clientController := NewClientController(testingProvider.Stores.Clients)
 	path := "/api/manage/client"
 	req := test.NewRequest(echo.GET, path, nil)
 	res := test.NewResponseRecorder()
 	context := echo.NewContext(req, res, testingProvider.Echo)
 	if err := clientController.GetClients(context); err != nil {
-		t.Errorf("Error should %q \t but get %q", nil, err)
+		t.Errorf("Error should %v \t but get %q", nil, err)
 	}
 	//check the number of clients
 	result := []models.Client{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err == nil {
+	if err := json.NewDecoder(res.Body).Decode(&models.Page{Items: &result}); err == nil {
 		if len(result) == 0 {
 			t.Error("should at least one client in this get clients request !")
 		}
 	}
 }
 
+func TestClientConditionalRequests(t *testing.T) {
+	testingProvider.Router.Add(echo.GET, "/api/manage/client/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.PUT, "/api/manage/client/:id", nil, testingProvider.Echo)
+	testingProvider.Router.Add(echo.DELETE, "/api/manage/client/:id", nil, testingProvider.Echo)
+	clientController := NewClientController(testingProvider.Stores.Clients)
+	client := models.Client{AppId: bson.NewObjectId(), Name: "versioned client", Version: 1}
+	testingProvider.Stores.Clients.Insert(&client)
+	path := fmt.Sprintf("/api/manage/client/%s", client.AppId.Hex())
+	cases := []struct {
+		method         string
+		header         string
+		value          string
+		handler        echo.HandlerFunc
+		expectedStatus int
+		expectedError  error
+	}{
+		{echo.GET, "", "", clientController.GetClientById, http.StatusOK, nil},
+		{echo.GET, "If-None-Match", `"1"`, clientController.GetClientById, http.StatusNotModified, nil},
+		{echo.PUT, "If-Match", `"1"`, clientController.UpdateClientById, http.StatusOK, nil},
+		//the version 1 is changed by previous update
+		{echo.PUT, "If-Match", `"1"`, clientController.UpdateClientById, 0, specialerror.ErrPreconditionFailed},
+		{echo.GET, "If-None-Match", `"1"`, clientController.GetClientById, http.StatusOK, nil},
+		{echo.DELETE, "If-Match", `"1"`, clientController.DeleteClientById, 0, specialerror.ErrPreconditionFailed},
+		{echo.DELETE, "If-Match", `"2"`, clientController.DeleteClientById, http.StatusOK, nil},
+	}
+	for _, c := range cases {
+		req := test.NewRequest(c.method, path, bytes.NewBufferString(`{"name":"updated versioned client"}`))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		if c.header != "" {
+			req.Header().Set(c.header, c.value)
+		}
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(c.method, path, context)
+		if err := c.handler(context); err != c.expectedError {
+			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+		}
+		if c.expectedError == nil && res.Status() != c.expectedStatus {
+			t.Errorf("Error should %v \t but get %v", c.expectedStatus, res.Status())
+		}
+	}
+}
+
 func TestDeleteClientById(t *testing.T) {
 	//since the URL have id param should add it to Router
 	testingProvider.Router.Add(echo.DELETE, "/api/manage/client/:id", nil, testingProvider.Echo)
-	//copy db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	clientController := NewClientController(session, testhelper.DB_TEST_NAME)
+	clientController := NewClientController(testingProvider.Stores.Clients)
 	//define different cases
 	path := fmt.Sprintf("/api/manage/client/%s", newAppIdStrForClient)
 	method := echo.DELETE
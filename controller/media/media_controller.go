@@ -0,0 +1,58 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+	"github.com/atahani/golang-rest-api-sample/util/blobstorage"
+	"github.com/atahani/golang-rest-api-sample/util/etag"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	//name of the wildcard parameter in echo router
+	KEY_PARAM = "_*"
+)
+
+type MediaController struct {
+	Storage     blobstorage.Storage
+	CacheMaxAge time.Duration
+}
+
+func NewMediaController(storage blobstorage.Storage, cfg config.MediaConfig) *MediaController {
+	return &MediaController{storage, cfg.CacheMaxAge.Duration}
+}
+
+//serve the file of blob storage such as the profile images
+func (mc MediaController) GetMedia(c echo.Context) error {
+	key := c.Param(KEY_PARAM)
+	r, obj, err := mc.Storage.Get(key)
+	if err != nil {
+		if err == blobstorage.ErrNotFound || err == blobstorage.ErrNotValidKey {
+			return specialerror.ErrNotFound
+		}
+		return specialerror.ErrInternalServerError
+	}
+	defer r.Close()
+	header := c.Response().Header()
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(mc.CacheMaxAge.Seconds())))
+	if obj.ETag != "" {
+		header.Set(etag.ETAG_HEADER, obj.ETag)
+		if etag.Match(c.Request().Header().Get(etag.IF_NONE_MATCH_HEADER), obj.ETag) {
+			return c.NoContent(http.StatusNotModified)
+		}
+	}
+	header.Set(echo.HeaderContentType, obj.ContentType)
+	//the files are small images, so read them to serve range requests
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	return c.ServeContent(bytes.NewReader(content), key, obj.ModTime)
+}
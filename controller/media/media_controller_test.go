@@ -0,0 +1,77 @@
+package media
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/test"
+
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+	"github.com/atahani/golang-rest-api-sample/util/testhelper"
+)
+
+var testingProvider testhelper.TestingProvider
+
+func TestGetMedia(t *testing.T) {
+	testingProvider.Router.Add(echo.GET, "/media/*", nil, testingProvider.Echo)
+	mediaController := NewMediaController(testingProvider.Storage, testingProvider.Config.Media)
+	if err := testingProvider.Storage.Put("profile/57e4/image.jpeg", []byte("image content"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	_, obj, _ := testingProvider.Storage.Get("profile/57e4/image.jpeg")
+	cases := []struct {
+		path           string
+		ifNoneMatch    string
+		expectedStatus int
+		expectedError  error
+	}{
+		{
+			path:           "/media/profile/57e4/image.jpeg",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			path:           "/media/profile/57e4/image.jpeg",
+			ifNoneMatch:    obj.ETag,
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			path:          "/media/profile/57e4/other.jpeg",
+			expectedError: specialerror.ErrNotFound,
+		},
+		{
+			path:          "/media/profile/../../secret",
+			expectedError: specialerror.ErrNotFound,
+		},
+	}
+	for _, c := range cases {
+		req := test.NewRequest(echo.GET, c.path, nil)
+		if c.ifNoneMatch != "" {
+			req.Header().Set("If-None-Match", c.ifNoneMatch)
+		}
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		testingProvider.Router.Find(echo.GET, c.path, context)
+		if err := mediaController.GetMedia(context); err != c.expectedError {
+			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+		}
+		if c.expectedError != nil {
+			continue
+		}
+		if res.Status() != c.expectedStatus {
+			t.Errorf("Error should %v \t but get %v", c.expectedStatus, res.Status())
+		}
+		if res.Header().Get("Cache-Control") != "public, max-age=2592000" || res.Header().Get("ETag") != obj.ETag {
+			t.Errorf("the caching headers are not valid %v", res.Header())
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	//start of testing
+	testingProvider = testhelper.TestingProvider{}
+	testingProvider.StartTesting()
+	ret := m.Run()
+	os.Exit(ret)
+}
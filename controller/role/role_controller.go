@@ -0,0 +1,155 @@
+package role
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/permission"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+//manage the role definitions, the policy of this instance reloaded after each change
+type RoleController struct {
+	Roles  store.RoleStore
+	Users  store.UserStore
+	Policy *permission.Policy
+}
+
+func NewRoleController(roles store.RoleStore, users store.UserStore, policy *permission.Policy) *RoleController {
+	return &RoleController{roles, users, policy}
+}
+
+//all of permissions that can be given to roles
+func (rc RoleController) GetPermissions(c echo.Context) error {
+	c.JSON(http.StatusOK, append([]string{models.ALL_PERMISSIONS}, models.Permissions...))
+	return nil
+}
+
+func (rc RoleController) GetRoles(c echo.Context) error {
+	roles, err := rc.Roles.FindAll()
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, roles)
+	return nil
+}
+
+func (rc RoleController) GetRoleByName(c echo.Context) error {
+	role, err := rc.Roles.FindByName(c.Param("name"))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, role)
+	return nil
+}
+
+func (rc RoleController) CreateRole(c echo.Context) error {
+	roleRequest := models.RoleRequest{}
+	//the binder check if struct is not valid return err
+	if err := c.Bind(&roleRequest); err != nil {
+		return err
+	}
+	if roleRequest.Name == "" || !isValidPermissions(roleRequest.Permissions) {
+		return specialerror.ErrSomeFieldAreNotValid
+	}
+	now := time.Now()
+	role := models.Role{
+		Name:        roleRequest.Name,
+		Description: roleRequest.Description,
+		Permissions: roleRequest.Permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := rc.Roles.Insert(&role); err != nil {
+		if err == store.ErrDuplicate {
+			return specialerror.ErrRoleAlreadyExists
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if err := rc.Policy.Reload(); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusCreated, role)
+	return nil
+}
+
+//change the description and permissions, the users of role get the new permissions in their next request
+func (rc RoleController) UpdateRoleByName(c echo.Context) error {
+	name := c.Param("name")
+	//there should be always a role that can manage the roles
+	if name == models.ADMIN_ROLE {
+		return specialerror.ErrBuiltinRoleCanNotChange
+	}
+	roleRequest := models.RoleRequest{}
+	if err := c.Bind(&roleRequest); err != nil {
+		return err
+	}
+	if !isValidPermissions(roleRequest.Permissions) {
+		return specialerror.ErrSomeFieldAreNotValid
+	}
+	update := models.Role{Description: roleRequest.Description, Permissions: roleRequest.Permissions}
+	if err := rc.Roles.Update(name, &update); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if err := rc.Policy.Reload(); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	role, err := rc.Roles.FindByName(name)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, role)
+	return nil
+}
+
+//the builtin roles and the roles that given to users can't be removed
+func (rc RoleController) DeleteRoleByName(c echo.Context) error {
+	role, err := rc.Roles.FindByName(c.Param("name"))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if role.Builtin {
+		return specialerror.ErrBuiltinRoleCanNotChange
+	}
+	users, err := rc.Users.FindPage(store.UserFilter{Role: role.Name}, store.PageRequest{Limit: 1, SortField: "joined_at"})
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if items, ok := users.Items.([]models.User); !ok || len(items) > 0 {
+		return specialerror.ErrRoleIsInUse
+	}
+	if err := rc.Roles.Remove(role.Name); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if err := rc.Policy.Reload(); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyRemoved)
+	return nil
+}
+
+func isValidPermissions(permissions []string) bool {
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package role
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/test"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+	"github.com/atahani/golang-rest-api-sample/util/testhelper"
+)
+
+var testingProvider testhelper.TestingProvider
+
+func TestManageRoles(t *testing.T) {
+	roleController := NewRoleController(testingProvider.Stores.Roles, testingProvider.Stores.Users, testingProvider.Policy)
+	//the user of editor role can't be removed
+	testingProvider.Stores.Users.Insert(&models.User{Id: bson.NewObjectId(), Email: "reviewer@gmail.com", Roles: []string{"reviewer"}})
+	cases := []struct {
+		handler       echo.HandlerFunc
+		method        string
+		path          string
+		name          string
+		body          string
+		expectedError error
+	}{
+		{handler: roleController.CreateRole, method: echo.POST, path: "/api/manage/role", body: `{"name":"editor","permissions":["article:read","article:write"]}`, expectedError: nil},
+		{handler: roleController.CreateRole, method: echo.POST, path: "/api/manage/role", body: `{"name":"Editor","permissions":["article:read"]}`, expectedError: specialerror.ErrRoleAlreadyExists},
+		{handler: roleController.CreateRole, method: echo.POST, path: "/api/manage/role", body: `{"name":"writer","permissions":["article:delete"]}`, expectedError: specialerror.ErrSomeFieldAreNotValid},
+		{handler: roleController.CreateRole, method: echo.POST, path: "/api/manage/role", body: `{"name":"not valid name","permissions":["article:read"]}`, expectedError: specialerror.ErrSomeFieldAreNotValid},
+		{handler: roleController.CreateRole, method: echo.POST, path: "/api/manage/role", body: `{"permissions":["article:read"]}`, expectedError: specialerror.ErrSomeFieldAreNotValid},
+		{handler: roleController.CreateRole, method: echo.POST, path: "/api/manage/role", body: `{"name":"reviewer","permissions":["comment:moderate"]}`, expectedError: nil},
+		{handler: roleController.UpdateRoleByName, method: echo.PUT, path: "/api/manage/role/:name", name: "editor", body: `{"permissions":["article:read"," COMMENT:write "]}`, expectedError: nil},
+		{handler: roleController.UpdateRoleByName, method: echo.PUT, path: "/api/manage/role/:name", name: "unknown", body: `{"permissions":["article:read"]}`, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{handler: roleController.UpdateRoleByName, method: echo.PUT, path: "/api/manage/role/:name", name: models.ADMIN_ROLE, body: `{"permissions":["article:read"]}`, expectedError: specialerror.ErrBuiltinRoleCanNotChange},
+		{handler: roleController.DeleteRoleByName, method: echo.DELETE, path: "/api/manage/role/:name", name: models.USER_ROLE, expectedError: specialerror.ErrBuiltinRoleCanNotChange},
+		{handler: roleController.DeleteRoleByName, method: echo.DELETE, path: "/api/manage/role/:name", name: "reviewer", expectedError: specialerror.ErrRoleIsInUse},
+		{handler: roleController.DeleteRoleByName, method: echo.DELETE, path: "/api/manage/role/:name", name: "unknown", expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+	}
+	for _, c := range cases {
+		testingProvider.Router.Add(c.method, c.path, nil, testingProvider.Echo)
+		fullPath := strings.Replace(c.path, ":name", c.name, 1)
+		req := test.NewRequest(c.method, fullPath, bytes.NewBufferString(c.body))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		context := echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+		testingProvider.Router.Find(c.method, fullPath, context)
+		if err := c.handler(context); err != c.expectedError {
+			t.Errorf("Error should %v \t but get %v", c.expectedError, err)
+		}
+	}
+	//the policy reloaded after each change
+	expected := []string{models.ARTICLE_READ_PERMISSION, models.COMMENT_WRITE_PERMISSION}
+	if permissions := testingProvider.Policy.Permissions([]string{"editor"}); strings.Join(permissions, ",") != strings.Join(expected, ",") {
+		t.Errorf("permissions of editor should %v \t but get %v", expected, permissions)
+	}
+	//list of roles
+	res := test.NewResponseRecorder()
+	context := echo.NewContext(test.NewRequest(echo.GET, "/api/manage/role", nil), res, testingProvider.Echo)
+	if err := roleController.GetRoles(context); err != nil {
+		t.Fatal(err)
+	}
+	roles := []models.Role{}
+	if err := json.NewDecoder(res.Body).Decode(&roles); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	if strings.Join(names, ",") != "admin,editor,reviewer,user" {
+		t.Errorf("roles should be sorted by name \t but get %v", names)
+	}
+	//the role that is not used can be removed
+	testingProvider.Router.Add(echo.DELETE, "/api/manage/role/:name", nil, testingProvider.Echo)
+	context = echo.NewContext(test.NewRequest(echo.DELETE, "/api/manage/role/editor", nil), test.NewResponseRecorder(), testingProvider.Echo)
+	testingProvider.Router.Find(echo.DELETE, "/api/manage/role/editor", context)
+	if err := roleController.DeleteRoleByName(context); err != nil {
+		t.Errorf("Error should %v \t but get %v", nil, err)
+	}
+	if testingProvider.Policy.HasRole("editor") {
+		t.Error("the removed role should not be in policy")
+	}
+}
+
+func TestMain(m *testing.M) {
+	//start of testing
+	testingProvider = testhelper.TestingProvider{}
+	testingProvider.StartTesting()
+	ret := m.Run()
+	os.Exit(ret)
+}
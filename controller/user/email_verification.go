@@ -0,0 +1,124 @@
+package user
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/client"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/mailer"
+	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	EMAIL_VERIFICATION_TOKEN_PURPOSE = "email_verification"
+	EMAIL_VERIFICATION_EMAIL_SUBJECT = "Verify your email address"
+	EMAIL_VERIFICATION_EMAIL_BODY = "Hi %s,\n\nPlease verify this email address for your account by opening the link below in %s:\n\n%s\n\nIf you didn't use this email address, you can ignore this email.\n"
+)
+
+//echo middleware that only let users with verified email, it should be after JWTAuthenticationMiddleware
+func RequireVerifiedEmailMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if verified, ok := c.Get(EMAIL_VERIFIED_KEY).(bool); ok && verified {
+				return next(c)
+			}
+			return specialerror.ErrEmailIsNotVerified
+		}
+	}
+}
+
+//verify the email by token of verification email, the pending email replace the current email
+func (uc UserController) VerifyEmail(c echo.Context) error {
+	verifyEmailRequest := models.VerifyEmailRequest{}
+	if err := c.Bind(&verifyEmailRequest); err != nil {
+		return err
+	}
+	cliController := client.NewClientController(uc.Clients)
+	if _, err := cliController.ClientAuthorization(verifyEmailRequest.AppId, verifyEmailRequest.AppKey); err != nil {
+		return err
+	}
+	verificationToken, err := uc.OneTimeTokens.Consume(EMAIL_VERIFICATION_TOKEN_PURPOSE, util.HashToken(verifyEmailRequest.Token))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrEmailVerificationTokenIsNotValid
+		}
+		return specialerror.ErrInternalServerError
+	}
+	u, err := uc.Users.FindById(verificationToken.UserId)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrEmailVerificationTokenIsNotValid
+		}
+		return specialerror.ErrInternalServerError
+	}
+	switch verificationToken.Email {
+	case u.Email:
+		err = uc.Users.SetEmailVerified(u.Id, u.Email)
+	case u.PendingEmail:
+		//other user may take this email after it requested
+		isTaken, err2 := uc.Users.IsEmailTaken(u.PendingEmail, u.Id)
+		if err2 != nil {
+			return specialerror.ErrInternalServerError
+		}
+		if isTaken {
+			return specialerror.ErrAlreadyHaveUserWithThisEmailAddress
+		}
+		err = uc.Users.ConfirmPendingEmail(u.Id, u.PendingEmail)
+	default:
+		//the email changed after sending this token
+		return specialerror.ErrEmailVerificationTokenIsNotValid
+	}
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrEmailVerificationTokenIsNotValid
+		}
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.EmailSuccessfullyVerified)
+	return nil
+}
+
+//send verification email again, to the pending email if there is any
+func (uc UserController) ResendEmailVerification(c echo.Context) error {
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	u, err := uc.Users.FindById(userId)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	email := u.PendingEmail
+	if email == "" {
+		if u.EmailVerified {
+			return specialerror.ErrEmailIsAlreadyVerified
+		}
+		email = u.Email
+	}
+	if err := uc.sendEmailVerification(u, email); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.EmailVerificationSent)
+	return nil
+}
+
+//send the verification link of email address to it
+func (uc UserController) sendEmailVerification(u *models.User, email string) error {
+	link, err := uc.issueOneTimeToken(u.Id, EMAIL_VERIFICATION_TOKEN_PURPOSE, email, uc.Account.EmailVerificationTokenLifetime.Duration, uc.Account.EmailVerificationURL)
+	if err != nil {
+		return err
+	}
+	return uc.Mailer.Send(&mailer.Message{
+		To:      email,
+		Subject: EMAIL_VERIFICATION_EMAIL_SUBJECT,
+		Body:    fmt.Sprintf(EMAIL_VERIFICATION_EMAIL_BODY, u.DisplayName, uc.Account.EmailVerificationTokenLifetime.String(), link),
+	})
+}
@@ -0,0 +1,223 @@
+package user
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/pagination"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+//sort query parameter of users and its field
+var userSortFields = map[string]string{
+	"joined": "joined_at",
+	"email":  "email",
+	"name":   "display_name",
+}
+
+//the users that match q, role and enabled query parameters, the last joined first
+func (uc UserController) GetUsers(c echo.Context) error {
+	page, err := pagination.ParsePageRequest(c, userSortFields, "joined")
+	if err != nil {
+		return err
+	}
+	filter := store.UserFilter{
+		Query: strings.TrimSpace(c.QueryParam("q")),
+		Role:  strings.ToLower(c.QueryParam("role")),
+	}
+	if enabled := c.QueryParam("enabled"); enabled != "" {
+		isEnable, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return specialerror.ErrNotValidQueryParameter
+		}
+		filter.IsEnable = &isEnable
+	}
+	result, err := uc.Users.FindPage(filter, *page)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if users, ok := result.Items.([]models.User); ok {
+		for i := range users {
+			users[i].HashedPassword = ""
+		}
+	}
+	c.JSON(http.StatusOK, result)
+	return nil
+}
+
+//one user with its trusted apps
+func (uc UserController) GetUserById(c echo.Context) error {
+	u, err := uc.managedUser(c, false)
+	if err != nil {
+		return err
+	}
+	devices, err := uc.devices(u, "")
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	u.HashedPassword = ""
+	c.JSON(http.StatusOK, &models.UserDetail{User: u, TrustedApps: devices})
+	return nil
+}
+
+func (uc UserController) EnableUser(c echo.Context) error {
+	return uc.setUserEnabled(c, true)
+}
+
+//the access tokens of user revoked, so the user signed out right now
+func (uc UserController) DisableUser(c echo.Context) error {
+	return uc.setUserEnabled(c, false)
+}
+
+func (uc UserController) setUserEnabled(c echo.Context, enabled bool) error {
+	u, err := uc.managedUser(c, !enabled)
+	if err != nil {
+		return err
+	}
+	if err := uc.Users.SetEnabled(u.Id, enabled); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if !enabled {
+		if err := uc.AccessTokens.RemoveByUserId(u.Id); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyUpdated)
+	return nil
+}
+
+//replace the roles of user, the access tokens revoked when any role removed
+func (uc UserController) UpdateUserRoles(c echo.Context) error {
+	u, err := uc.managedUser(c, false)
+	if err != nil {
+		return err
+	}
+	updateRolesRequest := models.UpdateRolesRequest{}
+	if err := c.Bind(&updateRolesRequest); err != nil {
+		return err
+	}
+	for _, role := range updateRolesRequest.Roles {
+		if !uc.Policy.HasRole(role) {
+			return specialerror.ErrSomeFieldAreNotValid
+		}
+	}
+	demoted := false
+	for _, role := range u.Roles {
+		if !util.IsStringInSlice(role, updateRolesRequest.Roles) {
+			demoted = true
+		}
+	}
+	//admins can give roles to themselves but can't remove their own roles
+	if demoted && isCurrentUser(c, u.Id) {
+		return specialerror.ErrCanNotManageYourself
+	}
+	if err := uc.Users.SetRoles(u.Id, updateRolesRequest.Roles); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if demoted {
+		if err := uc.AccessTokens.RemoveByUserId(u.Id); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+	}
+	u.Roles = updateRolesRequest.Roles
+	u.HashedPassword = ""
+	c.JSON(http.StatusOK, u)
+	return nil
+}
+
+//sign out the user from all of trusted apps and send the reset password link, the user can't sign in until reset it
+func (uc UserController) ForcePasswordReset(c echo.Context) error {
+	u, err := uc.managedUser(c, false)
+	if err != nil {
+		return err
+	}
+	if err := uc.Users.RequirePasswordReset(u.Id); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.AccessTokens.RemoveByUserId(u.Id); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.sendPasswordResetEmail(u); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.PasswordResetRequired)
+	return nil
+}
+
+//remove the user with its tokens, the articles of user moved to trash and purged after the retention
+func (uc UserController) DeleteUser(c echo.Context) error {
+	u, err := uc.managedUser(c, true)
+	if err != nil {
+		return err
+	}
+	deletedAt := time.Now()
+	articleIds, err := uc.Articles.TrashAllOwned(u.Id, deletedAt)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	for _, articleId := range articleIds {
+		if err := uc.Revisions.SetDeletedAtByArticleId(articleId, &deletedAt); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+		if err := uc.Comments.SetDeletedAtByArticleId(articleId, &deletedAt); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+	}
+	//the articles of others are not shared with the removed user anymore
+	if err := uc.Articles.RemoveCollaboratorFromAll(u.Id); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.AccessTokens.RemoveByUserId(u.Id); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	for _, purpose := range []string{PASSWORD_RESET_TOKEN_PURPOSE, EMAIL_VERIFICATION_TOKEN_PURPOSE} {
+		if err := uc.OneTimeTokens.RemoveByUserId(u.Id, purpose); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+	}
+	if err := uc.Users.Remove(u.Id); err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return specialerror.ErrInternalServerError
+	}
+	if strings.HasPrefix(u.ImageFileName, PROFILE_IMAGE_DIR+"/") {
+		uc.removeProfileImage(u.ImageFileName)
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyRemoved)
+	return nil
+}
+
+//the user of id parameter, notSelf for the actions that admin can't do on its own account
+func (uc UserController) managedUser(c echo.Context, notSelf bool) (*models.User, error) {
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return nil, specialerror.ErrNotValidItemId
+	}
+	id := bson.ObjectIdHex(c.Param("id"))
+	if notSelf && isCurrentUser(c, id) {
+		return nil, specialerror.ErrCanNotManageYourself
+	}
+	u, err := uc.Users.FindById(id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, specialerror.ErrNotFoundAnyItemWithThisId
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	return u, nil
+}
+
+func isCurrentUser(c echo.Context, id bson.ObjectId) bool {
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	return ok && userId == id
+}
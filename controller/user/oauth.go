@@ -0,0 +1,286 @@
+package user
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/client"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	AUTHORIZATION_CODE_PURPOSE = "authorization_code"
+	//the error in redirect URI when the user denies the client
+	ACCESS_DENIED_OAUTH_ERROR = "access_denied"
+	MIN_CODE_VERIFIER_LENGTH = 43
+	MAX_CODE_VERIFIER_LENGTH = 128
+)
+
+//the client and the scopes of authorize request that the user should approve, the user signed in by the first party app
+func (uc UserController) GetAuthorizeConsent(c echo.Context) error {
+	authorizeRequest := models.AuthorizeRequest{
+		ResponseType:        c.QueryParam("response_type"),
+		ClientId:            c.QueryParam("client_id"),
+		RedirectURI:         c.QueryParam("redirect_uri"),
+		Scope:               c.QueryParam("scope"),
+		State:               c.QueryParam("state"),
+		CodeChallenge:       c.QueryParam("code_challenge"),
+		CodeChallengeMethod: c.QueryParam("code_challenge_method"),
+	}
+	if err := util.ValidateStruct(&authorizeRequest); err != nil {
+		return specialerror.ErrNotValidQueryParameter
+	}
+	cli, scopes, err := uc.authorizeClient(&authorizeRequest)
+	if err != nil {
+		return err
+	}
+	c.JSON(http.StatusOK, models.ConsentResponse{
+		ClientId:          cli.AppId,
+		ClientName:        cli.Name,
+		ClientDescription: cli.Description,
+		Scopes:            scopes,
+		RedirectURI:       authorizeRequest.RedirectURI,
+		State:             authorizeRequest.State,
+	})
+	return nil
+}
+
+//the answer of user in consent, the approved client gets authorization code in its redirect URI
+func (uc UserController) Authorize(c echo.Context) error {
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	authorizeRequest := models.AuthorizeRequest{}
+	//the binder check if struct is not valid return err
+	if err := c.Bind(&authorizeRequest); err != nil {
+		return err
+	}
+	cli, scopes, err := uc.authorizeClient(&authorizeRequest)
+	if err != nil {
+		return err
+	}
+	redirectURI, err := url.Parse(authorizeRequest.RedirectURI)
+	if err != nil {
+		return specialerror.ErrRedirectURIIsNotValid
+	}
+	query := redirectURI.Query()
+	if authorizeRequest.Approve {
+		code, err := util.GenerateNewOneTimeToken()
+		if err != nil {
+			return specialerror.ErrInternalServerError
+		}
+		authorizationCode := models.OneTimeToken{
+			Id:            bson.NewObjectId(),
+			UserId:        userId,
+			Purpose:       AUTHORIZATION_CODE_PURPOSE,
+			HashedToken:   util.HashToken(code),
+			ClientId:      cli.AppId,
+			RedirectURI:   authorizeRequest.RedirectURI,
+			Scopes:        scopes,
+			CodeChallenge: authorizeRequest.CodeChallenge,
+			ExpireAt:      time.Now().Add(uc.JWT.AuthorizationCodeLifetime.Duration),
+			CreatedAt:     time.Now(),
+		}
+		if err := uc.OneTimeTokens.Insert(&authorizationCode); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+		query.Set("code", code)
+	} else {
+		query.Set("error", ACCESS_DENIED_OAUTH_ERROR)
+	}
+	if authorizeRequest.State != "" {
+		query.Set("state", authorizeRequest.State)
+	}
+	redirectURI.RawQuery = query.Encode()
+	c.JSON(http.StatusOK, models.AuthorizeResponse{RedirectTo: redirectURI.String()})
+	return nil
+}
+
+//OAuth2 token endpoint, the request can be JSON or form
+func (uc UserController) IssueToken(c echo.Context) error {
+	tokenRequest := models.TokenRequest{}
+	if err := bindTokenRequest(c, &tokenRequest); err != nil {
+		return err
+	}
+	//the confidential clients send their hashed app key as client secret
+	cliController := client.NewClientController(uc.Clients)
+	isWebClient, err := cliController.ClientAuthorization(tokenRequest.ClientId, tokenRequest.ClientSecret); if err != nil {
+		return err
+	}
+	clientId := bson.ObjectIdHex(tokenRequest.ClientId)
+	var authResponse *models.AuthenticationResponse
+	switch tokenRequest.GrantType {
+	case models.AUTHORIZATION_CODE_GRANT_TYPE:
+		authResponse, err = uc.exchangeAuthorizationCode(clientId, &tokenRequest, isWebClient)
+	case models.REFRESH_TOKEN_GRANT_TYPE:
+		if tokenRequest.RefreshToken == "" {
+			return specialerror.ErrRefreshTokenIsNotValid
+		}
+		authResponse, err = uc.refreshAccessToken(c, clientId, tokenRequest.RefreshToken, tokenRequest.Scope)
+	default:
+		authResponse, err = uc.issueClientCredentialsToken(clientId, &tokenRequest)
+	}
+	if err != nil {
+		return err
+	}
+	//the tokens should not be cached
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	c.JSON(http.StatusOK, models.TokenResponse{
+		AccessToken:  authResponse.AccessToken,
+		TokenType:    authResponse.TokenType,
+		ExpiresIn:    int64(authResponse.ExpiresInMin * 60),
+		RefreshToken: authResponse.RefreshToken,
+		Scope:        authResponse.Scope,
+	})
+	return nil
+}
+
+//the enabled client with this redirect URI and the requested scopes, the client gets all of its allowed scopes when it doesn't request any
+func (uc UserController) authorizeClient(authorizeRequest *models.AuthorizeRequest) (*models.Client, []string, error) {
+	if !bson.IsObjectIdHex(authorizeRequest.ClientId) {
+		return nil, nil, specialerror.ErrNotValidClientInformation
+	}
+	cli, err := uc.Clients.FindById(bson.ObjectIdHex(authorizeRequest.ClientId))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, nil, specialerror.ErrClientIsNotValidToCommunicate
+		}
+		return nil, nil, specialerror.ErrInternalServerError
+	}
+	if !cli.IsEnable {
+		return nil, nil, specialerror.ErrClientIsNotValidToCommunicate
+	}
+	//never redirect to the URIs that are not registered
+	if !cli.HasRedirectURI(authorizeRequest.RedirectURI) {
+		return nil, nil, specialerror.ErrRedirectURIIsNotValid
+	}
+	scopes, err := requestedScopes(cli, authorizeRequest.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cli, scopes, nil
+}
+
+//exchange the authorization code with access token, the code can be used only once by its client and code verifier
+func (uc UserController) exchangeAuthorizationCode(clientId bson.ObjectId, tokenRequest *models.TokenRequest, isWebClient bool) (*models.AuthenticationResponse, error) {
+	if tokenRequest.Code == "" {
+		return nil, specialerror.ErrAuthorizationCodeIsNotValid
+	}
+	authorizationCode, err := uc.OneTimeTokens.Consume(AUTHORIZATION_CODE_PURPOSE, util.HashToken(tokenRequest.Code))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, specialerror.ErrAuthorizationCodeIsNotValid
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	if authorizationCode.ClientId != clientId || authorizationCode.RedirectURI != tokenRequest.RedirectURI || !isValidCodeVerifier(tokenRequest.CodeVerifier, authorizationCode.CodeChallenge) {
+		return nil, specialerror.ErrAuthorizationCodeIsNotValid
+	}
+	u, err := uc.Users.FindById(authorizationCode.UserId)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, specialerror.ErrAuthorizationCodeIsNotValid
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	//the same checks of sign in
+	if !u.IsEnable {
+		return nil, specialerror.ErrUserIsDisable
+	}
+	if u.PasswordResetRequired {
+		return nil, specialerror.ErrPasswordResetRequired
+	}
+	return uc.generateAccessToken(u, clientId, bson.NewObjectId(), "", authorizationCode.Scopes, isWebClient)
+}
+
+//the token of client itself for the other services, it doesn't have refresh token and doesn't act as any user
+func (uc UserController) issueClientCredentialsToken(clientId bson.ObjectId, tokenRequest *models.TokenRequest) (*models.AuthenticationResponse, error) {
+	cli, err := uc.Clients.FindById(clientId)
+	if err != nil {
+		return nil, specialerror.ErrInternalServerError
+	}
+	//the web clients are authorized without secret
+	if cli.PlatformType == client.WEB_PLATFORM_TYPE {
+		return nil, specialerror.ErrGrantTypeIsNotAllowed
+	}
+	scopes, err := requestedScopes(cli, tokenRequest.Scope)
+	if err != nil {
+		return nil, err
+	}
+	accessToken := models.AccessToken{
+		Id:       bson.NewObjectId(),
+		ClientId: cli.AppId,
+		Scopes:   scopes,
+	}
+	token, expireIn := uc.newAccessTokenJWT(&accessToken, cli.AppId.Hex())
+	sToken, err := uc.Keys.Sign(token); if err != nil {
+		return nil, specialerror.ErrInternalServerError
+	}
+	accessToken.Token = sToken
+	if err := uc.AccessTokens.Insert(&accessToken); err != nil {
+		return nil, specialerror.ErrInternalServerError
+	}
+	authResponse := models.AuthenticationResponse{
+		TokenType:    BEARER_AUTHENTICATION_TYPE,
+		AccessToken:  accessToken.Token,
+		ExpiresInMin: expireIn.Minutes(),
+		Scope:        strings.Join(scopes, " "),
+	}
+	return &authResponse, nil
+}
+
+//the space separated scopes should be allowed for client, empty scope means all of the allowed scopes
+func requestedScopes(cli *models.Client, scope string) ([]string, error) {
+	scopes := models.ParseScope(scope)
+	if len(scopes) == 0 {
+		scopes = cli.AllowedScopes
+	}
+	if len(scopes) == 0 {
+		return nil, specialerror.ErrScopeIsNotAllowed
+	}
+	for _, s := range scopes {
+		if !cli.IsScopeAllowed(s) {
+			return nil, specialerror.ErrScopeIsNotAllowed
+		}
+	}
+	return scopes, nil
+}
+
+//PKCE with S256 method, the challenge is base64url of SHA256 of verifier without padding
+func isValidCodeVerifier(verifier, challenge string) bool {
+	if len(verifier) < MIN_CODE_VERIFIER_LENGTH || len(verifier) > MAX_CODE_VERIFIER_LENGTH {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+//the OAuth2 clients send the token request as form, the others send JSON like the other endpoints
+func bindTokenRequest(c echo.Context, tokenRequest *models.TokenRequest) error {
+	if !strings.HasPrefix(c.Request().Header().Get(echo.HeaderContentType), echo.MIMEApplicationForm) {
+		return c.Bind(tokenRequest)
+	}
+	tokenRequest.GrantType = c.FormValue("grant_type")
+	tokenRequest.ClientId = c.FormValue("client_id")
+	tokenRequest.ClientSecret = c.FormValue("client_secret")
+	tokenRequest.Code = c.FormValue("code")
+	tokenRequest.RedirectURI = c.FormValue("redirect_uri")
+	tokenRequest.CodeVerifier = c.FormValue("code_verifier")
+	tokenRequest.RefreshToken = c.FormValue("refresh_token")
+	tokenRequest.Scope = c.FormValue("scope")
+	return util.ValidateStruct(tokenRequest)
+}
@@ -0,0 +1,146 @@
+package user
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/controller/client"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/mailer"
+	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	PASSWORD_RESET_TOKEN_PURPOSE = "password_reset"
+	PASSWORD_RESET_EMAIL_SUBJECT = "Reset your password"
+	PASSWORD_RESET_EMAIL_BODY = "Hi %s,\n\nWe received a request to reset the password of your account. Open the link below in %s to choose a new password:\n\n%s\n\nIf you didn't request it, you can ignore this email and your password doesn't change.\n"
+)
+
+//send reset password link to user email, the response is the same when there isn't any user with this email
+func (uc UserController) ForgotPassword(c echo.Context) error {
+	forgotPasswordRequest := models.ForgotPasswordRequest{}
+	//the binder check if struct is not valid return err
+	if err := c.Bind(&forgotPasswordRequest); err != nil {
+		return err
+	}
+	//check client information is valid or not
+	cliController := client.NewClientController(uc.Clients)
+	if _, err := cliController.ClientAuthorization(forgotPasswordRequest.AppId, forgotPasswordRequest.AppKey); err != nil {
+		return err
+	}
+	u, err := uc.Users.FindByEmail(strings.ToLower(forgotPasswordRequest.Email))
+	if err != nil && err != store.ErrNotFound {
+		return specialerror.ErrInternalServerError
+	}
+	//don't say which emails have account
+	if err == store.ErrNotFound || !u.IsEnable {
+		c.JSON(http.StatusOK, operationresult.PasswordResetEmailSent)
+		return nil
+	}
+	if err := uc.sendPasswordResetEmail(u); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.PasswordResetEmailSent)
+	return nil
+}
+
+//set new password by the token of reset password email and revoke all of trusted apps
+func (uc UserController) ResetPassword(c echo.Context) error {
+	resetPasswordRequest := models.ResetPasswordRequest{}
+	if err := c.Bind(&resetPasswordRequest); err != nil {
+		return err
+	}
+	cliController := client.NewClientController(uc.Clients)
+	if _, err := cliController.ClientAuthorization(resetPasswordRequest.AppId, resetPasswordRequest.AppKey); err != nil {
+		return err
+	}
+	//the token removed when found, so it can't be used again
+	resetToken, err := uc.OneTimeTokens.Consume(PASSWORD_RESET_TOKEN_PURPOSE, util.HashToken(resetPasswordRequest.Token))
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrPasswordResetTokenIsNotValid
+		}
+		return specialerror.ErrInternalServerError
+	}
+	u, err := uc.Users.FindById(resetToken.UserId)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrPasswordResetTokenIsNotValid
+		}
+		return specialerror.ErrInternalServerError
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(resetPasswordRequest.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	//whoever had the old password should sign in again, only the password fields changed so the changes of admin meanwhile are kept
+	if err := uc.Users.ResetPassword(u.Id, string(hashedPassword)); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.AccessTokens.RemoveByUserId(u.Id); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.PasswordSuccessfullyReset)
+	return nil
+}
+
+//issue new reset password token and send its link to user email
+func (uc UserController) sendPasswordResetEmail(u *models.User) error {
+	link, err := uc.issueOneTimeToken(u.Id, PASSWORD_RESET_TOKEN_PURPOSE, "", uc.Account.PasswordResetTokenLifetime.Duration, uc.Account.PasswordResetURL)
+	if err != nil {
+		return err
+	}
+	msg := mailer.Message{
+		To:      u.Email,
+		Subject: PASSWORD_RESET_EMAIL_SUBJECT,
+		Body:    fmt.Sprintf(PASSWORD_RESET_EMAIL_BODY, u.DisplayName, uc.Account.PasswordResetTokenLifetime.String(), link),
+	}
+	return uc.Mailer.Send(&msg)
+}
+
+//save new token for user and return the link that has the token, only the last token of each purpose is valid
+func (uc UserController) issueOneTimeToken(userId bson.ObjectId, purpose, email string, lifetime time.Duration, link string) (string, error) {
+	if err := uc.OneTimeTokens.RemoveByUserId(userId, purpose); err != nil {
+		return "", err
+	}
+	token, err := util.GenerateNewOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+	oneTimeToken := models.OneTimeToken{
+		Id:          bson.NewObjectId(),
+		UserId:      userId,
+		Purpose:     purpose,
+		HashedToken: util.HashToken(token),
+		Email:       email,
+		ExpireAt:    time.Now().Add(lifetime),
+		CreatedAt:   time.Now(),
+	}
+	if err := uc.OneTimeTokens.Insert(&oneTimeToken); err != nil {
+		return "", err
+	}
+	return linkWithToken(link, token)
+}
+
+//add the token as query parameter of the link
+func linkWithToken(link, token string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/util/picture"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	PROFILE_IMAGE_FORM_FIELD = "image"
+	PROFILE_IMAGE_DIR = "profile"
+)
+
+//sizes of the square variants, the first one saved with the file name of user image and the others with size suffix
+var profileImageSizes = []int{512, 256, 64}
+
+//key of the image variant in blob storage, such as profile/id/name_64.jpeg
+func ProfileImageVariant(fileName string, size int) string {
+	if size == profileImageSizes[0] {
+		return fileName
+	}
+	ext := path.Ext(fileName)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(fileName, ext), size, ext)
+}
+
+//get uploaded JPEG or PNG image as multipart form, resize it and save it as user image profile
+func (uc UserController) UpdateProfileImage(c echo.Context) error {
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	fh, err := c.FormFile(PROFILE_IMAGE_FORM_FIELD)
+	if err != nil {
+		return specialerror.ErrImageIsNotValid
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	defer file.Close()
+	//read one more byte to find out the file is larger than limit
+	data, err := ioutil.ReadAll(io.LimitReader(file, int64(uc.Media.MaxImageSize) + 1))
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if len(data) > uc.Media.MaxImageSize {
+		return specialerror.ErrImageIsTooLarge
+	}
+	img, format, err := picture.Decode(data, uc.Media.MaxImageDimension)
+	if err == picture.ErrTooLargeDimension {
+		return specialerror.ErrImageIsTooLarge
+	} else if err != nil {
+		return specialerror.ErrImageIsNotValid
+	}
+	current, err := uc.Users.FindById(userId)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	//new name for every upload so the cached images never get stale
+	fileName := fmt.Sprintf("%s/%s/%s.%s", PROFILE_IMAGE_DIR, userId.Hex(), bson.NewObjectId().Hex(), format)
+	result := models.ProfileImage{ImageFileName: fileName, Variants: map[string]string{}}
+	for _, size := range profileImageSizes {
+		content, err := picture.Encode(picture.Square(img, size), format)
+		if err != nil {
+			return specialerror.ErrInternalServerError
+		}
+		key := ProfileImageVariant(fileName, size)
+		if err := uc.Storage.Put(key, content, picture.ContentType(format)); err != nil {
+			uc.removeProfileImage(fileName)
+			return specialerror.ErrInternalServerError
+		}
+		result.Variants[strconv.Itoa(size)] = key
+	}
+	if err := uc.Users.SetImageFileName(userId, fileName); err != nil {
+		uc.removeProfileImage(fileName)
+		return specialerror.ErrInternalServerError
+	}
+	//the default image is shared by all of the users, only remove the uploaded ones
+	if strings.HasPrefix(current.ImageFileName, PROFILE_IMAGE_DIR + "/") {
+		uc.removeProfileImage(current.ImageFileName)
+	}
+	c.JSON(http.StatusOK, &result)
+	return nil
+}
+
+//remove all of the variants, it's best effort since the image isn't referenced anymore
+func (uc UserController) removeProfileImage(fileName string) {
+	for _, size := range profileImageSizes {
+		uc.Storage.Delete(ProfileImageVariant(fileName, size))
+	}
+}
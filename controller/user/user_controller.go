@@ -2,47 +2,82 @@ package user
 
 import (
 	"time"
-	"sync"
 	"strings"
 	"net/http"
 
 	"golang.org/x/crypto/bcrypt"
-	"gopkg.in/mgo.v2"
+	"github.com/dgrijalva/jwt-go"
 	"gopkg.in/mgo.v2/bson"
 	"gopkg.in/mcuadros/go-defaults.v1"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo"
 
+	"github.com/atahani/golang-rest-api-sample/config"
 	"github.com/atahani/golang-rest-api-sample/controller/client"
 	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
 	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/blobstorage"
+	"github.com/atahani/golang-rest-api-sample/util/jwtkey"
+	"github.com/atahani/golang-rest-api-sample/util/mailer"
 	"github.com/atahani/golang-rest-api-sample/util/operationresult"
+	"github.com/atahani/golang-rest-api-sample/util/permission"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 )
 
 const (
-	USER_COLLECTION_NAME = "users"
-	ACCESS_TOKEN_COLLECTION_NAME = "accessTokens"
-	JWT_SIGNING_KEY_PHRASE = "sectet_keys_to_hash_jwt_token"
 	BEARER_AUTHENTICATION_TYPE = "Bearer"
 	ROLES_KEY = "roles"
+	PERMISSIONS_KEY = "permissions"
+	SCOPES_KEY = "scopes"
 	USER_ID_KEY = "user_id"
 	TOKEN_ID_KEY = "token_id"
 	TRUSTED_APP_ID_KEY = "trusted_app_id"
+	EMAIL_VERIFIED_KEY = "email_verified"
+	REFRESH_TOKEN_REUSE_EVENT_TYPE = "refresh_token_reuse"
 )
 
 type UserController struct {
-	Session *mgo.Session
-	DBName  string
+	Users          store.UserStore
+	Clients        store.ClientStore
+	AccessTokens   store.AccessTokenStore
+	SecurityEvents store.SecurityEventStore
+	OneTimeTokens  store.OneTimeTokenStore
+	Articles       store.ArticleStore
+	Revisions      store.ArticleRevisionStore
+	Comments       store.CommentStore
+	JWT            config.JWTConfig
+	Account        config.AccountConfig
+	Keys           *jwtkey.Manager
+	Mailer         mailer.Mailer
+	Media          config.MediaConfig
+	Storage        blobstorage.Storage
+	Policy         *permission.Policy
 }
 
-func NewUserController(s *mgo.Session, dbName string) *UserController {
-	return &UserController{s, dbName}
+//user controller need most of the stores, so get all of them
+func NewUserController(stores *store.Stores, cfg *config.Config, keys *jwtkey.Manager, m mailer.Mailer, storage blobstorage.Storage, policy *permission.Policy) *UserController {
+	return &UserController{
+		Users:          stores.Users,
+		Clients:        stores.Clients,
+		AccessTokens:   stores.AccessTokens,
+		SecurityEvents: stores.SecurityEvents,
+		OneTimeTokens:  stores.OneTimeTokens,
+		Articles:       stores.Articles,
+		Revisions:      stores.ArticleRevisions,
+		Comments:       stores.Comments,
+		JWT:            cfg.JWT,
+		Account:        cfg.Account,
+		Keys:           keys,
+		Mailer:         m,
+		Media:          cfg.Media,
+		Storage:        storage,
+		Policy:         policy,
+	}
 }
 
-//echo middleware for checking JWT token is valid and authorize request
-func JWTAuthenticationMiddleware(s *mgo.Session, dbName string) echo.MiddlewareFunc {
+//echo middleware for checking JWT token is valid and authorize request, the permissions of user's roles set by policy
+func JWTAuthenticationMiddleware(users store.UserStore, accessTokens store.AccessTokenStore, keys *jwtkey.Manager, policy *permission.Policy) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header().Get(echo.HeaderAuthorization)
@@ -50,36 +85,34 @@ func JWTAuthenticationMiddleware(s *mgo.Session, dbName string) echo.MiddlewareF
 			he := specialerror.ErrUnauthorized
 			if len(authHeader) > l + 1 && authHeader[:l] == BEARER_AUTHENTICATION_TYPE {
 				token := string(authHeader[l + 1:])
-				t, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-					//always check the signing method
-					if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-						return nil, he
-					}
-					//return the key for validation
-					return []byte(JWT_SIGNING_KEY_PHRASE), nil
-				})
+				//verify with the key of kid header, it's check the signing method too
+				t, err := keys.Parse(token)
 				if err == nil && t.Valid {
-					//get copy of database session
-					session := s.Copy()
-					defer session.Close()
-					accessToken := models.AccessToken{}
-					if err := session.DB(dbName).C(ACCESS_TOKEN_COLLECTION_NAME).Find(bson.M{"token": token}).One(&accessToken); err != nil {
-						if err == mgo.ErrNotFound {
+					accessToken, err := accessTokens.FindByToken(token)
+					if err != nil {
+						if err == store.ErrNotFound {
 							return he
 						}
 						return specialerror.ErrInternalServerError
 					}
+					//the tokens of client credentials don't act as any user
+					if accessToken.UserId == "" {
+						return he
+					}
 					//get the user and check is enable or not
-					user := models.User{}
-					if err := session.DB(dbName).C(USER_COLLECTION_NAME).FindId(accessToken.UserId).One(&user); err != nil {
+					user, err := users.FindById(accessToken.UserId)
+					if err != nil {
 						return specialerror.ErrInternalServerError
 					}
 					if user.IsEnable {
 						//set some information that need in routes handler
 						c.Set(USER_ID_KEY, user.Id)
 						c.Set(ROLES_KEY, user.Roles)
+						c.Set(PERMISSIONS_KEY, policy.Permissions(user.Roles))
+						c.Set(SCOPES_KEY, accessTokenScopes(accessToken))
 						c.Set(TOKEN_ID_KEY, accessToken.Id)
 						c.Set(TRUSTED_APP_ID_KEY, accessToken.TrustedAppId)
+						c.Set(EMAIL_VERIFIED_KEY, user.EmailVerified)
 						//process the next and finish this middleware
 						return next(c)
 					}
@@ -91,50 +124,76 @@ func JWTAuthenticationMiddleware(s *mgo.Session, dbName string) echo.MiddlewareF
 	}
 }
 
-//echo middleware to check is this user have role
-func AuthorizeUserByRolesMiddleware(roles []string) echo.MiddlewareFunc {
+//echo middleware to check the user have all of these permissions
+func RequirePermission(permissions ...string) echo.MiddlewareFunc {
+	return requirePermissions(permission.HasAll, permissions)
+}
+
+//echo middleware to check the user have at least one of these permissions
+func RequireAnyPermission(permissions ...string) echo.MiddlewareFunc {
+	return requirePermissions(permission.HasAny, permissions)
+}
+
+func requirePermissions(match func(granted []string, required ...string) bool, permissions []string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			data := c.Get(ROLES_KEY)
-			if roleSlice, ok := data.([]string); ok {
-				//check is have these role or not
-				for _, v := range roles {
-					if !util.IsStringInSlice(v, roleSlice) {
-						return specialerror.ErrCanNotAccessToTheseResource
-					}
+			granted, ok := c.Get(PERMISSIONS_KEY).([]string)
+			if !ok {
+				return specialerror.ErrInternalServerError
+			}
+			if !match(granted, permissions...) {
+				return specialerror.ErrCanNotAccessToTheseResource
+			}
+			//process the next and finish this middleware
+			return next(c)
+		}
+	}
+}
+
+//echo middleware to check the access token has all of these scopes, the permissions of user are checked separately
+func RequireScope(scopes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			granted, ok := c.Get(SCOPES_KEY).([]string)
+			if !ok {
+				return specialerror.ErrInternalServerError
+			}
+			for _, scope := range scopes {
+				if !util.IsStringInSlice(scope, granted) {
+					return specialerror.ErrInsufficientScope
 				}
-				//it's mean the user have all of the roles
-				//process the next and finish this middleware
-				return next(c)
 			}
-			return specialerror.ErrInternalServerError
+			//process the next and finish this middleware
+			return next(c)
 		}
 	}
 }
 
+//check the user of request have this permission, used in handlers that check the permission besides ownership
+func HasPermission(c echo.Context, p string) bool {
+	granted, _ := c.Get(PERMISSIONS_KEY).([]string)
+	return permission.Has(granted, p)
+}
+
 func (uc UserController) SignUpNewUser(c echo.Context) error {
 	//create the user model
 	signUpModel := models.SignUpRequest{}
 	if err := c.Bind(&signUpModel); err != nil {
 		return err
 	}
-	//get copy of session
-	session := uc.Session.Copy()
-	defer session.Copy()
-	//TODO : please NOTE should check is't new email for this user ? if yes ? send verification email to this email
 	//first check is already have user with this email address > unique or not
-	count, err := session.DB(uc.DBName).C(USER_COLLECTION_NAME).Find(bson.M{"email": strings.ToLower(signUpModel.Email)}).Count(); if err != nil {
+	isTaken, err := uc.Users.IsEmailTaken(strings.ToLower(signUpModel.Email), ""); if err != nil {
 		return specialerror.ErrInternalServerError
 	}
-	if count != 0 {
+	if isTaken {
 		return specialerror.ErrAlreadyHaveUserWithThisEmailAddress
 	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(signUpModel.Password), bcrypt.DefaultCost); if err != nil {
 		return specialerror.ErrInternalServerError
 	}
 	//check client information is valid or not
-	cliController := client.NewClientController(uc.Session, uc.DBName)
-	isWebClient, err := cliController.ClientAuthorization(uc.Session, uc.DBName, signUpModel.AppId, signUpModel.AppKey); if err != nil {
+	cliController := client.NewClientController(uc.Clients)
+	isWebClient, err := cliController.ClientAuthorization(signUpModel.AppId, signUpModel.AppKey); if err != nil {
 		return err
 	}
 	//create new user and assign attributes
@@ -145,18 +204,23 @@ func (uc UserController) SignUpNewUser(c echo.Context) error {
 		DisplayName:    signUpModel.DisplayName,
 		Email:          strings.ToLower(signUpModel.Email),
 		HashedPassword: string(hashedPassword),
-		Roles:          []string{"user"},
+		Roles:          []string{models.USER_ROLE},
 		JoinedAt:       time.Now(),
 		UpdatedAt:      time.Now(),
 	}
 	//set defaults values for user model
 	defaults.SetDefaults(&u)
 	//store new user into database
-	if err := session.DB(uc.DBName).C(USER_COLLECTION_NAME).Insert(u); err != nil {
+	if err := uc.Users.Insert(&u); err != nil {
 		return specialerror.ErrInternalServerError
 	}
+	//the user can ask for verification email again, so sign up doesn't fail because of mail server
+	uc.sendEmailVerification(&u, u.Email)
+	scopes, err := uc.signInScopes(bson.ObjectIdHex(signUpModel.AppId)); if err != nil {
+		return err
+	}
 	//should generate access token and send it
-	authResponse, err := generateAccessToken(session, uc.DBName, &u, bson.ObjectIdHex(signUpModel.AppId), bson.NewObjectId(), signUpModel.DeviceModel, false, isWebClient); if err != nil {
+	authResponse, err := uc.generateAccessToken(&u, bson.ObjectIdHex(signUpModel.AppId), bson.NewObjectId(), signUpModel.DeviceModel, scopes, isWebClient); if err != nil {
 		return err
 	}
 	//return the authentication response
@@ -166,9 +230,6 @@ func (uc UserController) SignUpNewUser(c echo.Context) error {
 
 //authenticate the user with credential information email/username with password and generate access token
 func (uc UserController) SignIn(c echo.Context) error {
-	//get copy of database session
-	session := uc.Session.Copy()
-	defer session.Close()
 	//check the credential information if it's valid
 	signInRequest := models.SignInRequest{}
 	//populate user credential information from request
@@ -177,14 +238,14 @@ func (uc UserController) SignIn(c echo.Context) error {
 		return err
 	}
 	//check client information is valid or not
-	cliController := client.NewClientController(uc.Session, uc.DBName)
-	isWebClient, err := cliController.ClientAuthorization(uc.Session, uc.DBName, signInRequest.AppId, signInRequest.AppKey); if err != nil {
+	cliController := client.NewClientController(uc.Clients)
+	isWebClient, err := cliController.ClientAuthorization(signInRequest.AppId, signInRequest.AppKey); if err != nil {
 		return err
 	}
-	var user models.User
 	//get user by email
-	if err := session.DB(uc.DBName).C(USER_COLLECTION_NAME).Find(bson.M{"email": strings.ToLower(signInRequest.Email)}).One(&user); err != nil {
-		if err == mgo.ErrNotFound {
+	user, err := uc.Users.FindByEmail(strings.ToLower(signInRequest.Email))
+	if err != nil {
+		if err == store.ErrNotFound {
 			return specialerror.ErrNotValidCredentialInfo
 		}
 		return specialerror.ErrInternalServerError
@@ -193,8 +254,18 @@ func (uc UserController) SignIn(c echo.Context) error {
 	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(signInRequest.Password)); err != nil {
 		return specialerror.ErrNotValidCredentialInfo
 	}
+	//the disabled users and the users that admin reset their password can't get new token
+	if !user.IsEnable {
+		return specialerror.ErrUserIsDisable
+	}
+	if user.PasswordResetRequired {
+		return specialerror.ErrPasswordResetRequired
+	}
+	scopes, err := uc.signInScopes(bson.ObjectIdHex(signInRequest.AppId)); if err != nil {
+		return err
+	}
 	//it's mean the credential information is valid so should generate JWT token as send it as JSON
-	authResponse, err := generateAccessToken(session, uc.DBName, &user, bson.ObjectIdHex(signInRequest.AppId), bson.NewObjectId(), signInRequest.DeviceModel, false, isWebClient); if err != nil {
+	authResponse, err := uc.generateAccessToken(user, bson.ObjectIdHex(signInRequest.AppId), bson.NewObjectId(), signInRequest.DeviceModel, scopes, isWebClient); if err != nil {
 		return err
 	}
 	//return the authentication response
@@ -203,46 +274,109 @@ func (uc UserController) SignIn(c echo.Context) error {
 }
 
 //refresh the user access token
-func (ac UserController) RefreshAccessToken(c echo.Context) error {
-	//get copy of database session
-	session := ac.Session.Copy()
-	defer session.Close()
+func (uc UserController) RefreshAccessToken(c echo.Context) error {
 	refreshTokenRequest := models.RefreshTokenRequest{}
 	//the binder check if struct is not valid return err
 	if err := c.Bind(&refreshTokenRequest); err != nil {
 		return err
 	}
-	cliController := client.NewClientController(ac.Session, ac.DBName)
+	cliController := client.NewClientController(uc.Clients)
 	//check client information is valid or not
-	isWebClient, err := cliController.ClientAuthorization(session, ac.DBName, refreshTokenRequest.AppId, refreshTokenRequest.AppKey); if err != nil {
+	if _, err := cliController.ClientAuthorization(refreshTokenRequest.AppId, refreshTokenRequest.AppKey); err != nil {
+		return err
+	}
+	//generate the access token
+	authResponse, err := uc.refreshAccessToken(c, bson.ObjectIdHex(refreshTokenRequest.AppId), refreshTokenRequest.RefreshToken, ""); if err != nil {
 		return err
 	}
+	//return the authentication response
+	c.JSON(http.StatusOK, &authResponse)
+	return nil
+}
+
+//rotate the refresh token of trusted app and generate new access token, used by refresh token endpoint and OAuth2 token endpoint
+//the access token can have less scopes than its trusted app by the space separated scope, empty scope means all of them
+func (uc UserController) refreshAccessToken(c echo.Context, clientId bson.ObjectId, refreshToken, scope string) (*models.AuthenticationResponse, error) {
 	//check is refresh token valid or not
-	user := models.User{}
-	if err := session.DB(ac.DBName).C(USER_COLLECTION_NAME).Find(bson.M{"trusted_apps._client": bson.ObjectIdHex(refreshTokenRequest.AppId), "trusted_apps.refresh_token": refreshTokenRequest.RefreshToken}).One(&user); err != nil {
-		if err == mgo.ErrNotFound {
-			return specialerror.ErrRefreshTokenIsNotValid
+	user, err := uc.Users.FindByRefreshToken(clientId, refreshToken)
+	if err != nil {
+		if err == store.ErrNotFound {
+			//the rotated refresh token used again, so one of them is stolen
+			return nil, uc.revokeReusedRefreshToken(c, clientId, refreshToken)
 		}
-		return specialerror.ErrInternalServerError
+		return nil, specialerror.ErrInternalServerError
+	}
+	if !user.IsEnable {
+		return nil, specialerror.ErrUserIsDisable
 	}
 	//get the trusted app
-	var trustedAppId bson.ObjectId
-	for _, trustedApp := range user.TrustedApps {
-		if trustedApp.RefreshToken == refreshTokenRequest.RefreshToken {
-			trustedAppId = trustedApp.Id
+	var trustedApp models.TrustedApp
+	for _, ta := range user.TrustedApps {
+		if ta.ClientId == clientId && ta.RefreshToken == refreshToken {
+			trustedApp = ta
 		}
 	}
-	//generate the access token
-	authResponse, err := generateAccessToken(session, ac.DBName, &user, bson.ObjectIdHex(refreshTokenRequest.AppId), trustedAppId, "", true, isWebClient); if err != nil {
-		return err
+	trustedAppId := trustedApp.Id
+	if uc.isRefreshTokenExpired(trustedApp) {
+		//the trusted app is useless, so remove it
+		if err := uc.Users.RemoveTrustedApp(user.Id, trustedAppId); err != nil {
+			return nil, specialerror.ErrInternalServerError
+		}
+		return nil, specialerror.ErrRefreshTokenIsExpired
 	}
-	//return the authentication response
-	c.JSON(http.StatusOK, &authResponse)
-	return nil
+	scopes := trustedApp.Scopes
+	if scopes == nil {
+		var err error
+		if scopes, err = uc.signInScopes(clientId); err != nil {
+			return nil, err
+		}
+	}
+	if requested := models.ParseScope(scope); len(requested) > 0 {
+		for _, s := range requested {
+			if !util.IsStringInSlice(s, scopes) {
+				return nil, specialerror.ErrScopeIsNotAllowed
+			}
+		}
+		scopes = requested
+	}
+	newRefreshToken, err := util.GenerateNewRefreshToken(); if err != nil {
+		return nil, specialerror.ErrInternalServerError
+	}
+	//conditional update, the concurrent refresh by the same token that loses is a reuse of rotated refresh token
+	if err := uc.Users.RotateRefreshToken(user.Id, trustedAppId, refreshToken, newRefreshToken, uc.JWT.RetiredRefreshTokensLimit, time.Now()); err != nil {
+		if err == store.ErrNotFound {
+			return nil, uc.revokeReusedRefreshToken(c, clientId, refreshToken)
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	return uc.issueAccessToken(user, clientId, trustedAppId, newRefreshToken, scopes)
+}
+
+//the scopes of sign in, the clients without allowed scopes are the first party apps that have all of them
+func (uc UserController) signInScopes(clientId bson.ObjectId) ([]string, error) {
+	cli, err := uc.Clients.FindById(clientId)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, specialerror.ErrClientIsNotValidToCommunicate
+		}
+		return nil, specialerror.ErrInternalServerError
+	}
+	if len(cli.AllowedScopes) == 0 {
+		return models.Scopes, nil
+	}
+	return cli.AllowedScopes, nil
+}
+
+//the scopes that routes check, the tokens issued before scopes have all of them
+func accessTokenScopes(accessToken *models.AccessToken) []string {
+	if accessToken.Scopes == nil {
+		return models.Scopes
+	}
+	return accessToken.Scopes
 }
 
 //update user model such as email , first, last, display name
-//TODO : should implement user change image profile, get uploaded image resize it and save it as user image profile
+//the image profile changed by UpdateProfileImage
 func (uc UserController) UpdateUserProfile(c echo.Context) error {
 	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
 	if !ok {
@@ -253,34 +387,41 @@ func (uc UserController) UpdateUserProfile(c echo.Context) error {
 	if err := c.Bind(&u); err != nil {
 		return err
 	}
-	//copy session db
-	session := uc.Session.Copy()
-	defer session.Close()
-	//TODO : please NOTE should check is't new email for this user ? if yes ? send verification email to this email
-	//check is email address unique or not
-	count, err := session.DB(uc.DBName).C(USER_COLLECTION_NAME).Find(bson.M{"email": strings.ToLower(u.Email), "_id": bson.M{"$ne": userId}}).Count(); if err != nil {
+	current, err := uc.Users.FindById(userId)
+	if err != nil {
 		return specialerror.ErrInternalServerError
 	}
-	if count != 0 {
-		return specialerror.ErrAlreadyHaveUserWithThisEmailAddress
-	}
-	userUpdateSet := bson.M{
-		"first_name":   u.FirstName,
-		"last_name":    u.LastName,
-		"display_name": u.DisplayName,
-		"email":        u.Email,
-		"updated_at":   time.Now(),
+	email := strings.ToLower(u.Email)
+	isEmailChanged := email != current.Email && email != current.PendingEmail
+	if isEmailChanged {
+		//check is email address unique or not before changing anything
+		isTaken, err := uc.Users.IsEmailTaken(email, userId); if err != nil {
+			return specialerror.ErrInternalServerError
+		}
+		if isTaken {
+			return specialerror.ErrAlreadyHaveUserWithThisEmailAddress
+		}
 	}
 	//update the user profile in one query
-	if err := session.DB(uc.DBName).C(USER_COLLECTION_NAME).UpdateId(userId, bson.M{"$set": userUpdateSet}); err != nil {
+	if err := uc.Users.UpdateProfile(userId, &u); err != nil {
 		return specialerror.ErrInternalServerError
 	}
-	c.JSON(http.StatusOK, operationresult.SuccessfullyUpdated)
+	if !isEmailChanged {
+		c.JSON(http.StatusOK, operationresult.SuccessfullyUpdated)
+		return nil
+	}
+	//the new email replace the current one after verification
+	if err := uc.Users.SetPendingEmail(userId, email); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.sendEmailVerification(current, email); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.ProfileUpdatedEmailPending)
 	return nil
 }
 
 //change password with authorized user NOT reset password
-//TODO : should implement reset password flow like send reset password link to user by email
 func (uc UserController) ChangeUserPassword(c echo.Context) error {
 	chPasswordReqModel := models.ChangePasswordRequestModel{}
 	if err := c.Bind(&chPasswordReqModel); err != nil {
@@ -291,12 +432,9 @@ func (uc UserController) ChangeUserPassword(c echo.Context) error {
 	if !ok {
 		return specialerror.ErrInternalServerError
 	}
-	//copy db session
-	session := uc.Session.Copy()
-	defer session.Close()
 	//get user model from db to check password and update it
-	u := models.User{}
-	if err := session.DB(uc.DBName).C(USER_COLLECTION_NAME).FindId(userId).One(&u); err != nil {
+	u, err := uc.Users.FindById(userId)
+	if err != nil {
 		return specialerror.ErrInternalServerError
 	}
 	//check old password is valid or not
@@ -308,9 +446,7 @@ func (uc UserController) ChangeUserPassword(c echo.Context) error {
 	if err != nil {
 		return specialerror.ErrInternalServerError
 	}
-	u.HashedPassword = string(hashedPassword)
-	u.UpdatedAt = time.Now()
-	if err := session.DB(uc.DBName).C(USER_COLLECTION_NAME).UpdateId(userId, &u); err != nil {
+	if err := uc.Users.SetPassword(u.Id, string(hashedPassword)); err != nil {
 		return specialerror.ErrInternalServerError
 	}
 	//inform user the password successfully changed
@@ -318,114 +454,271 @@ func (uc UserController) ChangeUserPassword(c echo.Context) error {
 	return nil
 }
 
-func generateAccessToken(s *mgo.Session, dbName string, u *models.User, clientId, trustedAppId bson.ObjectId, deviceModel string, isRefreshToken, isWebClient bool) (*models.AuthenticationResponse, error) {
+//revoke the current access token and the refresh token of its trusted app
+func (uc UserController) SignOut(c echo.Context) error {
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	tokenId, ok := c.Get(TOKEN_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	trustedAppId, ok := c.Get(TRUSTED_APP_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	//first remove the trusted app so the refresh token can't make new access token
+	if err := uc.Users.RemoveTrustedApp(userId, trustedAppId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	//the other access tokens of this trusted app can't be refreshed anymore, so remove them too
+	if err := uc.AccessTokens.RemoveByTrustedAppId(trustedAppId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.AccessTokens.RemoveById(tokenId); err != nil && err != store.ErrNotFound {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullySignedOut)
+	return nil
+}
+
+//revoke all of trusted apps and access tokens of user
+func (uc UserController) SignOutAll(c echo.Context) error {
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.Users.RemoveAllTrustedApps(userId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.AccessTokens.RemoveByUserId(userId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullySignedOutAll)
+	return nil
+}
+
+//list the trusted apps of user as devices with their client name
+func (uc UserController) GetDevices(c echo.Context) error {
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	currentTrustedAppId, _ := c.Get(TRUSTED_APP_ID_KEY).(bson.ObjectId)
+	u, err := uc.Users.FindById(userId)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	devices, err := uc.devices(u, currentTrustedAppId)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, devices)
+	return nil
+}
+
+//the trusted apps of user with their client information, currentTrustedAppId can be empty
+func (uc UserController) devices(u *models.User, currentTrustedAppId bson.ObjectId) ([]models.Device, error) {
+	//get each client once
+	clients := map[bson.ObjectId]*models.Client{}
+	devices := []models.Device{}
+	for _, trustedApp := range u.TrustedApps {
+		cli, ok := clients[trustedApp.ClientId]
+		if !ok {
+			var err error
+			cli, err = uc.Clients.FindById(trustedApp.ClientId)
+			if err != nil && err != store.ErrNotFound {
+				return nil, err
+			}
+			clients[trustedApp.ClientId] = cli
+		}
+		device := models.Device{
+			Id:          trustedApp.Id,
+			ClientId:    trustedApp.ClientId,
+			DeviceModel: trustedApp.DeviceModel,
+			OSVersion:   trustedApp.OSVersion,
+			AppVersion:  trustedApp.AppVersion,
+			Scopes:      trustedApp.Scopes,
+			GrantedAt:   trustedApp.GrantedAt,
+			LastUsedAt:  trustedApp.LastUsedAt,
+			IsCurrent:   trustedApp.Id == currentTrustedAppId,
+		}
+		//the trusted apps granted before keeping last used time
+		if device.LastUsedAt.IsZero() {
+			device.LastUsedAt = trustedApp.GrantedAt
+		}
+		//the client may be removed by admin
+		if cli != nil {
+			device.ClientName = cli.Name
+			device.PlatformType = cli.PlatformType
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+//revoke one trusted app of user, its refresh token and access tokens
+func (uc UserController) RevokeDevice(c echo.Context) error {
+	//first check is id valid or not
+	if !bson.IsObjectIdHex(c.Param("id")) {
+		return specialerror.ErrNotValidItemId
+	}
+	trustedAppId := bson.ObjectIdHex(c.Param("id"))
+	userId, ok := c.Get(USER_ID_KEY).(bson.ObjectId)
+	if !ok {
+		return specialerror.ErrInternalServerError
+	}
+	u, err := uc.Users.FindById(userId)
+	if err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	//the trusted app should belong to this user
+	foundIt := false
+	for _, trustedApp := range u.TrustedApps {
+		if trustedApp.Id == trustedAppId {
+			foundIt = true
+		}
+	}
+	if !foundIt {
+		return specialerror.ErrNotFoundAnyItemWithThisId
+	}
+	if err := uc.Users.RemoveTrustedApp(userId, trustedAppId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	if err := uc.AccessTokens.RemoveByTrustedAppId(trustedAppId); err != nil {
+		return specialerror.ErrInternalServerError
+	}
+	c.JSON(http.StatusOK, operationresult.SuccessfullyRemoved)
+	return nil
+}
+
+//refresh token expired when the trusted app is idle or the session is too old
+func (uc UserController) isRefreshTokenExpired(trustedApp models.TrustedApp) bool {
+	now := time.Now()
+	//the trusted apps granted before keeping these times
+	sessionStartedAt, lastUsedAt := trustedApp.SessionStartedAt, trustedApp.LastUsedAt
+	if sessionStartedAt.IsZero() {
+		sessionStartedAt = trustedApp.GrantedAt
+	}
+	if lastUsedAt.IsZero() {
+		lastUsedAt = sessionStartedAt
+	}
+	return now.Sub(sessionStartedAt) > uc.JWT.RefreshTokenAbsoluteLifetime.Duration || now.Sub(lastUsedAt) > uc.JWT.RefreshTokenIdleLifetime.Duration
+}
+
+//revoke the trusted app that this retired refresh token belongs to and record security event
+func (uc UserController) revokeReusedRefreshToken(c echo.Context, clientId bson.ObjectId, refreshToken string) error {
+	user, err := uc.Users.FindByRetiredRefreshToken(clientId, refreshToken)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return specialerror.ErrRefreshTokenIsNotValid
+		}
+		return specialerror.ErrInternalServerError
+	}
+	for _, trustedApp := range user.TrustedApps {
+		if trustedApp.ClientId != clientId || !util.IsStringInSlice(refreshToken, trustedApp.RetiredRefreshTokens) {
+			continue
+		}
+		//both of the attacker and the user should sign in again
+		if err := uc.Users.RemoveTrustedApp(user.Id, trustedApp.Id); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+		if err := uc.AccessTokens.RemoveByTrustedAppId(trustedApp.Id); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+		event := models.SecurityEvent{
+			Id:           bson.NewObjectId(),
+			UserId:       user.Id,
+			TrustedAppId: trustedApp.Id,
+			ClientId:     clientId,
+			Type:         REFRESH_TOKEN_REUSE_EVENT_TYPE,
+			IPAddress:    c.Request().RemoteAddress(),
+			UserAgent:    c.Request().UserAgent(),
+			CreatedAt:    time.Now(),
+		}
+		if err := uc.SecurityEvents.Insert(&event); err != nil {
+			return specialerror.ErrInternalServerError
+		}
+	}
+	return specialerror.ErrRefreshTokenIsNotValid
+}
+
+//replace the refresh token and keep the old one to detect its reuse
+func (uc UserController) rotateRefreshToken(trustedApp *models.TrustedApp, refreshToken string) {
+	if trustedApp.RefreshToken != "" {
+		trustedApp.RetiredRefreshTokens = append(trustedApp.RetiredRefreshTokens, trustedApp.RefreshToken)
+		//only keep the newest ones
+		if limit := uc.JWT.RetiredRefreshTokensLimit; limit > 0 && len(trustedApp.RetiredRefreshTokens) > limit {
+			trustedApp.RetiredRefreshTokens = trustedApp.RetiredRefreshTokens[len(trustedApp.RetiredRefreshTokens) - limit:]
+		}
+	}
+	trustedApp.RefreshToken = refreshToken
+}
+
+//the scopes replace the scopes of trusted app, the existing trusted app of this client starts new session by new refresh token
+func (uc UserController) generateAccessToken(u *models.User, clientId, trustedAppId bson.ObjectId, deviceModel string, scopes []string, isWebClient bool) (*models.AuthenticationResponse, error) {
+	//generate the refresh token
+	refreshToken, err := util.GenerateNewRefreshToken(); if err != nil {
+		return nil, specialerror.ErrInternalServerError
+	}
+	//the web client has one trusted app, the other clients have one trusted app for each device model
+	var trustedApp *models.TrustedApp
+	for i := range u.TrustedApps {
+		if u.TrustedApps[i].ClientId == clientId && (isWebClient || u.TrustedApps[i].DeviceModel == deviceModel) {
+			trustedApp = &u.TrustedApps[i]
+		}
+	}
+	if trustedApp != nil {
+		//the access token belongs to the existing trusted app
+		uc.rotateRefreshToken(trustedApp, refreshToken)
+	} else {
+		//so should create new trusted app
+		trustedApp = &models.TrustedApp{
+			Id:           trustedAppId,
+			ClientId:     clientId,
+			RefreshToken: refreshToken,
+			GrantedAt:    time.Now(),
+		}
+		if !isWebClient {
+			trustedApp.DeviceModel = deviceModel
+		}
+	}
+	//sign in again start new session
+	trustedApp.SessionStartedAt = time.Now()
+	trustedApp.Scopes = scopes
+	//keep the last time this trusted app used
+	trustedApp.LastUsedAt = time.Now()
+	//only the trusted app saved, so the changes of user meanwhile such as disabling by admin are not overwritten
+	if err := uc.Users.SaveTrustedApp(u.Id, trustedApp); err != nil {
+		return nil, specialerror.ErrInternalServerError
+	}
+	return uc.issueAccessToken(u, clientId, trustedApp.Id, refreshToken, scopes)
+}
+
+//sign and save the access token of trusted app, the refresh token should be already saved in trusted app
+func (uc UserController) issueAccessToken(u *models.User, clientId, trustedAppId bson.ObjectId, refreshToken string, scopes []string) (*models.AuthenticationResponse, error) {
 	//define access token model
 	accessToken := models.AccessToken{
 		Id:           bson.NewObjectId(),
 		UserId:       u.Id,
 		TrustedAppId: trustedAppId,
+		ClientId:     clientId,
+		Scopes:       scopes,
 	}
-	expireIn := time.Hour * time.Duration(util.GenerateRandomNumber(24, 72))
-	accessToken.ExpireAt = time.Now().Add(expireIn)
-	//new JWT
-	token := jwt.New(jwt.SigningMethodHS256)
-	//set headers
-	token.Header["type"] = "JWT"
-	token.Claims["exp"] = accessToken.ExpireAt.Unix()
+	token, expireIn := uc.newAccessTokenJWT(&accessToken, u.Id.Hex())
 	token.Claims["uid"] = u.Id.Hex()
 	token.Claims["roles"] = u.Roles
 	token.Claims["name"] = u.DisplayName
 	token.Claims["img"] = u.ImageFileName
 	token.Claims["aid"] = trustedAppId.Hex()
-	token.Claims["tid"] = accessToken.Id.Hex()
-	sToken, err := token.SignedString([]byte(JWT_SIGNING_KEY_PHRASE)); if err != nil {
+	sToken, err := uc.Keys.Sign(token); if err != nil {
 		return nil, specialerror.ErrInternalServerError
 	}
 	//assign access Token
 	accessToken.Token = sToken
-	//generate the refresh token
-	refreshToken, err := util.GenerateNewRefreshToken(); if err != nil {
-		return nil, specialerror.ErrInternalServerError
-	}
-	//save trusted app for this user and save the access token in database
-	//check is web client
-	if isRefreshToken {
-		//so already have trusted app for this user
-		foundIt := false
-		for i, trustedApp := range u.TrustedApps {
-			if trustedApp.Id == trustedAppId {
-				foundIt = true
-				u.TrustedApps[i].RefreshToken = refreshToken
-			}
-		}
-		if !foundIt {
-			return nil, specialerror.ErrInternalServerError
-		}
-	} else {
-		if isWebClient {
-			//search in trustedApps have already this webClient
-			foundIt := false
-			for i, trustedApp := range u.TrustedApps {
-				if trustedApp.ClientId == clientId {
-					foundIt = true
-					u.TrustedApps[i].RefreshToken = refreshToken
-				}
-			}
-			if !foundIt {
-				//so should create new trusted app
-				newTrustedAppForWebClient := models.TrustedApp{
-					Id:           trustedAppId,
-					ClientId:     clientId,
-					RefreshToken: refreshToken,
-					GrantedAt:    time.Now(),
-				}
-				//assign this trusted app for web client
-				u.TrustedApps = append(u.TrustedApps, newTrustedAppForWebClient)
-			}
-		} else {
-			//first check is already have any trusted app with this client and device model
-			foundIt := false
-			for i, trustedApp := range u.TrustedApps {
-				if trustedApp.ClientId == clientId && trustedApp.DeviceModel == deviceModel {
-					foundIt = true
-					u.TrustedApps[i].RefreshToken = refreshToken
-				}
-			}
-			if !foundIt {
-				//create new trusted app
-				newTrustedApp := models.TrustedApp{
-					Id:           trustedAppId,
-					ClientId:     clientId,
-					RefreshToken: refreshToken,
-					DeviceModel:  deviceModel,
-					GrantedAt:    time.Now(),
-				}
-				//assign this trusted app to user
-				u.TrustedApps = append(u.TrustedApps, newTrustedApp)
-			}
-		}
-	}
-	var err1, err2 error
-	waitGroup := sync.WaitGroup{}
-	waitGroup.Add(2)
-	go func() {
-		defer waitGroup.Done()
-		//copy the db session
-		session1 := s.Copy()
-		defer session1.Close()
-		//now should save the user
-		err1 = session1.DB(dbName).C(USER_COLLECTION_NAME).UpdateId(u.Id, &u)
-	}()
-	go func() {
-		defer waitGroup.Done()
-		//copy the db session
-		session2 := s.Copy()
-		defer session2.Close()
-		//save access token to db
-		err2 = session2.DB(dbName).C(ACCESS_TOKEN_COLLECTION_NAME).Insert(&accessToken)
-	}()
-	waitGroup.Wait()
-	if err1 != nil || err2 != nil {
+	//save access token to db
+	if err := uc.AccessTokens.Insert(&accessToken); err != nil {
 		return nil, specialerror.ErrInternalServerError
 	}
 	AuthResponse := models.AuthenticationResponse{
@@ -433,6 +726,33 @@ func generateAccessToken(s *mgo.Session, dbName string, u *models.User, clientId
 		AccessToken:  accessToken.Token,
 		ExpiresInMin: expireIn.Minutes(),
 		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
 	}
 	return &AuthResponse, nil
 }
+
+//new JWT of access token with the registered claims, the expire time of access token set by its random lifetime
+func (uc UserController) newAccessTokenJWT(accessToken *models.AccessToken, subject string) (*jwt.Token, time.Duration) {
+	//the life time is random minutes between min and max lifetime
+	expireIn := uc.JWT.AccessTokenMinLifetime.Duration
+	if spread := int((uc.JWT.AccessTokenMaxLifetime.Duration - expireIn) / time.Minute); spread > 0 {
+		expireIn += time.Minute * time.Duration(util.GenerateRandomNumber(0, spread))
+	}
+	issuedAt := time.Now()
+	accessToken.ExpireAt = issuedAt.Add(expireIn)
+	//new JWT signed by the active key
+	token := uc.Keys.NewToken()
+	//set headers
+	token.Header["type"] = "JWT"
+	//registered claims so other services can verify the token by discovery endpoints
+	token.Claims["iss"] = uc.JWT.Issuer
+	token.Claims["aud"] = uc.JWT.Audience
+	token.Claims["sub"] = subject
+	token.Claims["iat"] = issuedAt.Unix()
+	token.Claims["nbf"] = issuedAt.Unix()
+	token.Claims["exp"] = accessToken.ExpireAt.Unix()
+	token.Claims["cid"] = accessToken.ClientId.Hex()
+	token.Claims["scope"] = strings.Join(accessToken.Scopes, " ")
+	token.Claims["tid"] = accessToken.Id.Hex()
+	return token, expireIn
+}
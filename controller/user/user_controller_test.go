@@ -3,21 +3,29 @@ package user
 import (
 	"os"
 	"fmt"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
 	"testing"
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"image"
+	"image/png"
+	"mime/multipart"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/engine"
 	"github.com/labstack/echo/test"
-	"github.com/syndtr/goleveldb/leveldb/errors"
-	"github.com/dgrijalva/jwt-go"
 
 	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/blobstorage"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 	"github.com/atahani/golang-rest-api-sample/util/testhelper"
 	"github.com/atahani/golang-rest-api-sample/controller/client"
@@ -33,11 +41,7 @@ func TestSignUpNewUser(t *testing.T) {
 	userEmail = "ahmad.tahani@gmail.com"
 	userPassword = "123456abcz"
 	newPassword = "987654321mnbvcx"
-	//get copy of db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	userController := NewUserController(session, testhelper.DB_TEST_NAME)
-	session.DB(testhelper.DB_TEST_NAME).C(USER_COLLECTION_NAME).DropCollection()
+	userController := newUserController()
 	//define different cases
 	reqBodyInvalidAppId := models.SignUpRequest{
 		AppId:       "123124",
@@ -131,10 +135,7 @@ func TestSignUpNewUser(t *testing.T) {
 }
 
 func TestRefreshAccessToken(t *testing.T) {
-	//get copy db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	userController := NewUserController(session, testhelper.DB_TEST_NAME)
+	userController := newUserController()
 	//define different cases
 	refreshToke1 := models.RefreshTokenRequest{
 		AppId:        newAppIdStr,
@@ -199,10 +200,7 @@ func TestRefreshAccessToken(t *testing.T) {
 }
 
 func TestSignIn(t *testing.T) {
-	//get copy of db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	userController := NewUserController(session, testhelper.DB_TEST_NAME)
+	userController := newUserController()
 	reqBodyInvalidAppId := models.SignInRequest{
 		AppId:    "0981234",
 		Email:    userEmail,
@@ -272,11 +270,8 @@ func TestSignIn(t *testing.T) {
 }
 
 func TestJWTAuthenticationMiddleware(t *testing.T) {
-	//get copy of db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
 	//define jwt as handler since we test middleware alone
-	jwt := JWTAuthenticationMiddleware(session, testhelper.DB_TEST_NAME)(func(c echo.Context) error {
+	jwt := JWTAuthenticationMiddleware(testingProvider.Stores.Users, testingProvider.Stores.AccessTokens, testingProvider.Keys, testingProvider.Policy)(func(c echo.Context) error {
 		return c.String(http.StatusOK, "test")
 	})
 	//define different case
@@ -315,63 +310,76 @@ func TestJWTAuthenticationMiddleware(t *testing.T) {
 	}
 }
 
-func TestAuthorizeUserByRolesMiddleware(t *testing.T) {
-	//get copy of db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
-	//define authorize role as handler since we test middleware alone
-	authorizeRole := AuthorizeUserByRolesMiddleware([]string{"admin", "user"})(func(c echo.Context) error {
+func TestRequirePermission(t *testing.T) {
+	//define the middlewares as handler since we test middleware alone
+	handler := func(c echo.Context) error {
 		return c.String(http.StatusOK, "test")
-	})
+	}
+	allOf := RequirePermission(models.ARTICLE_READ_PERMISSION, models.ARTICLE_WRITE_PERMISSION)(handler)
+	anyOf := RequireAnyPermission(models.COMMENT_WRITE_PERMISSION, models.COMMENT_MODERATE_PERMISSION)(handler)
 	//define different cases
 	cases := []struct {
-		req           engine.Request
-		res           *test.ResponseRecorder
+		middleware    echo.HandlerFunc
 		roles         []string
 		expectedError error
 	}{
-		{
-			req:           test.NewRequest(echo.GET, "/", nil),
-			res:           test.NewResponseRecorder(),
-			roles:         []string{"admin", "user"},
-			expectedError: nil,
-		},
-		{
-			req:           test.NewRequest(echo.GET, "/", nil),
-			res:           test.NewResponseRecorder(),
-			roles:         []string{"admin"},
-			expectedError: specialerror.ErrCanNotAccessToTheseResource,
-		},
-		{
-			req:           test.NewRequest(echo.GET, "/", nil),
-			res:           test.NewResponseRecorder(),
-			roles:         []string{"user"},
-			expectedError: specialerror.ErrCanNotAccessToTheseResource,
-		},
+		{middleware: allOf, roles: []string{models.USER_ROLE}, expectedError: nil},
+		{middleware: allOf, roles: []string{models.ADMIN_ROLE}, expectedError: nil},
+		{middleware: allOf, roles: []string{}, expectedError: specialerror.ErrCanNotAccessToTheseResource},
+		{middleware: allOf, roles: []string{"unknown"}, expectedError: specialerror.ErrCanNotAccessToTheseResource},
+		{middleware: anyOf, roles: []string{models.USER_ROLE}, expectedError: nil},
+		{middleware: anyOf, roles: []string{"unknown", models.ADMIN_ROLE}, expectedError: nil},
+		{middleware: anyOf, roles: nil, expectedError: specialerror.ErrCanNotAccessToTheseResource},
 	}
 	for _, c := range cases {
-		c.req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
-		context := echo.NewContext(c.req, c.res, testingProvider.Echo)
-		context.Set(ROLES_KEY, c.roles)
-		if err := authorizeRole(context); err != c.expectedError {
+		context := echo.NewContext(test.NewRequest(echo.GET, "/", nil), test.NewResponseRecorder(), testingProvider.Echo)
+		context.Set(PERMISSIONS_KEY, testingProvider.Policy.Permissions(c.roles))
+		if err := c.middleware(context); err != c.expectedError {
+			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
+		}
+	}
+	//the middleware after authentication
+	context := echo.NewContext(test.NewRequest(echo.GET, "/", nil), test.NewResponseRecorder(), testingProvider.Echo)
+	if err := allOf(context); err != specialerror.ErrInternalServerError {
+		t.Errorf("Error should %q \t but get %q", specialerror.ErrInternalServerError, err)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	//define the middleware as handler since we test middleware alone
+	articlesWrite := RequireScope(models.ARTICLES_READ_SCOPE, models.ARTICLES_WRITE_SCOPE)(func(c echo.Context) error {
+		return c.String(http.StatusOK, "test")
+	})
+	cases := []struct {
+		scopes        []string
+		expectedError error
+	}{
+		{scopes: models.Scopes, expectedError: nil},
+		{scopes: []string{models.ARTICLES_WRITE_SCOPE, models.ARTICLES_READ_SCOPE}, expectedError: nil},
+		{scopes: []string{models.ARTICLES_READ_SCOPE}, expectedError: specialerror.ErrInsufficientScope},
+		{scopes: []string{models.ACCOUNT_SCOPE}, expectedError: specialerror.ErrInsufficientScope},
+		{scopes: []string{}, expectedError: specialerror.ErrInsufficientScope},
+	}
+	for _, c := range cases {
+		context := echo.NewContext(test.NewRequest(echo.GET, "/", nil), test.NewResponseRecorder(), testingProvider.Echo)
+		context.Set(SCOPES_KEY, c.scopes)
+		if err := articlesWrite(context); err != c.expectedError {
 			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
 		}
 	}
+	//the first party apps without allowed scopes have all of scopes
+	if authResponse.Scope != strings.Join(models.Scopes, " ") {
+		t.Errorf("scope should %q \t but get %q", strings.Join(models.Scopes, " "), authResponse.Scope)
+	}
+	//the tokens issued before scopes have all of them
+	if scopes := accessTokenScopes(&models.AccessToken{}); len(scopes) != len(models.Scopes) {
+		t.Errorf("scopes should %v \t but get %v", models.Scopes, scopes)
+	}
 }
 
 func TestUpdateUserProfile(t *testing.T) {
-	//copy session of db
-	session := testingProvider.Session.Copy()
-	defer session.Close()
 	//get the user_id from JWT token
-	to, err := jwt.Parse(authResponse.AccessToken, func(token *jwt.Token) (interface{}, error) {
-		//always check the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("can't parse the access token !")
-		}
-		//return the key for validation
-		return []byte(JWT_SIGNING_KEY_PHRASE), nil
-	})
+	to, err := testingProvider.Keys.Parse(authResponse.AccessToken)
 	if err != nil || to == nil || !to.Valid {
 		t.Errorf("the access token is not valid or can't validate the access token !")
 	}
@@ -379,7 +387,11 @@ func TestUpdateUserProfile(t *testing.T) {
 	if !ok {
 		t.Errorf("can't get user_id from claims !")
 	}
-	userController := NewUserController(session, testhelper.DB_TEST_NAME)
+	//the registered claims for the other services
+	if to.Claims["sub"] != userId || to.Claims["iss"] != testingProvider.Config.JWT.Issuer || to.Claims["aud"] != testingProvider.Config.JWT.Audience {
+		t.Errorf("the access token should have sub, iss and aud claims \t but get %v", to.Claims)
+	}
+	userController := newUserController()
 	//define different cases
 	reqBodyValid := models.User{
 		FirstName:   "ahmad :)",
@@ -401,6 +413,18 @@ func TestUpdateUserProfile(t *testing.T) {
 		//don't have displayName and email address
 	}
 	reqBodyInvalidFieldsJ, _ := json.Marshal(reqBodyInvalidFields)
+	//the email of another user, the profile should not be changed
+	otherUser := models.User{Id: bson.NewObjectId(), Email: "taken.profile@gmail.com"}
+	if err := testingProvider.Stores.Users.Insert(&otherUser); err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	reqBodyTakenEmail := models.User{
+		FirstName:   "not changed",
+		LastName:    "not changed",
+		DisplayName: "not changed",
+		Email:       otherUser.Email,
+	}
+	reqBodyTakenEmailJ, _ := json.Marshal(reqBodyTakenEmail)
 	path := "/api/user/profile"
 	method := echo.PUT
 	cases := []struct {
@@ -423,6 +447,11 @@ func TestUpdateUserProfile(t *testing.T) {
 			res:           test.NewResponseRecorder(),
 			expectedError: specialerror.ErrSomeFieldAreNotValid,
 		},
+		{
+			req:           test.NewRequest(method, path, bytes.NewReader(reqBodyTakenEmailJ)),
+			res:           test.NewResponseRecorder(),
+			expectedError: specialerror.ErrAlreadyHaveUserWithThisEmailAddress,
+		},
 	}
 	for _, c := range cases {
 		c.req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
@@ -433,21 +462,15 @@ func TestUpdateUserProfile(t *testing.T) {
 			t.Errorf("Error should %q \t but get %q", c.expectedError, err)
 		}
 	}
+	u, err := testingProvider.Stores.Users.FindById(bson.ObjectIdHex(userId))
+	if err != nil || u.DisplayName != reqBodyValid.DisplayName {
+		t.Errorf("Error should %v \t but get %v, %v", reqBodyValid.DisplayName, u, err)
+	}
 }
 
 func TestChangeUserPassword(t *testing.T) {
-	//copy db session
-	session := testingProvider.Session.Copy()
-	defer session.Close()
 	//get the user_id from JWT token
-	to, err := jwt.Parse(authResponse.AccessToken, func(token *jwt.Token) (interface{}, error) {
-		//always check the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("can't parse the access token !")
-		}
-		//return the key for validation
-		return []byte(JWT_SIGNING_KEY_PHRASE), nil
-	})
+	to, err := testingProvider.Keys.Parse(authResponse.AccessToken)
 	if err != nil || to == nil || !to.Valid {
 		t.Errorf("the access token is not valid or can't validate the access token !")
 	}
@@ -455,7 +478,7 @@ func TestChangeUserPassword(t *testing.T) {
 	if !ok {
 		t.Errorf("can't get user_id from claims !")
 	}
-	userController := NewUserController(session, testhelper.DB_TEST_NAME)
+	userController := newUserController()
 	//define different cases
 	reqBodyValid := models.ChangePasswordRequestModel{
 		OldPassword: userPassword,
@@ -506,13 +529,746 @@ func TestChangeUserPassword(t *testing.T) {
 	}
 }
 
+func TestSignOut(t *testing.T) {
+	userController := newUserController()
+	jwt := JWTAuthenticationMiddleware(testingProvider.Stores.Users, testingProvider.Stores.AccessTokens, testingProvider.Keys, testingProvider.Policy)
+	//each case sign in again, sign out and check the tokens are revoked
+	cases := []struct {
+		handler echo.HandlerFunc
+		path    string
+	}{
+		{handler: userController.SignOut, path: "/auth/signout"},
+		{handler: userController.SignOutAll, path: "/auth/signout/all"},
+	}
+	for _, c := range cases {
+		auth := signInForTest(t, newPassword)
+		req := test.NewRequest(echo.POST, c.path, nil)
+		req.Header().Set(echo.HeaderAuthorization, fmt.Sprintf("%s %s", BEARER_AUTHENTICATION_TYPE, auth.AccessToken))
+		context := echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+		if err := jwt(c.handler)(context); err != nil {
+			t.Errorf("Error should %v \t but get %q", nil, err)
+		}
+		//the access token should not be valid anymore
+		req = test.NewRequest(echo.POST, c.path, nil)
+		req.Header().Set(echo.HeaderAuthorization, fmt.Sprintf("%s %s", BEARER_AUTHENTICATION_TYPE, auth.AccessToken))
+		context = echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+		if err := jwt(c.handler)(context); err != specialerror.ErrUnauthorized {
+			t.Errorf("Error should %q \t but get %v", specialerror.ErrUnauthorized, err)
+		}
+		//the refresh token should not be valid anymore
+		reqBody, _ := json.Marshal(models.RefreshTokenRequest{AppId: newAppIdStr, RefreshToken: auth.RefreshToken})
+		req = test.NewRequest(echo.POST, "/auth/token/refresh", bytes.NewReader(reqBody))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		context = echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+		if err := userController.RefreshAccessToken(context); err != specialerror.ErrRefreshTokenIsNotValid {
+			t.Errorf("Error should %q \t but get %v", specialerror.ErrRefreshTokenIsNotValid, err)
+		}
+	}
+}
+
+func TestDevices(t *testing.T) {
+	userController := newUserController()
+	auth := signInForTest(t, newPassword)
+	to, err := testingProvider.Keys.Parse(auth.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId := bson.ObjectIdHex(to.Claims["uid"].(string))
+	trustedAppId := bson.ObjectIdHex(to.Claims["aid"].(string))
+	//list devices
+	res := test.NewResponseRecorder()
+	context := echo.NewContext(test.NewRequest(echo.GET, "/api/user/devices", nil), res, testingProvider.Echo)
+	context.Set(USER_ID_KEY, userId)
+	context.Set(TRUSTED_APP_ID_KEY, trustedAppId)
+	if err := userController.GetDevices(context); err != nil {
+		t.Errorf("Error should %v \t but get %q", nil, err)
+	}
+	devices := []models.Device{}
+	if err := json.NewDecoder(res.Body).Decode(&devices); err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].Id != trustedAppId || !devices[0].IsCurrent || devices[0].ClientName != "new client just for test" || devices[0].LastUsedAt.IsZero() {
+		t.Errorf("devices should have the current trusted app \t but get %+v", devices)
+	}
+	//revoke device
+	testingProvider.Router.Add(echo.DELETE, "/api/user/devices/:id", nil, testingProvider.Echo)
+	path := "/api/user/devices/"
+	cases := []struct {
+		path          string
+		req           engine.Request
+		res           *test.ResponseRecorder
+		expectedError error
+	}{
+		{
+			path:          fmt.Sprintf("%ssomeinvalid", path),
+			req:           test.NewRequest(echo.DELETE, fmt.Sprintf("%ssomeinvalid", path), nil),
+			res:           test.NewResponseRecorder(),
+			expectedError: specialerror.ErrNotValidItemId,
+		},
+		{
+			path:          fmt.Sprintf("%s%s", path, bson.NewObjectId().Hex()),
+			req:           test.NewRequest(echo.DELETE, fmt.Sprintf("%s%s", path, bson.NewObjectId().Hex()), nil),
+			res:           test.NewResponseRecorder(),
+			expectedError: specialerror.ErrNotFoundAnyItemWithThisId,
+		},
+		{
+			path:          fmt.Sprintf("%s%s", path, trustedAppId.Hex()),
+			req:           test.NewRequest(echo.DELETE, fmt.Sprintf("%s%s", path, trustedAppId.Hex()), nil),
+			res:           test.NewResponseRecorder(),
+			expectedError: nil,
+		},
+	}
+	for _, c := range cases {
+		context := echo.NewContext(c.req, c.res, testingProvider.Echo)
+		testingProvider.Router.Find(echo.DELETE, c.path, context)
+		context.Set(USER_ID_KEY, userId)
+		if err := userController.RevokeDevice(context); err != c.expectedError {
+			t.Errorf("Error should %v \t but get %v", c.expectedError, err)
+		}
+	}
+	//the access token of revoked device should not be valid anymore
+	if _, err := testingProvider.Stores.AccessTokens.FindByToken(auth.AccessToken); err != store.ErrNotFound {
+		t.Errorf("Error should %q \t but get %v", store.ErrNotFound, err)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	userController := newUserController()
+	auth := signInForTest(t, newPassword)
+	//rotate the refresh token
+	rotated, err := refreshForTest(userController, auth.RefreshToken)
+	if err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	//use the old refresh token again
+	if _, err := refreshForTest(userController, auth.RefreshToken); err != specialerror.ErrRefreshTokenIsNotValid {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrRefreshTokenIsNotValid, err)
+	}
+	//the whole trusted app revoked
+	if _, err := refreshForTest(userController, rotated.RefreshToken); err != specialerror.ErrRefreshTokenIsNotValid {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrRefreshTokenIsNotValid, err)
+	}
+	if _, err := testingProvider.Stores.AccessTokens.FindByToken(rotated.AccessToken); err != store.ErrNotFound {
+		t.Errorf("Error should %q \t but get %v", store.ErrNotFound, err)
+	}
+	u, err := testingProvider.Stores.Users.FindByEmail(userEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := testingProvider.Stores.SecurityEvents.FindByUserId(u.Id)
+	if err != nil || len(events) != 1 || events[0].Type != REFRESH_TOKEN_REUSE_EVENT_TYPE {
+		t.Errorf("should record one %s security event \t but get %v", REFRESH_TOKEN_REUSE_EVENT_TYPE, events)
+	}
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	cases := []struct {
+		absolute time.Duration
+		idle     time.Duration
+	}{
+		{absolute: time.Hour, idle: time.Nanosecond},
+		{absolute: time.Nanosecond, idle: time.Nanosecond},
+	}
+	for _, c := range cases {
+		auth := signInForTest(t, newPassword)
+		userController := newUserController()
+		userController.JWT.RefreshTokenAbsoluteLifetime.Duration = c.absolute
+		userController.JWT.RefreshTokenIdleLifetime.Duration = c.idle
+		if _, err := refreshForTest(userController, auth.RefreshToken); err != specialerror.ErrRefreshTokenIsExpired {
+			t.Errorf("Error should %q \t but get %v", specialerror.ErrRefreshTokenIsExpired, err)
+		}
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	userController := newUserController()
+	auth := signInForTest(t, newPassword)
+	//request reset password links
+	forgotCases := []struct {
+		email        string
+		expectedMail bool
+	}{
+		{email: "not.registered@gmail.com", expectedMail: false},
+		{email: userEmail, expectedMail: true},
+	}
+	for _, c := range forgotCases {
+		reqBody, _ := json.Marshal(models.ForgotPasswordRequest{AppId: newAppIdStr, Email: c.email})
+		req := test.NewRequest(echo.POST, "/auth/password/forgot", bytes.NewReader(reqBody))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		context := echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+		if err := userController.ForgotPassword(context); err != nil {
+			t.Errorf("Error should %v \t but get %v", nil, err)
+		}
+		if msg := testingProvider.MailBox.LastMessageTo(c.email); (msg != nil) != c.expectedMail {
+			t.Errorf("sending email to %s should be %t", c.email, c.expectedMail)
+		}
+	}
+	msg := testingProvider.MailBox.LastMessageTo(userEmail)
+	if msg == nil {
+		t.Fatal("reset password email didn't send")
+	}
+	token := regexp.MustCompile(`token=([0-9a-f]+)`).FindStringSubmatch(msg.Body)
+	if token == nil {
+		t.Fatalf("reset password email should have token \t but get %q", msg.Body)
+	}
+	resetPassword := "reset0987password"
+	cases := []struct {
+		token         string
+		expectedError error
+	}{
+		{token: "notvalidtoken", expectedError: specialerror.ErrPasswordResetTokenIsNotValid},
+		{token: token[1], expectedError: nil},
+		//the token can be used only once
+		{token: token[1], expectedError: specialerror.ErrPasswordResetTokenIsNotValid},
+	}
+	for _, c := range cases {
+		reqBody, _ := json.Marshal(models.ResetPasswordRequest{AppId: newAppIdStr, Token: c.token, Password: resetPassword})
+		req := test.NewRequest(echo.POST, "/auth/password/reset", bytes.NewReader(reqBody))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		context := echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+		if err := userController.ResetPassword(context); err != c.expectedError {
+			t.Errorf("Error should %v \t but get %v", c.expectedError, err)
+		}
+	}
+	//the sessions before reset are revoked
+	if _, err := testingProvider.Stores.AccessTokens.FindByToken(auth.AccessToken); err != store.ErrNotFound {
+		t.Errorf("Error should %q \t but get %v", store.ErrNotFound, err)
+	}
+	if _, err := refreshForTest(userController, auth.RefreshToken); err != specialerror.ErrRefreshTokenIsNotValid {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrRefreshTokenIsNotValid, err)
+	}
+	//sign in with the new password
+	signInForTest(t, resetPassword)
+	newPassword = resetPassword
+}
+
+func TestEmailVerification(t *testing.T) {
+	userController := newUserController()
+	u, err := testingProvider.Stores.Users.FindByEmail(userEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//sign up sent the verification email
+	if len(testingProvider.MailBox.Messages) == 0 || testingProvider.MailBox.Messages[0].Subject != EMAIL_VERIFICATION_EMAIL_SUBJECT {
+		t.Error("sign up should send verification email")
+	}
+	//not verified users can't pass the middleware
+	requireVerified := RequireVerifiedEmailMiddleware()(func(c echo.Context) error {
+		return c.String(http.StatusOK, "test")
+	})
+	context := echo.NewContext(test.NewRequest(echo.POST, "/api/article", nil), test.NewResponseRecorder(), testingProvider.Echo)
+	context.Set(EMAIL_VERIFIED_KEY, u.EmailVerified)
+	if err := requireVerified(context); err != specialerror.ErrEmailIsNotVerified {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrEmailIsNotVerified, err)
+	}
+	//send the verification email again and verify the current email
+	context = echo.NewContext(test.NewRequest(echo.POST, "/api/user/email/verification", nil), test.NewResponseRecorder(), testingProvider.Echo)
+	context.Set(USER_ID_KEY, u.Id)
+	if err := userController.ResendEmailVerification(context); err != nil {
+		t.Errorf("Error should %v \t but get %v", nil, err)
+	}
+	token := verificationTokenForTest(t, userEmail)
+	cases := []struct {
+		token         string
+		expectedError error
+	}{
+		{token: "notvalidtoken", expectedError: specialerror.ErrEmailVerificationTokenIsNotValid},
+		{token: token, expectedError: nil},
+		{token: token, expectedError: specialerror.ErrEmailVerificationTokenIsNotValid},
+	}
+	for _, c := range cases {
+		if err := verifyEmailForTest(userController, c.token); err != c.expectedError {
+			t.Errorf("Error should %v \t but get %v", c.expectedError, err)
+		}
+	}
+	if u, _ = testingProvider.Stores.Users.FindById(u.Id); !u.EmailVerified {
+		t.Error("email should be verified")
+	}
+	context = echo.NewContext(test.NewRequest(echo.POST, "/api/user/email/verification", nil), test.NewResponseRecorder(), testingProvider.Echo)
+	context.Set(USER_ID_KEY, u.Id)
+	if err := userController.ResendEmailVerification(context); err != specialerror.ErrEmailIsAlreadyVerified {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrEmailIsAlreadyVerified, err)
+	}
+	//change the email, it's pending until verified
+	newEmail := "ahmad.tahani.new@gmail.com"
+	reqBody, _ := json.Marshal(models.User{FirstName: u.FirstName, LastName: u.LastName, DisplayName: u.DisplayName, Email: newEmail})
+	req := test.NewRequest(echo.PUT, "/api/user/profile", bytes.NewReader(reqBody))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	context = echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+	context.Set(USER_ID_KEY, u.Id)
+	if err := userController.UpdateUserProfile(context); err != nil {
+		t.Errorf("Error should %v \t but get %v", nil, err)
+	}
+	if u, _ = testingProvider.Stores.Users.FindById(u.Id); u.Email != userEmail || u.PendingEmail != newEmail {
+		t.Errorf("email should %q and pending email %q \t but get %q and %q", userEmail, newEmail, u.Email, u.PendingEmail)
+	}
+	if err := verifyEmailForTest(userController, verificationTokenForTest(t, newEmail)); err != nil {
+		t.Errorf("Error should %v \t but get %v", nil, err)
+	}
+	if u, _ = testingProvider.Stores.Users.FindById(u.Id); u.Email != newEmail || u.PendingEmail != "" || !u.EmailVerified {
+		t.Errorf("email should change to %q \t but get %+v", newEmail, u)
+	}
+	userEmail = newEmail
+}
+
+func TestUpdateProfileImage(t *testing.T) {
+	userController := newUserController()
+	//the small limit for too large case
+	limitedController := newUserController()
+	limitedController.Media.MaxImageSize = 100
+	u, err := testingProvider.Stores.Users.FindByEmail(userEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 600, 400)))
+	validImage := buf.Bytes()
+	cases := []struct {
+		controller    *UserController
+		field         string
+		content       []byte
+		expectedError error
+	}{
+		{controller: userController, field: "file", content: validImage, expectedError: specialerror.ErrImageIsNotValid},
+		{controller: userController, field: PROFILE_IMAGE_FORM_FIELD, content: []byte("GIF89a not supported image"), expectedError: specialerror.ErrImageIsNotValid},
+		{controller: limitedController, field: PROFILE_IMAGE_FORM_FIELD, content: validImage, expectedError: specialerror.ErrImageIsTooLarge},
+		{controller: userController, field: PROFILE_IMAGE_FORM_FIELD, content: validImage, expectedError: nil},
+		{controller: userController, field: PROFILE_IMAGE_FORM_FIELD, content: validImage, expectedError: nil},
+	}
+	previous := ""
+	for _, c := range cases {
+		res := test.NewResponseRecorder()
+		if err := profileImageForTest(c.controller, res, u.Id, c.field, c.content); err != c.expectedError {
+			t.Errorf("Error should %q 	 but get %v", c.expectedError, err)
+		}
+		if c.expectedError != nil {
+			continue
+		}
+		result := models.ProfileImage{}
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if u, _ = testingProvider.Stores.Users.FindById(u.Id); u.ImageFileName != result.ImageFileName {
+			t.Errorf("Error should %v 	 but get %v", result.ImageFileName, u.ImageFileName)
+		}
+		for _, size := range profileImageSizes {
+			if _, _, err := testingProvider.Storage.Get(ProfileImageVariant(u.ImageFileName, size)); err != nil {
+				t.Errorf("the variant %d should be saved 	 but get %v", size, err)
+			}
+			//the previous image removed
+			if previous != "" {
+				if _, _, err := testingProvider.Storage.Get(ProfileImageVariant(previous, size)); err != blobstorage.ErrNotFound {
+					t.Errorf("Error should %v 	 but get %v", blobstorage.ErrNotFound, err)
+				}
+			}
+		}
+		previous = u.ImageFileName
+	}
+}
+
+func TestOAuth2(t *testing.T) {
+	userController := newUserController()
+	redirectURI := "https://app.example.com/callback"
+	confidentialClient := models.Client{AppId: bson.NewObjectId(), AppKey: "oauth-client-key", Name: "integration", IsEnable: true, PlatformType: "android", RedirectURIs: []string{redirectURI}, AllowedScopes: []string{models.ARTICLES_READ_SCOPE, models.ARTICLES_WRITE_SCOPE}}
+	webClient := models.Client{AppId: bson.NewObjectId(), Name: "web integration", IsEnable: true, PlatformType: client.WEB_PLATFORM_TYPE, RedirectURIs: []string{redirectURI}, AllowedScopes: []string{models.ARTICLES_READ_SCOPE}}
+	testingProvider.Stores.Clients.Insert(&confidentialClient)
+	testingProvider.Stores.Clients.Insert(&webClient)
+	clientId, clientSecret := confidentialClient.AppId.Hex(), confidentialClient.HashedAppKey()
+	u := models.User{Id: bson.NewObjectId(), Email: "oauth.user@gmail.com", DisplayName: "OAuth", IsEnable: true, Roles: []string{models.USER_ROLE}}
+	testingProvider.Stores.Users.Insert(&u)
+	verifier := "dBjftJeZ4CVP-mJ92ZoQmGjYV4cpR9XfSZsLqB9s8Fk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	authorizeQuery := func(clientId, redirect, scope, method string) url.Values {
+		return url.Values{"response_type": {"code"}, "client_id": {clientId}, "redirect_uri": {redirect}, "scope": {scope}, "state": {"xyz"}, "code_challenge": {challenge}, "code_challenge_method": {method}}
+	}
+	consentCases := []struct {
+		query         url.Values
+		expectedError error
+	}{
+		{authorizeQuery(clientId, redirectURI, "articles:read", "S256"), nil},
+		{authorizeQuery(clientId, redirectURI, "", "S256"), nil},
+		{authorizeQuery(clientId, "https://evil.example.com/callback", "articles:read", "S256"), specialerror.ErrRedirectURIIsNotValid},
+		{authorizeQuery(clientId, redirectURI, "articles:read comments:write", "S256"), specialerror.ErrScopeIsNotAllowed},
+		{authorizeQuery(clientId, redirectURI, "articles:read", "plain"), specialerror.ErrNotValidQueryParameter},
+		{authorizeQuery("not-valid", redirectURI, "articles:read", "S256"), specialerror.ErrNotValidClientInformation},
+		{authorizeQuery(bson.NewObjectId().Hex(), redirectURI, "articles:read", "S256"), specialerror.ErrClientIsNotValidToCommunicate},
+	}
+	for i, c := range consentCases {
+		req := test.NewRequest(echo.GET, "/oauth/authorize?"+c.query.Encode(), nil)
+		res := test.NewResponseRecorder()
+		if err := userController.GetAuthorizeConsent(echo.NewContext(req, res, testingProvider.Echo)); err != c.expectedError {
+			t.Errorf("case %d: Error should %v \t but get %v", i, c.expectedError, err)
+		}
+		if c.expectedError == nil {
+			consent := models.ConsentResponse{}
+			json.NewDecoder(res.Body).Decode(&consent)
+			if consent.ClientName != confidentialClient.Name || len(consent.Scopes) == 0 || consent.State != "xyz" {
+				t.Errorf("case %d: consent is not valid %+v", i, consent)
+			}
+		}
+	}
+	//the answer of user is sent to the redirect URI
+	authorize := func(clientId, scope string, approve bool) url.Values {
+		reqBody, _ := json.Marshal(models.AuthorizeRequest{ResponseType: "code", ClientId: clientId, RedirectURI: redirectURI, Scope: scope, State: "xyz", CodeChallenge: challenge, CodeChallengeMethod: "S256", Approve: approve})
+		req := test.NewRequest(echo.POST, "/oauth/authorize", bytes.NewReader(reqBody))
+		req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		context.Set(USER_ID_KEY, u.Id)
+		if err := userController.Authorize(context); err != nil {
+			t.Fatalf("Error should %v \t but get %v", nil, err)
+		}
+		authorizeResponse := models.AuthorizeResponse{}
+		json.NewDecoder(res.Body).Decode(&authorizeResponse)
+		redirectTo, _ := url.Parse(authorizeResponse.RedirectTo)
+		if !strings.HasPrefix(authorizeResponse.RedirectTo, redirectURI+"?") || redirectTo.Query().Get("state") != "xyz" {
+			t.Fatalf("redirect is not valid %s", authorizeResponse.RedirectTo)
+		}
+		return redirectTo.Query()
+	}
+	if denied := authorize(clientId, "articles:read", false); denied.Get("error") != ACCESS_DENIED_OAUTH_ERROR || denied.Get("code") != "" {
+		t.Errorf("denied redirect should have error \t but get %v", denied)
+	}
+	firstCode := authorize(clientId, "articles:read", true).Get("code")
+	secondCode := authorize(clientId, "articles:read", true).Get("code")
+	thirdCode := authorize(clientId, "articles:read", true).Get("code")
+	webCode := authorize(webClient.AppId.Hex(), "", true).Get("code")
+	//JSON or form request of token endpoint
+	issueToken := func(form url.Values, asForm bool) (*models.TokenResponse, error) {
+		var req engine.Request
+		if asForm {
+			req = test.NewRequest(echo.POST, "/oauth/token", strings.NewReader(form.Encode()))
+			req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		} else {
+			reqBody, _ := json.Marshal(models.TokenRequest{GrantType: form.Get("grant_type"), ClientId: form.Get("client_id"), ClientSecret: form.Get("client_secret"), Code: form.Get("code"), RedirectURI: form.Get("redirect_uri"), CodeVerifier: form.Get("code_verifier"), RefreshToken: form.Get("refresh_token"), Scope: form.Get("scope")})
+			req = test.NewRequest(echo.POST, "/oauth/token", bytes.NewReader(reqBody))
+			req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		}
+		res := test.NewResponseRecorder()
+		if err := userController.IssueToken(echo.NewContext(req, res, testingProvider.Echo)); err != nil {
+			return nil, err
+		}
+		if res.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("token response should not be cached")
+		}
+		tokenResponse := models.TokenResponse{}
+		return &tokenResponse, json.NewDecoder(res.Body).Decode(&tokenResponse)
+	}
+	codeForm := func(clientId, secret, code, redirect, verifier string) url.Values {
+		return url.Values{"grant_type": {"authorization_code"}, "client_id": {clientId}, "client_secret": {secret}, "code": {code}, "redirect_uri": {redirect}, "code_verifier": {verifier}}
+	}
+	tokenCases := []struct {
+		form          url.Values
+		asForm        bool
+		expectedError error
+	}{
+		{url.Values{"grant_type": {"password"}, "client_id": {clientId}}, true, specialerror.ErrSomeFieldAreNotValid},
+		{codeForm(clientId, "wrong secret", firstCode, redirectURI, verifier), true, specialerror.ErrClientIsNotValidToCommunicate},
+		//the code is used once, even with wrong verifier
+		{codeForm(clientId, clientSecret, firstCode, redirectURI, verifier+"wrong"), true, specialerror.ErrAuthorizationCodeIsNotValid},
+		{codeForm(clientId, clientSecret, firstCode, redirectURI, verifier), true, specialerror.ErrAuthorizationCodeIsNotValid},
+		//the code of other client
+		{codeForm(webClient.AppId.Hex(), "", thirdCode, redirectURI, verifier), false, specialerror.ErrAuthorizationCodeIsNotValid},
+		{codeForm(clientId, clientSecret, "", redirectURI, verifier), false, specialerror.ErrAuthorizationCodeIsNotValid},
+		{url.Values{"grant_type": {"client_credentials"}, "client_id": {webClient.AppId.Hex()}}, false, specialerror.ErrGrantTypeIsNotAllowed},
+		{url.Values{"grant_type": {"client_credentials"}, "client_id": {clientId}, "client_secret": {clientSecret}, "scope": {"account"}}, false, specialerror.ErrScopeIsNotAllowed},
+		{url.Values{"grant_type": {"refresh_token"}, "client_id": {clientId}, "client_secret": {clientSecret}, "refresh_token": {"not valid"}}, false, specialerror.ErrRefreshTokenIsNotValid},
+	}
+	for i, c := range tokenCases {
+		if _, err := issueToken(c.form, c.asForm); err != c.expectedError {
+			t.Errorf("case %d: Error should %v \t but get %v", i, c.expectedError, err)
+		}
+	}
+	webToken, err := issueToken(codeForm(webClient.AppId.Hex(), "", webCode, redirectURI, verifier), false)
+	if err != nil || webToken.Scope != models.ARTICLES_READ_SCOPE {
+		t.Errorf("web client should get token with its allowed scopes \t but get %v %+v", err, webToken)
+	}
+	token, err := issueToken(codeForm(clientId, clientSecret, secondCode, redirectURI, verifier), true)
+	if err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	if token.TokenType != BEARER_AUTHENTICATION_TYPE || token.RefreshToken == "" || token.ExpiresIn <= 0 || token.Scope != models.ARTICLES_READ_SCOPE {
+		t.Errorf("token response is not valid %+v", token)
+	}
+	//the token only have the approved scopes
+	jwt := JWTAuthenticationMiddleware(testingProvider.Stores.Users, testingProvider.Stores.AccessTokens, testingProvider.Keys, testingProvider.Policy)(RequireScope(models.ARTICLES_READ_SCOPE)(func(c echo.Context) error {
+		if c.Get(USER_ID_KEY) != u.Id {
+			t.Errorf("user id should %v \t but get %v", u.Id, c.Get(USER_ID_KEY))
+		}
+		return RequireScope(models.ARTICLES_WRITE_SCOPE)(func(c echo.Context) error { return nil })(c)
+	}))
+	authenticate := func(accessToken string) error {
+		req := test.NewRequest(echo.GET, "/", nil)
+		req.Header().Set(echo.HeaderAuthorization, BEARER_AUTHENTICATION_TYPE+" "+accessToken)
+		return jwt(echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo))
+	}
+	if err := authenticate(token.AccessToken); err != specialerror.ErrInsufficientScope {
+		t.Errorf("Error should %v \t but get %v", specialerror.ErrInsufficientScope, err)
+	}
+	if parsed, err := testingProvider.Keys.Parse(token.AccessToken); err != nil || parsed.Claims["scope"] != models.ARTICLES_READ_SCOPE {
+		t.Errorf("scope claim should %v \t but get %v %v", models.ARTICLES_READ_SCOPE, err, parsed)
+	}
+	//the refreshed token can't have more scopes than approved
+	if _, err := issueToken(url.Values{"grant_type": {"refresh_token"}, "client_id": {clientId}, "client_secret": {clientSecret}, "refresh_token": {token.RefreshToken}, "scope": {"articles:read articles:write"}}, true); err != specialerror.ErrScopeIsNotAllowed {
+		t.Errorf("Error should %v \t but get %v", specialerror.ErrScopeIsNotAllowed, err)
+	}
+	//the token is refreshed by its trusted app
+	refreshed, err := issueToken(url.Values{"grant_type": {"refresh_token"}, "client_id": {clientId}, "client_secret": {clientSecret}, "refresh_token": {token.RefreshToken}}, true)
+	if err != nil || refreshed.RefreshToken == token.RefreshToken || refreshed.Scope != models.ARTICLES_READ_SCOPE {
+		t.Errorf("refresh token should be rotated \t but get %v %+v", err, refreshed)
+	}
+	devices, _ := testingProvider.Stores.Users.FindById(u.Id)
+	if len(devices.TrustedApps) != 2 || fmt.Sprint(devices.TrustedApps[1].Scopes) != "[articles:read]" {
+		t.Errorf("user should have trusted app for each client \t but get %d", len(devices.TrustedApps))
+	}
+	//the token of client itself doesn't act as user
+	clientToken, err := issueToken(url.Values{"grant_type": {"client_credentials"}, "client_id": {clientId}, "client_secret": {clientSecret}}, true)
+	if err != nil || clientToken.RefreshToken != "" || clientToken.Scope != "articles:read articles:write" {
+		t.Errorf("client token is not valid %v %+v", err, clientToken)
+	} else if err := authenticate(clientToken.AccessToken); err != specialerror.ErrUnauthorized {
+		t.Errorf("Error should %v \t but get %v", specialerror.ErrUnauthorized, err)
+	}
+}
+
+func TestManageUsers(t *testing.T) {
+	userController := newUserController()
+	adminId := bson.NewObjectId()
+	auth := signInForTest(t, newPassword)
+	u, err := testingProvider.Stores.Users.FindByEmail(userEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := u.Id.Hex()
+	//search users
+	res := test.NewResponseRecorder()
+	context := echo.NewContext(test.NewRequest(echo.GET, "/api/manage/user?q=TAHANI&enabled=true", nil), res, testingProvider.Echo)
+	if err := userController.GetUsers(context); err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	page := struct {
+		Items []models.User `json:"items"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Id != u.Id || page.Items[0].HashedPassword != "" {
+		t.Errorf("users should have the test user without password \t but get %+v", page.Items)
+	}
+	//one user with its trusted apps
+	res = test.NewResponseRecorder()
+	if err := manageUserForTest(userController.GetUserById, echo.GET, "/api/manage/user/:id", id, adminId, nil, res); err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	detail := struct {
+		Email       string          `json:"email"`
+		TrustedApps []models.Device `json:"trusted_apps"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Email != userEmail || len(detail.TrustedApps) == 0 {
+		t.Errorf("user should have its trusted apps \t but get %+v", detail)
+	}
+	cases := []struct {
+		handler       echo.HandlerFunc
+		method        string
+		path          string
+		id            string
+		currentUserId bson.ObjectId
+		body          string
+		expectedError error
+	}{
+		{handler: userController.DisableUser, method: echo.POST, path: "/api/manage/user/:id/disable", id: "someinvalid", currentUserId: adminId, expectedError: specialerror.ErrNotValidItemId},
+		{handler: userController.DisableUser, method: echo.POST, path: "/api/manage/user/:id/disable", id: bson.NewObjectId().Hex(), currentUserId: adminId, expectedError: specialerror.ErrNotFoundAnyItemWithThisId},
+		{handler: userController.DisableUser, method: echo.POST, path: "/api/manage/user/:id/disable", id: id, currentUserId: u.Id, expectedError: specialerror.ErrCanNotManageYourself},
+		{handler: userController.DeleteUser, method: echo.DELETE, path: "/api/manage/user/:id", id: id, currentUserId: u.Id, expectedError: specialerror.ErrCanNotManageYourself},
+		{handler: userController.UpdateUserRoles, method: echo.PUT, path: "/api/manage/user/:id/roles", id: id, currentUserId: u.Id, body: `{"roles":["admin"]}`, expectedError: specialerror.ErrCanNotManageYourself},
+		{handler: userController.UpdateUserRoles, method: echo.PUT, path: "/api/manage/user/:id/roles", id: id, currentUserId: adminId, body: `{"roles":["user","owner"]}`, expectedError: specialerror.ErrSomeFieldAreNotValid},
+		{handler: userController.UpdateUserRoles, method: echo.PUT, path: "/api/manage/user/:id/roles", id: id, currentUserId: adminId, body: `{"roles":[]}`, expectedError: specialerror.ErrSomeFieldAreNotValid},
+		{handler: userController.DisableUser, method: echo.POST, path: "/api/manage/user/:id/disable", id: id, currentUserId: adminId, expectedError: nil},
+	}
+	for _, c := range cases {
+		if err := manageUserForTest(c.handler, c.method, c.path, c.id, c.currentUserId, []byte(c.body), test.NewResponseRecorder()); err != c.expectedError {
+			t.Errorf("Error should %v \t but get %v", c.expectedError, err)
+		}
+	}
+	//the disabled user signed out right now and can't get new token
+	if _, err := testingProvider.Stores.AccessTokens.FindByToken(auth.AccessToken); err != store.ErrNotFound {
+		t.Errorf("Error should %q \t but get %v", store.ErrNotFound, err)
+	}
+	if _, err := refreshForTest(userController, auth.RefreshToken); err != specialerror.ErrUserIsDisable {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrUserIsDisable, err)
+	}
+	if _, err := signInWithErrorForTest(newPassword); err != specialerror.ErrUserIsDisable {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrUserIsDisable, err)
+	}
+	if err := manageUserForTest(userController.EnableUser, echo.POST, "/api/manage/user/:id/enable", id, adminId, nil, test.NewResponseRecorder()); err != nil {
+		t.Errorf("Error should %v \t but get %v", nil, err)
+	}
+	//adding role keeps the access token but removing it revokes the token
+	auth = signInForTest(t, newPassword)
+	rolesCases := []struct {
+		body          string
+		expectedRoles []string
+		revoked       bool
+	}{
+		{body: `{"roles":["user"," Admin"]}`, expectedRoles: []string{models.USER_ROLE, models.ADMIN_ROLE}, revoked: false},
+		{body: `{"roles":["user"]}`, expectedRoles: []string{models.USER_ROLE}, revoked: true},
+	}
+	for _, c := range rolesCases {
+		if err := manageUserForTest(userController.UpdateUserRoles, echo.PUT, "/api/manage/user/:id/roles", id, adminId, []byte(c.body), test.NewResponseRecorder()); err != nil {
+			t.Errorf("Error should %v \t but get %v", nil, err)
+		}
+		if u, _ := testingProvider.Stores.Users.FindById(u.Id); fmt.Sprint(u.Roles) != fmt.Sprint(c.expectedRoles) {
+			t.Errorf("roles should %v \t but get %v", c.expectedRoles, u.Roles)
+		}
+		if _, err := testingProvider.Stores.AccessTokens.FindByToken(auth.AccessToken); (err == store.ErrNotFound) != c.revoked {
+			t.Errorf("revoking the access token should be %t \t but get %v", c.revoked, err)
+		}
+	}
+	//force password reset
+	auth = signInForTest(t, newPassword)
+	if err := manageUserForTest(userController.ForcePasswordReset, echo.POST, "/api/manage/user/:id/password/reset", id, adminId, nil, test.NewResponseRecorder()); err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	if _, err := refreshForTest(userController, auth.RefreshToken); err != specialerror.ErrRefreshTokenIsNotValid {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrRefreshTokenIsNotValid, err)
+	}
+	if _, err := signInWithErrorForTest(newPassword); err != specialerror.ErrPasswordResetRequired {
+		t.Errorf("Error should %q \t but get %v", specialerror.ErrPasswordResetRequired, err)
+	}
+	msg := testingProvider.MailBox.LastMessageTo(userEmail)
+	token := regexp.MustCompile(`token=([0-9a-f]+)`).FindStringSubmatch(msg.Body)
+	if token == nil {
+		t.Fatalf("reset password email should have token \t but get %q", msg.Body)
+	}
+	reqBody, _ := json.Marshal(models.ResetPasswordRequest{AppId: newAppIdStr, Token: token[1], Password: newPassword})
+	req := test.NewRequest(echo.POST, "/auth/password/reset", bytes.NewReader(reqBody))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	if err := userController.ResetPassword(echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)); err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	signInForTest(t, newPassword)
+	//delete other user, its articles moved to trash
+	other := &models.User{Id: bson.NewObjectId(), Email: "other.user@gmail.com", IsEnable: true, Roles: []string{models.USER_ROLE}, JoinedAt: time.Now()}
+	if err := testingProvider.Stores.Users.Insert(other); err != nil {
+		t.Fatal(err)
+	}
+	article := &models.Article{Id: bson.NewObjectId(), Title: "title", Content: "content", UserId: other.Id, Version: 1, Status: models.DRAFT_STATUS}
+	if err := testingProvider.Stores.Articles.Insert(article); err != nil {
+		t.Fatal(err)
+	}
+	if err := manageUserForTest(userController.DeleteUser, echo.DELETE, "/api/manage/user/:id", other.Id.Hex(), adminId, nil, test.NewResponseRecorder()); err != nil {
+		t.Errorf("Error should %v \t but get %v", nil, err)
+	}
+	if _, err := testingProvider.Stores.Users.FindById(other.Id); err != store.ErrNotFound {
+		t.Errorf("Error should %q \t but get %v", store.ErrNotFound, err)
+	}
+	if _, err := testingProvider.Stores.Articles.FindById(article.Id); err != store.ErrNotFound {
+		t.Errorf("Error should %q \t but get %v", store.ErrNotFound, err)
+	}
+}
+
+//call the manage handler with id parameter as current user
+func manageUserForTest(handler echo.HandlerFunc, method, path, id string, currentUserId bson.ObjectId, body []byte, res *test.ResponseRecorder) error {
+	testingProvider.Router.Add(method, path, nil, testingProvider.Echo)
+	fullPath := strings.Replace(path, ":id", id, 1)
+	req := test.NewRequest(method, fullPath, bytes.NewReader(body))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	context := echo.NewContext(req, res, testingProvider.Echo)
+	testingProvider.Router.Find(method, fullPath, context)
+	context.Set(USER_ID_KEY, currentUserId)
+	return handler(context)
+}
+
+//upload the content as multipart form
+func profileImageForTest(userController *UserController, res *test.ResponseRecorder, userId bson.ObjectId, field string, content []byte) error {
+	body := bytes.Buffer{}
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile(field, "image.png")
+	part.Write(content)
+	w.Close()
+	req := test.NewRequest(echo.PUT, "/api/user/profile/image", &body)
+	req.Header().Set(echo.HeaderContentType, w.FormDataContentType())
+	context := echo.NewContext(req, res, testingProvider.Echo)
+	context.Set(USER_ID_KEY, userId)
+	return userController.UpdateProfileImage(context)
+}
+
+//the token of last verification email sent to this address
+func verificationTokenForTest(t *testing.T, email string) string {
+	msg := testingProvider.MailBox.LastMessageTo(email)
+	if msg == nil || msg.Subject != EMAIL_VERIFICATION_EMAIL_SUBJECT {
+		t.Fatalf("verification email didn't send to %s", email)
+	}
+	token := regexp.MustCompile(`token=([0-9a-f]+)`).FindStringSubmatch(msg.Body)
+	if token == nil {
+		t.Fatalf("verification email should have token \t but get %q", msg.Body)
+	}
+	return token[1]
+}
+
+func verifyEmailForTest(userController *UserController, token string) error {
+	reqBody, _ := json.Marshal(models.VerifyEmailRequest{AppId: newAppIdStr, Token: token})
+	req := test.NewRequest(echo.POST, "/auth/email/verify", bytes.NewReader(reqBody))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	context := echo.NewContext(req, test.NewResponseRecorder(), testingProvider.Echo)
+	return userController.VerifyEmail(context)
+}
+
+//refresh the access token of the test client
+func refreshForTest(userController *UserController, refreshToken string) (*models.AuthenticationResponse, error) {
+	reqBody, _ := json.Marshal(models.RefreshTokenRequest{AppId: newAppIdStr, RefreshToken: refreshToken})
+	req := test.NewRequest(echo.POST, "/auth/token/refresh", bytes.NewReader(reqBody))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	res := test.NewResponseRecorder()
+	context := echo.NewContext(req, res, testingProvider.Echo)
+	if err := userController.RefreshAccessToken(context); err != nil {
+		return nil, err
+	}
+	auth := models.AuthenticationResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+//sign in with the test user and return the authentication response
+func signInForTest(t *testing.T, password string) models.AuthenticationResponse {
+	auth, err := signInWithErrorForTest(password)
+	if err != nil {
+		t.Fatalf("can't sign in for test %v", err)
+	}
+	return *auth
+}
+
+//sign in with the test user and return the error of sign in
+func signInWithErrorForTest(password string) (*models.AuthenticationResponse, error) {
+	reqBody, _ := json.Marshal(models.SignInRequest{AppId: newAppIdStr, Email: userEmail, Password: password})
+	req := test.NewRequest(echo.POST, "/auth/singin", bytes.NewReader(reqBody))
+	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	res := test.NewResponseRecorder()
+	context := echo.NewContext(req, res, testingProvider.Echo)
+	if err := newUserController().SignIn(context); err != nil {
+		return nil, err
+	}
+	auth := models.AuthenticationResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+//user controller with stores of testing provider
+func newUserController() *UserController {
+	return NewUserController(testingProvider.Stores, testingProvider.Config, testingProvider.Keys, testingProvider.MailBox, testingProvider.Storage, testingProvider.Policy)
+}
+
 //create new client in db just for test
-func createNewClientInDB(s *mgo.Session, e *echo.Echo) (*models.Client, error) {
-	//copy db session
-	session := s.Copy()
-	defer session.Close()
+func createNewClientInDB(clients store.ClientStore, e *echo.Echo) (*models.Client, error) {
 	//add new client via function inside the manage_by_admin.go
-	clientController := client.NewClientController(session, testhelper.DB_TEST_NAME)
+	clientController := client.NewClientController(clients)
 	req := test.NewRequest(echo.POST, "/api/manage/client", bytes.NewBuffer([]byte(`{"name":"new client just for test"}`)))
 	req.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
 	res := test.NewResponseRecorder()
@@ -534,8 +1290,8 @@ func TestMain(m *testing.M) {
 	testingProvider = testhelper.TestingProvider{}
 	testingProvider.StartTesting()
 	//create the new client to signUp
-	if cli, err := createNewClientInDB(testingProvider.Session, testingProvider.Echo); err != nil {
-		fmt.Println("error happend in creating client !\n%s", err)
+	if cli, err := createNewClientInDB(testingProvider.Stores.Clients, testingProvider.Echo); err != nil {
+		fmt.Printf("error happend in creating client !\n%s", err)
 	} else {
 		newAppIdStr = string(cli.AppId.Hex())
 	}
@@ -0,0 +1,58 @@
+package wellknown
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/util/jwtkey"
+)
+
+const (
+	JWKS_PATH = "/.well-known/jwks.json"
+	OPENID_CONFIGURATION_PATH = "/.well-known/openid-configuration"
+	TOKEN_ENDPOINT_PATH = "/oauth/token"
+	AUTHORIZATION_ENDPOINT_PATH = "/oauth/authorize"
+	SIGN_IN_ENDPOINT_PATH = "/auth/singin"
+	//the public keys rarely change, clients can cache them for one hour
+	CACHE_CONTROL_VALUE = "public, max-age=3600"
+)
+
+//publish the public signing keys and the provider metadata so other services can verify access tokens
+type WellKnownController struct {
+	JWT  config.JWTConfig
+	Keys *jwtkey.Manager
+}
+
+func NewWellKnownController(jwtConfig config.JWTConfig, keys *jwtkey.Manager) *WellKnownController {
+	return &WellKnownController{jwtConfig, keys}
+}
+
+func (wc WellKnownController) GetJWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", CACHE_CONTROL_VALUE)
+	c.JSON(http.StatusOK, wc.Keys.JWKS())
+	return nil
+}
+
+func (wc WellKnownController) GetOpenIDConfiguration(c echo.Context) error {
+	issuer := strings.TrimRight(wc.JWT.Issuer, "/")
+	c.Response().Header().Set("Cache-Control", CACHE_CONTROL_VALUE)
+	c.JSON(http.StatusOK, models.OpenIDConfiguration{
+		Issuer:                           issuer,
+		JWKSURI:                          issuer + JWKS_PATH,
+		TokenEndpoint:                    issuer + TOKEN_ENDPOINT_PATH,
+		AuthorizationEndpoint:            issuer + AUTHORIZATION_ENDPOINT_PATH,
+		SignInEndpoint:                   issuer + SIGN_IN_ENDPOINT_PATH,
+		GrantTypesSupported:              []string{models.AUTHORIZATION_CODE_GRANT_TYPE, models.REFRESH_TOKEN_GRANT_TYPE, models.CLIENT_CREDENTIALS_GRANT_TYPE},
+		ResponseTypesSupported:           []string{models.CODE_RESPONSE_TYPE},
+		ScopesSupported:                  models.Scopes,
+		CodeChallengeMethodsSupported:    []string{models.S256_CODE_CHALLENGE_METHOD},
+		SubjectTypesSupported:            []string{"public"},
+		IdTokenSigningAlgValuesSupported: wc.Keys.Algorithms(),
+		ClaimsSupported:                  []string{"iss", "aud", "sub", "iat", "nbf", "exp", "uid", "roles", "name", "img", "aid", "cid", "tid", "scope"},
+	})
+	return nil
+}
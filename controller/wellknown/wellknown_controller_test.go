@@ -0,0 +1,134 @@
+package wellknown
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/test"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/util/jwtkey"
+	"github.com/atahani/golang-rest-api-sample/util/testhelper"
+)
+
+var testingProvider testhelper.TestingProvider
+
+//manager with active RSA key, verify ECDSA key and retired HMAC key
+func newRotatingManager(t *testing.T) *jwtkey.Manager {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := jwtkey.NewManager([]*jwtkey.Key{
+		{Id: "old", Method: jwt.SigningMethodHS256, Status: config.JWT_KEY_RETIRED_STATUS},
+		{Id: "ec", Method: jwt.SigningMethodES256, Status: config.JWT_KEY_VERIFY_STATUS, VerifyKey: &ecKey.PublicKey},
+		{Id: "rsa", Method: jwt.SigningMethodRS256, Status: config.JWT_KEY_ACTIVE_STATUS, SignKey: rsaKey, VerifyKey: &rsaKey.PublicKey},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestGetJWKS(t *testing.T) {
+	cases := []struct {
+		keys         *jwtkey.Manager
+		expectedKids []string
+	}{
+		{
+			//the HS256 secret never published
+			keys:         testingProvider.Keys,
+			expectedKids: []string{},
+		},
+		{
+			keys:         newRotatingManager(t),
+			expectedKids: []string{"ec", "rsa"},
+		},
+	}
+	for _, c := range cases {
+		wellKnownController := NewWellKnownController(testingProvider.Config.JWT, c.keys)
+		req := test.NewRequest(echo.GET, JWKS_PATH, nil)
+		res := test.NewResponseRecorder()
+		context := echo.NewContext(req, res, testingProvider.Echo)
+		if err := wellKnownController.GetJWKS(context); err != nil {
+			t.Errorf("Error should %v \t but get %q", nil, err)
+		}
+		set := models.JSONWebKeySet{}
+		if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(kids(set)) != fmt.Sprint(c.expectedKids) {
+			t.Errorf("kids should %v \t but get %v", c.expectedKids, kids(set))
+		}
+		for _, key := range set.Keys {
+			switch key.KeyType {
+			case "RSA":
+				if key.Algorithm != "RS256" || key.N == "" || key.E != "AQAB" {
+					t.Errorf("RSA key is not valid %+v", key)
+				}
+			case "EC":
+				//P-256 coordinates are 32 bytes, 43 characters in base64url
+				if key.Algorithm != "ES256" || key.Curve != "P-256" || len(key.X) != 43 || len(key.Y) != 43 {
+					t.Errorf("EC key is not valid %+v", key)
+				}
+			default:
+				t.Errorf("key type should be RSA or EC \t but get %q", key.KeyType)
+			}
+		}
+	}
+}
+
+func kids(set models.JSONWebKeySet) []string {
+	result := []string{}
+	for _, key := range set.Keys {
+		result = append(result, key.KeyId)
+	}
+	return result
+}
+
+func TestGetOpenIDConfiguration(t *testing.T) {
+	jwtConfig := testingProvider.Config.JWT
+	jwtConfig.Issuer = "https://api.example.com/"
+	wellKnownController := NewWellKnownController(jwtConfig, newRotatingManager(t))
+	req := test.NewRequest(echo.GET, OPENID_CONFIGURATION_PATH, nil)
+	res := test.NewResponseRecorder()
+	context := echo.NewContext(req, res, testingProvider.Echo)
+	if err := wellKnownController.GetOpenIDConfiguration(context); err != nil {
+		t.Errorf("Error should %v \t but get %q", nil, err)
+	}
+	metadata := models.OpenIDConfiguration{}
+	if err := json.NewDecoder(res.Body).Decode(&metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Issuer != "https://api.example.com" || metadata.JWKSURI != "https://api.example.com/.well-known/jwks.json" || metadata.TokenEndpoint != "https://api.example.com/oauth/token" || metadata.AuthorizationEndpoint != "https://api.example.com/oauth/authorize" || metadata.SignInEndpoint != "https://api.example.com/auth/singin" {
+		t.Errorf("the endpoints are not valid %+v", metadata)
+	}
+	if fmt.Sprint(metadata.IdTokenSigningAlgValuesSupported) != "[ES256 RS256]" {
+		t.Errorf("algorithms should %v \t but get %v", "[ES256 RS256]", metadata.IdTokenSigningAlgValuesSupported)
+	}
+	//only the grant types of token endpoint, the sign in is not an OAuth2 grant
+	if fmt.Sprint(metadata.GrantTypesSupported) != "[authorization_code refresh_token client_credentials]" {
+		t.Errorf("grant types should %v \t but get %v", "[authorization_code refresh_token client_credentials]", metadata.GrantTypesSupported)
+	}
+}
+
+func TestMain(m *testing.M) {
+	//start of testing
+	testingProvider = testhelper.TestingProvider{}
+	testingProvider.StartTesting()
+	ret := m.Run()
+	os.Exit(ret)
+}
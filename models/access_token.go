@@ -6,9 +6,13 @@ import (
 )
 
 type AccessToken struct {
-	Id           bson.ObjectId    `bson:"_id"`
-	UserId       bson.ObjectId    `bson:"user_id"`
-	TrustedAppId bson.ObjectId    `bson:"trusted_app_id"`
-	Token        string           `bson:"token"`
-	ExpireAt     time.Time        `bson:"expire_at"`
+	Id           bson.ObjectId `bson:"_id"`
+	//the tokens of client credentials grant don't have user and trusted app
+	UserId       bson.ObjectId `bson:"user_id,omitempty"`
+	TrustedAppId bson.ObjectId `bson:"trusted_app_id,omitempty"`
+	ClientId     bson.ObjectId `bson:"client_id,omitempty"`
+	//the routes check them, the tokens issued before scopes have all of them
+	Scopes       []string      `bson:"scopes,omitempty"`
+	Token        string        `bson:"token"`
+	ExpireAt     time.Time     `bson:"expire_at"`
 }
\ No newline at end of file
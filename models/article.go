@@ -5,11 +5,115 @@ import (
 	"time"
 )
 
+const (
+	//only the owner can see the article, the articles without visibility are private
+	PRIVATE_VISIBILITY = "private"
+	//anyone that has the id can see the article but it's not listed
+	UNLISTED_VISIBILITY = "unlisted"
+	//listed in the public articles of user
+	PUBLIC_VISIBILITY = "public"
+	//only the owner can see the draft articles
+	DRAFT_STATUS = "draft"
+	//published by the scheduler at scheduled_for
+	SCHEDULED_STATUS = "scheduled"
+	//the others can see the published articles base on visibility, the articles without status are published
+	PUBLISHED_STATUS = "published"
+	ARCHIVED_STATUS = "archived"
+	//the collaborator can see the article even if it's private or draft
+	VIEWER_COLLABORATOR_ROLE = "viewer"
+	//the collaborator can see and update the title, content and tags of article
+	EDITOR_COLLABORATOR_ROLE = "editor"
+)
+
 type Article struct {
-	Id        bson.ObjectId        `json:"id" bson:"_id"`
-	Title     string               `valid:"required" json:"title" bson:"title"`
-	Content   string               `valid:"required" json:"content" bson:"content"`
-	UserId    bson.ObjectId        `json:"user_id" bson:"user_id"`
-	CreatedAt time.Time            `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time            `json:"updated_at" bson:"updated_at"`
+	Id            bson.ObjectId  `json:"id" bson:"_id"`
+	//the id of article in the import file, it's unique in articles of user
+	ExternalId    string         `json:"external_id,omitempty" bson:"external_id,omitempty"`
+	Title         string         `valid:"required" json:"title" bson:"title"`
+	Content       string         `valid:"required" json:"content" bson:"content"`
+	Visibility    string         `valid:"in(private|unlisted|public)" json:"visibility" bson:"visibility,omitempty"`
+	//normalized by the binder, nil tags in update don't change the tags
+	Tags          []string       `valid:"tags" json:"tags,omitempty" bson:"tags,omitempty"`
+	UserId        bson.ObjectId  `json:"user_id" bson:"user_id"`
+	//increased by each update, the ETag of article
+	Version       int            `json:"version" bson:"version"`
+	//changed by publish, unpublish and archive
+	Status        string         `json:"status" bson:"status,omitempty"`
+	PublishedAt   *time.Time     `json:"published_at,omitempty" bson:"published_at,omitempty"`
+	ScheduledFor  *time.Time     `json:"scheduled_for,omitempty" bson:"scheduled_for,omitempty"`
+	//the number of comments that are not hidden
+	CommentCount  int            `json:"comment_count" bson:"comment_count"`
+	//the users that article shared with, only the owner changes them
+	Collaborators []Collaborator `json:"collaborators,omitempty" bson:"collaborators,omitempty"`
+	//the article is in trash, it's purged after the retention
+	DeletedAt     *time.Time     `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" bson:"updated_at"`
+}
+
+//the user that an article shared with
+type Collaborator struct {
+	UserId   bson.ObjectId `json:"user_id" bson:"user_id"`
+	Role     string        `json:"role" bson:"role"`
+	SharedAt time.Time     `json:"shared_at" bson:"shared_at"`
+}
+
+//the collaborator with its public information, it's used only for JSON response
+type CollaboratorDetail struct {
+	Author
+	Role     string    `json:"role"`
+	SharedAt time.Time `json:"shared_at"`
+}
+
+//it's used only for JSON request, share the article with the user of this email or change its role
+type ShareArticleRequest struct {
+	Email string `valid:"email,required" json:"email"`
+	Role  string `valid:"in(viewer|editor),required" json:"role"`
+}
+
+//the article of public API with its author
+type PublicArticle struct {
+	Id          bson.ObjectId `json:"id"`
+	Title       string        `json:"title"`
+	Content     string        `json:"content"`
+	Author      Author        `json:"author"`
+	Tags        []string      `json:"tags,omitempty"`
+	PublishedAt *time.Time    `json:"published_at,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+//public information of user
+type Author struct {
+	Id            bson.ObjectId `json:"id"`
+	DisplayName   string        `json:"display_name"`
+	ImageFileName string        `json:"image_profile_url"`
+}
+
+func NewAuthor(u *User) Author {
+	return Author{u.Id, u.DisplayName, u.ImageFileName}
+}
+
+func NewPublicArticle(a *Article, author Author) PublicArticle {
+	return PublicArticle{a.Id, a.Title, a.Content, author, a.Tags, a.PublishedAt, a.CreatedAt, a.UpdatedAt}
+}
+
+//called by the binder before validation
+func (a *Article) Normalize() {
+	a.Tags = NormalizeTags(a.Tags)
+}
+
+//the articles that saved before the status are published
+func (a *Article) IsPublished() bool {
+	return a.Status == PUBLISHED_STATUS || a.Status == ""
+}
+
+//the role of user in the collaborators of article, empty if the article is not shared with user
+func (a *Article) CollaboratorRole(userId bson.ObjectId) string {
+	for _, collaborator := range a.Collaborators {
+		if collaborator.UserId == userId {
+			return collaborator.Role
+		}
+	}
+	return ""
 }
\ No newline at end of file
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+	"time"
+)
+
+const (
+	//one JSON record in each line
+	NDJSON_FORMAT = "ndjson"
+	//zip of markdown files, the fields except content are in the front matter
+	MARKDOWN_FORMAT = "markdown"
+	CREATED_IMPORT_STATUS = "created"
+	UPDATED_IMPORT_STATUS = "updated"
+	UNCHANGED_IMPORT_STATUS = "unchanged"
+	FAILED_IMPORT_STATUS = "failed"
+)
+
+//the article in export and import files, importing the same external id again updates its article
+type ArticleRecord struct {
+	ExternalId  string     `valid:"required,length(1|128)" json:"external_id" yaml:"external_id"`
+	Title       string     `valid:"required" json:"title" yaml:"title"`
+	//the body of markdown file
+	Content     string     `valid:"required" json:"content" yaml:"-"`
+	//the record has all of fields of article, so empty visibility and status are private and draft
+	Visibility  string     `valid:"in(private|unlisted|public)" json:"visibility,omitempty" yaml:"visibility,omitempty"`
+	Tags        []string   `valid:"tags" json:"tags,omitempty" yaml:"tags,omitempty"`
+	Status      string     `valid:"in(draft|published|archived)" json:"status,omitempty" yaml:"status,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty" yaml:"published_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+}
+
+//the result of each record of import file
+type ImportItemResult struct {
+	//line number of NDJSON or file name in zip
+	Item       string        `json:"item"`
+	ExternalId string        `json:"external_id,omitempty"`
+	ArticleId  bson.ObjectId `json:"article_id,omitempty"`
+	Status     string        `json:"status"`
+	//the error message of failed record
+	Error      string        `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	Created   int                `json:"created"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Failed    int                `json:"failed"`
+	Items     []ImportItemResult `json:"items"`
+}
+
+//the articles that imported before keep their external id, the id of others is their external id
+//the scheduled articles are exported as draft since the scheduler is not part of import
+func NewArticleRecord(a *Article) ArticleRecord {
+	record := ArticleRecord{
+		ExternalId:  a.ExternalId,
+		Title:       a.Title,
+		Content:     a.Content,
+		Visibility:  a.Visibility,
+		Tags:        a.Tags,
+		Status:      a.Status,
+		PublishedAt: a.PublishedAt,
+		CreatedAt:   &a.CreatedAt,
+	}
+	if record.ExternalId == "" {
+		record.ExternalId = a.Id.Hex()
+	}
+	if record.Visibility == "" {
+		record.Visibility = PRIVATE_VISIBILITY
+	}
+	if a.IsPublished() {
+		record.Status = PUBLISHED_STATUS
+	} else if a.Status == SCHEDULED_STATUS {
+		record.Status = DRAFT_STATUS
+	}
+	return record
+}
+
+//called before validation
+func (r *ArticleRecord) Normalize() {
+	r.ExternalId = strings.TrimSpace(r.ExternalId)
+	r.Tags = NormalizeTags(r.Tags)
+}
+
+func (r *ImportResult) Add(item ImportItemResult) {
+	switch item.Status {
+	case CREATED_IMPORT_STATUS:
+		r.Created++
+	case UPDATED_IMPORT_STATUS:
+		r.Updated++
+	case UNCHANGED_IMPORT_STATUS:
+		r.Unchanged++
+	default:
+		r.Failed++
+	}
+	r.Items = append(r.Items, item)
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+const (
+	EQUAL_DIFF_OP  = "equal"
+	INSERT_DIFF_OP = "insert"
+	DELETE_DIFF_OP = "delete"
+)
+
+//immutable snapshot of article that saved on each change, the revisions of article numbered from 1
+type ArticleRevision struct {
+	Id           bson.ObjectId `json:"id" bson:"_id"`
+	ArticleId    bson.ObjectId `json:"article_id" bson:"article_id"`
+	Revision     int           `json:"revision" bson:"revision"`
+	Title        string        `json:"title" bson:"title"`
+	Content      string        `json:"content" bson:"content"`
+	EditorId     bson.ObjectId `json:"editor_id" bson:"editor_id"`
+	//the revision that restored by this revision
+	RestoredFrom int           `json:"restored_from,omitempty" bson:"restored_from,omitempty"`
+	CreatedAt    time.Time     `json:"created_at" bson:"created_at"`
+	//the same as article, they purged together
+	DeletedAt    *time.Time    `json:"-" bson:"deleted_at,omitempty"`
+}
+
+func NewArticleRevision(a *Article, editorId bson.ObjectId) *ArticleRevision {
+	return &ArticleRevision{
+		Id:        bson.NewObjectId(),
+		ArticleId: a.Id,
+		Title:     a.Title,
+		Content:   a.Content,
+		EditorId:  editorId,
+		CreatedAt: time.Now(),
+	}
+}
+
+//line by line changes of title and content between two revisions
+type ArticleRevisionDiff struct {
+	ArticleId bson.ObjectId `json:"article_id"`
+	From      int           `json:"from"`
+	To        int           `json:"to"`
+	Title     []DiffLine    `json:"title"`
+	Content   []DiffLine    `json:"content"`
+}
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
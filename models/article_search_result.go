@@ -0,0 +1,10 @@
+package models
+
+//article found by full text search
+type ArticleSearchResult struct {
+	Article `bson:",inline"`
+	//relevance of article, the results sorted by it
+	Score   float64 `json:"score" bson:"score"`
+	//part of content with the matched words wrapped in mark tag, it's HTML escaped
+	Snippet string  `json:"snippet" bson:"-"`
+}
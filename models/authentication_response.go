@@ -6,4 +6,6 @@ type AuthenticationResponse struct {
 	AccessToken  string     `json:"access_token"`
 	ExpiresInMin float64    `json:"expire_in_min"`
 	RefreshToken string     `json:"refresh_token"`
+	//space separated scopes of access token
+	Scope        string     `json:"scope"`
 }
@@ -8,18 +8,43 @@ import (
 )
 
 type Client struct {
-	AppId        bson.ObjectId     `json:"app_id" bson:"_id"`
-	AppKey       string            `json:"app_key" bson:"key"`
-	Name         string            `valid:"required" json:"name" bson:"name"`
-	Description  string            `json:"description,omitempty" bson:"description,omitempty"`
-	IsEnable     bool              `default:"true" json:"is_enable" bson:"enable_status"`
-	PlatformType string            `default:"web" json:"platform_type" bson:"platform_type"`
-	CreatedAt    time.Time         `json:"created_at" bson:"created_at"`
-	UpdatedAt    time.Time         `json:"updated_at" bson:"updated_at"`
+	AppId         bson.ObjectId `json:"app_id" bson:"_id"`
+	AppKey        string        `json:"app_key" bson:"key"`
+	Name          string        `valid:"required" json:"name" bson:"name"`
+	Description   string        `json:"description,omitempty" bson:"description,omitempty"`
+	IsEnable      bool          `default:"true" json:"is_enable" bson:"enable_status"`
+	PlatformType  string        `default:"web" json:"platform_type" bson:"platform_type"`
+	//the OAuth2 authorization codes only sent to these URIs
+	RedirectURIs  []string      `valid:"redirect_uris" json:"redirect_uris,omitempty" bson:"redirect_uris,omitempty"`
+	//the scopes that the client can request
+	AllowedScopes []string      `valid:"scopes" json:"allowed_scopes,omitempty" bson:"allowed_scopes,omitempty"`
+	//increased by each update, the ETag of client
+	Version       int           `json:"version" bson:"version"`
+	CreatedAt     time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" bson:"updated_at"`
 }
 
 func (cli *Client) HashedAppKey() string {
 	hasher := md5.New()
 	hasher.Write([]byte(cli.AppKey))
 	return hex.EncodeToString(hasher.Sum(nil))
-}
\ No newline at end of file
+}
+
+//the redirect URI should be the same as one of the registered ones
+func (cli *Client) HasRedirectURI(uri string) bool {
+	for _, u := range cli.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func (cli *Client) IsScopeAllowed(scope string) bool {
+	for _, s := range cli.AllowedScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+//comment of article, only the top level comments can be replied
+type Comment struct {
+	Id        bson.ObjectId `json:"id" bson:"_id"`
+	ArticleId bson.ObjectId `json:"article_id" bson:"article_id"`
+	//empty for the top level comments
+	ParentId  bson.ObjectId `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	UserId    bson.ObjectId `json:"user_id" bson:"user_id"`
+	Content   string        `json:"content" bson:"content"`
+	//hidden by the owner of article or admin, only they and the author of comment see it
+	IsHidden  bool          `json:"is_hidden" bson:"hidden"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" bson:"updated_at"`
+	//the same as article, they purged together
+	DeletedAt *time.Time    `json:"-" bson:"deleted_at,omitempty"`
+}
+
+//the parent_id is only used in create
+type CommentRequest struct {
+	Content  string        `valid:"required,length(1|2000)" json:"content"`
+	ParentId bson.ObjectId `json:"parent_id,omitempty"`
+}
+
+//top level comment with its replies
+type CommentThread struct {
+	Comment `bson:",inline"`
+	Replies []Comment `json:"replies"`
+}
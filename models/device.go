@@ -0,0 +1,22 @@
+package models
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+//trusted app of user with its client information, it's used only for JSON response
+type Device struct {
+	Id           bson.ObjectId `json:"id"`
+	ClientId     bson.ObjectId `json:"client_id"`
+	ClientName   string        `json:"client_name"`
+	PlatformType string        `json:"platform_type,omitempty"`
+	DeviceModel  string        `json:"device_model,omitempty"`
+	OSVersion    string        `json:"os_version,omitempty"`
+	AppVersion   string        `json:"app_version,omitempty"`
+	Scopes       []string      `json:"scopes,omitempty"`
+	GrantedAt    time.Time     `json:"granted_at"`
+	LastUsedAt   time.Time     `json:"last_used_at"`
+	//the trusted app of the access token that requested
+	IsCurrent    bool          `json:"is_current"`
+}
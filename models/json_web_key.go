@@ -0,0 +1,21 @@
+package models
+
+//public key in JWK format (RFC 7517), it's used only for JSON response
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	//RSA public key parameters
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	//ECDSA public key parameters
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+//it's used only for JSON response of jwks endpoint
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
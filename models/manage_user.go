@@ -0,0 +1,28 @@
+package models
+
+import "strings"
+
+//user with its trusted apps, it's used only for JSON response of admin
+type UserDetail struct {
+	*User
+	TrustedApps []Device `json:"trusted_apps"`
+}
+
+//it's used only for JSON request, the roles replace all of roles of user
+type UpdateRolesRequest struct {
+	Roles []string `valid:"required" json:"roles"`
+}
+
+//called before validation
+func (r *UpdateRolesRequest) Normalize() {
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, role := range r.Roles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	r.Roles = roles
+}
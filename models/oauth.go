@@ -0,0 +1,130 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	ARTICLES_READ_SCOPE = "articles:read"
+	ARTICLES_WRITE_SCOPE = "articles:write"
+	COMMENTS_WRITE_SCOPE = "comments:write"
+	//the profile, password and devices of user and approving the other clients
+	ACCOUNT_SCOPE = "account"
+	//the manage endpoints, the permissions of user are checked too
+	MANAGE_SCOPE = "manage"
+	AUTHORIZATION_CODE_GRANT_TYPE = "authorization_code"
+	REFRESH_TOKEN_GRANT_TYPE = "refresh_token"
+	CLIENT_CREDENTIALS_GRANT_TYPE = "client_credentials"
+	CODE_RESPONSE_TYPE = "code"
+	S256_CODE_CHALLENGE_METHOD = "S256"
+)
+
+//the scopes that clients can be allowed to request
+var Scopes = []string{
+	ARTICLES_READ_SCOPE,
+	ARTICLES_WRITE_SCOPE,
+	COMMENTS_WRITE_SCOPE,
+	ACCOUNT_SCOPE,
+	MANAGE_SCOPE,
+}
+
+//the query parameters of authorize endpoint, the same fields in JSON of consent request
+type AuthorizeRequest struct {
+	ResponseType        string `valid:"in(code),required" json:"response_type"`
+	ClientId            string `valid:"required" json:"client_id"`
+	RedirectURI         string `valid:"required" json:"redirect_uri"`
+	//space separated scopes, all of the allowed scopes of client when it's empty
+	Scope               string `json:"scope"`
+	State               string `valid:"length(0|512)" json:"state"`
+	//base64url of SHA256 of the code verifier, the code is exchanged only with its verifier
+	CodeChallenge       string `valid:"length(43|128),required" json:"code_challenge"`
+	CodeChallengeMethod string `valid:"in(S256),required" json:"code_challenge_method"`
+	//the answer of user in consent
+	Approve             bool   `json:"approve"`
+}
+
+//it's used only for JSON response, the information that user sees before approve the client
+type ConsentResponse struct {
+	ClientId          bson.ObjectId `json:"client_id"`
+	ClientName        string        `json:"client_name"`
+	ClientDescription string        `json:"client_description,omitempty"`
+	Scopes            []string      `json:"scopes"`
+	RedirectURI       string        `json:"redirect_uri"`
+	State             string        `json:"state,omitempty"`
+}
+
+//it's used only for JSON response, the user agent should be redirected to it with the code or the error
+type AuthorizeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+//the JSON or form request of token endpoint, the fields of each grant type checked by its handler
+type TokenRequest struct {
+	GrantType    string `valid:"in(authorization_code|refresh_token|client_credentials),required" json:"grant_type"`
+	ClientId     string `valid:"required" json:"client_id"`
+	//the hashed app key, the web clients don't have it
+	ClientSecret string `json:"client_secret"`
+	Code         string `json:"code"`
+	RedirectURI  string `json:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+//it's used only for JSON response of token endpoint, expires_in is in seconds
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+//the space separated scopes without the duplicated ones
+func ParseScope(scope string) []string {
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidScopes(scopes []string) bool {
+	for _, s := range scopes {
+		if !IsValidScope(s) {
+			return false
+		}
+	}
+	return true
+}
+
+//absolute URI without fragment, the native apps can use their own scheme
+func IsValidRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.IsAbs() && u.Fragment == "" && !strings.Contains(uri, "#")
+}
+
+func IsValidRedirectURIs(uris []string) bool {
+	for _, uri := range uris {
+		if !IsValidRedirectURI(uri) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+//only for database models, the token sent to user by email or the OAuth2 authorization code, only its hash saved
+type OneTimeToken struct {
+	Id            bson.ObjectId `bson:"_id"`
+	UserId        bson.ObjectId `bson:"user_id"`
+	Purpose       string        `bson:"purpose"`
+	HashedToken   string        `bson:"hashed_token"`
+	//the email address that token sent to, used to verify email
+	Email         string        `bson:"email,omitempty"`
+	//the client, redirect URI, scopes and PKCE challenge of authorization code
+	ClientId      bson.ObjectId `bson:"client_id,omitempty"`
+	RedirectURI   string        `bson:"redirect_uri,omitempty"`
+	Scopes        []string      `bson:"scopes,omitempty"`
+	CodeChallenge string        `bson:"code_challenge,omitempty"`
+	ExpireAt      time.Time     `bson:"expire_at"`
+	CreatedAt     time.Time     `bson:"created_at"`
+}
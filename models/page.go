@@ -0,0 +1,10 @@
+package models
+
+//envelope of the paginated lists, the next page requested by next_cursor as cursor query parameter
+type Page struct {
+	Items      interface{} `json:"items"`
+	//empty in the last page
+	NextCursor string      `json:"next_cursor"`
+	//only when total query parameter is true
+	Total      *int        `json:"total,omitempty"`
+}
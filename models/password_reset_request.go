@@ -0,0 +1,16 @@
+package models
+
+//it's used only for JSON request
+type ForgotPasswordRequest struct {
+	AppId  string `valid:"required" json:"app_id"`
+	AppKey string `json:"app_key"`
+	Email  string `valid:"email,required" json:"email"`
+}
+
+//it's used only for JSON request
+type ResetPasswordRequest struct {
+	AppId    string `valid:"required" json:"app_id"`
+	AppKey   string `json:"app_key"`
+	Token    string `valid:"required" json:"token"`
+	Password string `valid:"length(6|64),required" json:"password"`
+}
@@ -0,0 +1,8 @@
+package models
+
+//the uploaded image of user, the files served by GET /media/{file name}
+type ProfileImage struct {
+	ImageFileName string            `json:"image_profile_url"`
+	//file name of each variant by its size in pixels
+	Variants      map[string]string `json:"variants"`
+}
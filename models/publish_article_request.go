@@ -0,0 +1,10 @@
+package models
+
+import (
+	"time"
+)
+
+//the body of publish is optional, the article published now when scheduled_for is empty or past
+type PublishArticleRequest struct {
+	ScheduledFor *time.Time `json:"scheduled_for"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	ARTICLE_READ_PERMISSION = "article:read"
+	ARTICLE_WRITE_PERMISSION = "article:write"
+	COMMENT_WRITE_PERMISSION = "comment:write"
+	//hide and delete the comments of others
+	COMMENT_MODERATE_PERMISSION = "comment:moderate"
+	CLIENT_MANAGE_PERMISSION = "client:manage"
+	USER_MANAGE_PERMISSION = "user:manage"
+	ROLE_MANAGE_PERMISSION = "role:manage"
+	//all of permissions, even the permissions that added later
+	ALL_PERMISSIONS = "*"
+)
+
+//the permissions that routes check
+var Permissions = []string{
+	ARTICLE_READ_PERMISSION,
+	ARTICLE_WRITE_PERMISSION,
+	COMMENT_WRITE_PERMISSION,
+	COMMENT_MODERATE_PERMISSION,
+	CLIENT_MANAGE_PERMISSION,
+	USER_MANAGE_PERMISSION,
+	ROLE_MANAGE_PERMISSION,
+}
+
+//set of permissions that given to users by its name, used for database and JSON
+type Role struct {
+	Name        string    `json:"name" bson:"_id"`
+	Description string    `json:"description" bson:"description,omitempty"`
+	Permissions []string  `json:"permissions" bson:"permissions"`
+	//the builtin roles created on startup and can't be removed
+	Builtin     bool      `json:"builtin" bson:"builtin"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+//it's used only for JSON request, the name is only used in create
+type RoleRequest struct {
+	Name        string   `valid:"matches(^[a-z][a-z0-9_-]+$),length(2|32)" json:"name"`
+	Description string   `valid:"length(0|256)" json:"description"`
+	Permissions []string `valid:"required" json:"permissions"`
+}
+
+//called before validation
+func (r *RoleRequest) Normalize() {
+	r.Name = strings.ToLower(strings.TrimSpace(r.Name))
+	r.Description = strings.TrimSpace(r.Description)
+	permissions := []string{}
+	seen := map[string]bool{}
+	for _, permission := range r.Permissions {
+		permission = strings.ToLower(strings.TrimSpace(permission))
+		if permission != "" && !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	r.Permissions = permissions
+}
+
+//the roles that created on startup if they don't exist, the admin role has all of permissions
+func DefaultRoles() []Role {
+	now := time.Now()
+	return []Role{
+		{
+			Name:        USER_ROLE,
+			Description: "write articles and comment on the articles of others",
+			Permissions: []string{ARTICLE_READ_PERMISSION, ARTICLE_WRITE_PERMISSION, COMMENT_WRITE_PERMISSION},
+			Builtin:     true,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		{
+			Name:        ADMIN_ROLE,
+			Description: "all of permissions",
+			Permissions: []string{ALL_PERMISSIONS},
+			Builtin:     true,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+	}
+}
+
+func IsValidPermission(permission string) bool {
+	if permission == ALL_PERMISSIONS {
+		return true
+	}
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
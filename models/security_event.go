@@ -0,0 +1,18 @@
+package models
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+//only for database models, the suspicious actions about user account
+type SecurityEvent struct {
+	Id           bson.ObjectId `json:"id" bson:"_id"`
+	UserId       bson.ObjectId `json:"user_id" bson:"user_id"`
+	TrustedAppId bson.ObjectId `json:"trusted_app_id,omitempty" bson:"trusted_app_id,omitempty"`
+	ClientId     bson.ObjectId `json:"client_id,omitempty" bson:"client_id,omitempty"`
+	Type         string        `json:"type" bson:"type"`
+	IPAddress    string        `json:"ip_address,omitempty" bson:"ip_address,omitempty"`
+	UserAgent    string        `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	CreatedAt    time.Time     `json:"created_at" bson:"created_at"`
+}
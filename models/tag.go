@@ -0,0 +1,77 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	MAX_ARTICLE_TAGS = 10
+	MAX_TAG_LENGTH   = 32
+)
+
+//lower case words of letters and digits that joined by dash
+var tagPattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}]+(-[\p{Ll}\p{Lo}\p{N}]+)*$`)
+
+//the number of articles that have the tag
+type TagCount struct {
+	Name  string `json:"name" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+//rename one tag or merge some tags to one tag in all of articles of user
+type RenameTagsRequest struct {
+	From []string `valid:"tags,required" json:"from"`
+	To   string   `valid:"tag,required" json:"to"`
+}
+
+type RenameTagsResult struct {
+	To      string `json:"to"`
+	//the number of articles that changed
+	Updated int    `json:"updated"`
+}
+
+func (r *RenameTagsRequest) Normalize() {
+	r.From = NormalizeTags(r.From)
+	r.To = NormalizeTag(r.To)
+}
+
+//lower case without the extra spaces, the spaces between words replaced by dash
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+//normalize the tags and remove the empty and duplicated ones, nil tags stay nil
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	result := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+//the tag should be normalized
+func IsValidTag(tag string) bool {
+	return len([]rune(tag)) <= MAX_TAG_LENGTH && tagPattern.MatchString(tag)
+}
+
+func IsValidTags(tags []string) bool {
+	if len(tags) > MAX_ARTICLE_TAGS {
+		return false
+	}
+	for _, tag := range tags {
+		if !IsValidTag(tag) {
+			return false
+		}
+	}
+	return true
+}
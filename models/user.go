@@ -5,32 +5,49 @@ import (
 	"time"
 )
 
+const (
+	USER_ROLE  = "user"
+	ADMIN_ROLE = "admin"
+)
 
 //used for database and JSON
 type User struct {
-	Id             bson.ObjectId `json:"id" bson:"_id"`
-	FirstName      string        `valid:"required" json:"first_name" bson:"first_name"`
-	LastName       string        `valid:"required" json:"last_name" bson:"last_name"`
-	DisplayName    string        `valid:"required" json:"display_name" bson:"display_name"`
-	Email          string        `valid:"email,required" json:"email" bson:"email"`
-	HashedPassword string        `json:"password,omitempty" bson:"hashed_password"`
-	ImageFileName  string        `default:"default_image_profile.jpeg" json:"image_profile_url" bson:"image_profile_file_name"`
-	IsEnable       bool          `default:"true" json:"is_enable" bson:"enable_status"`
-	TrustedApps    []TrustedApp  `json:"-" bson:"trusted_apps,omitempty"`
-	Roles          []string      `json:"roles" bson:"roles"`
-	JoinedAt       time.Time     `json:"joined_at" bson:"joined_at"`
-	UpdatedAt      time.Time     `json:"updated_at" bson:"updated_at"`
+	Id                    bson.ObjectId `json:"id" bson:"_id"`
+	FirstName             string        `valid:"required" json:"first_name" bson:"first_name"`
+	LastName              string        `valid:"required" json:"last_name" bson:"last_name"`
+	DisplayName           string        `valid:"required" json:"display_name" bson:"display_name"`
+	Email                 string        `valid:"email,required" json:"email" bson:"email"`
+	EmailVerified         bool          `json:"email_verified" bson:"email_verified"`
+	//the new email address that is not verified yet
+	PendingEmail          string        `json:"pending_email,omitempty" bson:"pending_email,omitempty"`
+	HashedPassword        string        `json:"password,omitempty" bson:"hashed_password"`
+	ImageFileName         string        `default:"default_image_profile.jpeg" json:"image_profile_url" bson:"image_profile_file_name"`
+	IsEnable              bool          `default:"true" json:"is_enable" bson:"enable_status"`
+	//set by admin, the user can't sign in until reset the password by email
+	PasswordResetRequired bool          `json:"password_reset_required" bson:"password_reset_required,omitempty"`
+	TrustedApps           []TrustedApp  `json:"-" bson:"trusted_apps,omitempty"`
+	Roles                 []string      `json:"roles" bson:"roles"`
+	JoinedAt              time.Time     `json:"joined_at" bson:"joined_at"`
+	UpdatedAt             time.Time     `json:"updated_at" bson:"updated_at"`
 }
 
 //only for database models
 type TrustedApp struct {
-	Id               bson.ObjectId      `bson:"_id"`
-	ClientId         bson.ObjectId      `bson:"_client"`
-	RefreshToken     string             `bson:"refresh_token"`
-	DeviceModel      string             `bson:"device_model,omitempty"`
-	OSVersion        string             `bson:"os_version,omitempty"`
-	AppVersion       string             `bson:"app_version,omitempty"`
-	MessageTokenType string             `bson:"message_token_type,omitempty"`
-	MessageToken     string             `bson:"message_token,omitempty"`
-	GrantedAt        time.Time          `bson:"granted_at"`
+	Id                   bson.ObjectId `bson:"_id"`
+	ClientId             bson.ObjectId `bson:"_client"`
+	RefreshToken         string        `bson:"refresh_token"`
+	//the refresh tokens that rotated before, presenting one of them means the token stolen
+	RetiredRefreshTokens []string      `bson:"retired_refresh_tokens,omitempty"`
+	//start of the current refresh token family, the absolute expiry counted from it
+	SessionStartedAt     time.Time     `bson:"session_started_at,omitempty"`
+	DeviceModel          string        `bson:"device_model,omitempty"`
+	OSVersion            string        `bson:"os_version,omitempty"`
+	AppVersion           string        `bson:"app_version,omitempty"`
+	MessageTokenType     string        `bson:"message_token_type,omitempty"`
+	MessageToken         string        `bson:"message_token,omitempty"`
+	//the scopes of the access tokens of this trusted app, the trusted apps granted before scopes have all of them
+	Scopes               []string      `bson:"scopes,omitempty"`
+	GrantedAt            time.Time     `bson:"granted_at"`
+	//last time access token issued for this trusted app by sign in or refresh token
+	LastUsedAt           time.Time     `bson:"last_used_at,omitempty"`
 }
\ No newline at end of file
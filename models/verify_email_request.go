@@ -0,0 +1,8 @@
+package models
+
+//it's used only for JSON request
+type VerifyEmailRequest struct {
+	AppId  string `valid:"required" json:"app_id"`
+	AppKey string `json:"app_key"`
+	Token  string `valid:"required" json:"token"`
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"time"
@@ -11,14 +12,44 @@ import (
 	"github.com/labstack/echo/engine/standard"
 	"github.com/labstack/echo/middleware"
 
+	"github.com/atahani/golang-rest-api-sample/config"
 	"github.com/atahani/golang-rest-api-sample/controller/article"
 	"github.com/atahani/golang-rest-api-sample/controller/client"
+	"github.com/atahani/golang-rest-api-sample/controller/media"
+	"github.com/atahani/golang-rest-api-sample/controller/role"
 	"github.com/atahani/golang-rest-api-sample/controller/user"
+	"github.com/atahani/golang-rest-api-sample/controller/wellknown"
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
 	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/blobstorage"
+	"github.com/atahani/golang-rest-api-sample/util/jwtkey"
+	"github.com/atahani/golang-rest-api-sample/util/mailer"
+	"github.com/atahani/golang-rest-api-sample/util/permission"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("APP_CONFIG"), "path of YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective config with redacted secrets and exit")
+	flag.Parse()
+
+	//load and validate the config, environment variables override the file
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		out, err := cfg.Redacted()
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(out))
+		return
+	}
+
 	//Echo instance
 	app := echo.New()
 
@@ -29,97 +60,261 @@ func main() {
 	//set custom error handler
 	app.SetHTTPErrorHandler(specialerror.CustomErrorHandler)
 
-	//set the port listener
-	port := "8090"
-	if os.Getenv("PORT") != "" {
-		port = os.Getenv("PORT")
-	}
-
-	//Configs in different app environment mode
-	var applicationEnv string
-	var mongoDBDialInfo *mgo.DialInfo
-	switch os.Getenv("APP_ENV") {
-	case "development":
-		applicationEnv = "development"
-		mongoDBDialInfo = &mgo.DialInfo{
-			Addrs:    []string{"localhost:27017"},
-			Timeout:  60 * time.Second,
-			Database: "golang_sample_dev",
-		}
-		app.SetDebug(true)
+	app.SetDebug(cfg.Debug)
+	if cfg.Recover {
+		app.Use(middleware.Recover())
+	}
+	if cfg.Log.Enabled {
 		//Custom logger for console
 		app.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-			Format: "${method}-${status} at > ${uri} < in ${response_time} - ${response_size} bytes\n",
+			Format: cfg.Log.Format,
 		}))
-	case "production":
-		applicationEnv = "production"
-		mongoDBDialInfo = &mgo.DialInfo{
-			Addrs:    []string{"localhost"},
-			Timeout:  60 * time.Second,
-			Database: "golang_sample",
-		}
-		app.Use(middleware.Recover())
-		app.SetDebug(false)
+	}
+	if cfg.GzipLevel != 0 {
 		app.Use(middleware.GzipWithConfig(middleware.GzipConfig{
-			Level: 5,
+			Level: cfg.GzipLevel,
 		}))
-	default:
-		applicationEnv = "development"
-		mongoDBDialInfo = &mgo.DialInfo{
-			Addrs:    []string{"localhost:27017"},
-			Timeout:  60 * time.Second,
-			Database: "golang_sample_dev",
-		}
-		app.SetDebug(true)
-		//Custom logger for console
-		app.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-			Format: "${method}-${status} at > ${uri} < in ${response_time} - ${response_size} bytes\n",
+	}
+	if cfg.CORS.Enabled {
+		app.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+			AllowOrigins:     cfg.CORS.AllowOrigins,
+			AllowMethods:     cfg.CORS.AllowMethods,
+			AllowHeaders:     cfg.CORS.AllowHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
 		}))
 	}
 
+	//load the JWT signing keys
+	keys, err := jwtkey.LoadManager(cfg.JWT)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	//mailer that send the account emails such as reset password
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	//blob storage that keep the uploaded images
+	storage, err := blobstorage.New(cfg.Media)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
 	//create a session with maintains a pool of socket connections to out mongodb
+	mongoDBDialInfo := cfg.Mongo.DialInfo()
 	mongoSession, err := mgo.DialWithInfo(mongoDBDialInfo)
 	if err != nil {
 		fmt.Printf("connection %s\n", err)
 	}
 	//check and ensure database indexes
-	mongoSession.DB(mongoDBDialInfo.Database).C(user.ACCESS_TOKEN_COLLECTION_NAME).EnsureIndex(mgo.Index{
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ACCESS_TOKEN_COLLECTION_NAME).EnsureIndex(mgo.Index{
 		Key:         []string{"expire_at"},
 		Unique:      false,
 		DropDups:    false,
 		Background:  true,
 		ExpireAfter: time.Second * 1,
 	})
+	//the emailed tokens removed after expire
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ONE_TIME_TOKEN_COLLECTION_NAME).EnsureIndex(mgo.Index{
+		Key:         []string{"expire_at"},
+		Background:  true,
+		ExpireAfter: time.Second * 1,
+	})
+	//find the reused refresh tokens
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.USER_COLLECTION_NAME).EnsureIndex(mgo.Index{
+		Key:        []string{"trusted_apps.retired_refresh_tokens"},
+		Background: true,
+	})
+
+	//the paginated list of articles sorted by these fields
+	for _, field := range []string{"created_at", "updated_at", "title"} {
+		mongoSession.DB(mongoDBDialInfo.Database).C(store.ARTICLE_COLLECTION_NAME).EnsureIndex(mgo.Index{
+			Key:        []string{"user_id", field, "_id"},
+			Background: true,
+		})
+	}
+
+	//the articles that shared with user
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ARTICLE_COLLECTION_NAME).EnsureIndex(mgo.Index{
+		Key:        []string{"collaborators.user_id", "created_at", "_id"},
+		Background: true,
+	})
+
+	//the articles filtered by tag and the tags counted, tags is array so it's multikey index
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ARTICLE_COLLECTION_NAME).EnsureIndex(mgo.Index{
+		Key:        []string{"user_id", "tags"},
+		Background: true,
+	})
+
+	//full text search of articles
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ARTICLE_COLLECTION_NAME).EnsureIndex(store.ArticleTextIndex())
+	//the scheduler finds the due articles by this index
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ARTICLE_COLLECTION_NAME).EnsureIndex(store.ArticleScheduleIndex())
+	//the comments of article listed by time
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.COMMENT_COLLECTION_NAME).EnsureIndex(mgo.Index{
+		Key:        []string{"article_id", "parent_id", "created_at", "_id"},
+		Background: true,
+	})
+	//the revisions of article numbered by this index
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ARTICLE_REVISION_COLLECTION_NAME).EnsureIndex(store.ArticleRevisionIndex())
+	//the trash of user sorted by deleted_at
+	mongoSession.DB(mongoDBDialInfo.Database).C(store.ARTICLE_COLLECTION_NAME).EnsureIndex(mgo.Index{
+		Key:        []string{"user_id", "deleted_at", "_id"},
+		Background: true,
+	})
+	//the imported articles found by their external id
+	if err := store.EnsureArticleExternalIdIndex(mongoSession.DB(mongoDBDialInfo.Database)); err != nil {
+		fmt.Printf("external id index %s\n", err)
+	}
+	//the deleted articles purged with their revisions and comments after the retention
+	for _, collection := range []string{store.ARTICLE_COLLECTION_NAME, store.ARTICLE_REVISION_COLLECTION_NAME, store.COMMENT_COLLECTION_NAME} {
+		if err := store.EnsureTrashIndex(mongoSession.DB(mongoDBDialInfo.Database).C(collection), cfg.Article.TrashRetention.Duration); err != nil {
+			fmt.Printf("trash index of %s %s\n", collection, err)
+		}
+	}
+
+	//stores that controllers and middlewares use to access the database
+	stores := store.NewMongoStores(mongoSession, mongoDBDialInfo.Database)
 
-	clientController := client.NewClientController(mongoSession, mongoDBDialInfo.Database)
-	userController := user.NewUserController(mongoSession, mongoDBDialInfo.Database)
-	articleController := article.NewArticleController(mongoSession, mongoDBDialInfo.Database)
+	//the permissions of roles, the default roles created if they don't exist
+	policy := permission.NewPolicy(stores.Roles)
+	if err := policy.Init(); err != nil {
+		fmt.Printf("roles %s\n", err)
+	}
+	//get the role changes of other instances
+	if cfg.Account.RoleReloadInterval.Duration > 0 {
+		go policy.RunReloader(cfg.Account.RoleReloadInterval.Duration, nil)
+	}
+
+	clientController := client.NewClientController(stores.Clients)
+	userController := user.NewUserController(stores, cfg, keys, mail, storage, policy)
+	roleController := role.NewRoleController(stores.Roles, stores.Users, policy)
+	mediaController := media.NewMediaController(storage, cfg.Media)
+	wellKnownController := wellknown.NewWellKnownController(cfg.JWT, keys)
+	articleController := article.NewArticleController(stores)
+	//publish the scheduled articles, it's safe to run on all instances
+	if cfg.Article.SchedulerInterval.Duration > 0 {
+		go articleController.RunScheduler(cfg.Article.SchedulerInterval.Duration, nil)
+	}
+	//the middleware that authenticate user by access token
+	jwtAuthentication := user.JWTAuthenticationMiddleware(stores.Users, stores.AccessTokens, keys, policy)
+	//the account of user and approving the other clients need the account scope, so the tokens of other clients can't do them
+	accountScope := user.RequireScope(models.ACCOUNT_SCOPE)
 	//auth endpoint
 	app.Post("/auth/signup", userController.SignUpNewUser)
 	app.Post("/auth/singin", userController.SignIn)
 	app.Post("/auth/token/refresh", userController.RefreshAccessToken)
+	app.Post("/auth/signout", userController.SignOut, jwtAuthentication)
+	app.Post("/auth/signout/all", userController.SignOutAll, jwtAuthentication, accountScope)
+	app.Post("/auth/password/forgot", userController.ForgotPassword)
+	app.Post("/auth/password/reset", userController.ResetPassword)
+	app.Post("/auth/email/verify", userController.VerifyEmail)
+	//OAuth2 authorization server, the user approves the clients by the access token of first party app
+	app.Get(wellknown.AUTHORIZATION_ENDPOINT_PATH, userController.GetAuthorizeConsent, jwtAuthentication, accountScope)
+	app.Post(wellknown.AUTHORIZATION_ENDPOINT_PATH, userController.Authorize, jwtAuthentication, accountScope)
+	app.Post(wellknown.TOKEN_ENDPOINT_PATH, userController.IssueToken)
+	//discovery endpoints to verify the access tokens
+	app.Get(wellknown.JWKS_PATH, wellKnownController.GetJWKS)
+	app.Get(wellknown.OPENID_CONFIGURATION_PATH, wellKnownController.GetOpenIDConfiguration)
+	//uploaded files such as profile images
+	app.Get("/media/*", mediaController.GetMedia)
+
+	//public articles that don't need authentication
+	app.Get("/public/article/:id", articleController.GetPublicArticleById)
+	app.Get("/public/user/:id/articles", articleController.GetPublicArticlesOfUser)
 
-	//manage endpoint for client
-	apiAdmin := app.Group("/api/manage", user.JWTAuthenticationMiddleware(mongoSession, mongoDBDialInfo.Database), user.AuthorizeUserByRolesMiddleware([]string{"admin"}))
+	//manage endpoint, each part needs its own permission
+	apiAdmin := app.Group("/api/manage", jwtAuthentication, user.RequireScope(models.MANAGE_SCOPE))
 	//manage clients
-	apiAdmin.Get("/client", clientController.GetClients)
-	apiAdmin.Post("/client", clientController.CreateNewClient)
-	apiAdmin.Get("/client/:id", clientController.GetClientById)
-	apiAdmin.Put("/client/:id", clientController.UpdateClientById)
-	apiAdmin.Delete("/client/:id", clientController.DeleteClientById)
+	clientManage := user.RequirePermission(models.CLIENT_MANAGE_PERMISSION)
+	apiAdmin.Get("/client", clientController.GetClients, clientManage)
+	apiAdmin.Post("/client", clientController.CreateNewClient, clientManage)
+	apiAdmin.Get("/client/:id", clientController.GetClientById, clientManage)
+	apiAdmin.Put("/client/:id", clientController.UpdateClientById, clientManage)
+	apiAdmin.Delete("/client/:id", clientController.DeleteClientById, clientManage)
+	//manage users
+	userManage := user.RequirePermission(models.USER_MANAGE_PERMISSION)
+	apiAdmin.Get("/user", userController.GetUsers, userManage)
+	apiAdmin.Get("/user/:id", userController.GetUserById, userManage)
+	apiAdmin.Post("/user/:id/enable", userController.EnableUser, userManage)
+	apiAdmin.Post("/user/:id/disable", userController.DisableUser, userManage)
+	apiAdmin.Put("/user/:id/roles", userController.UpdateUserRoles, userManage)
+	apiAdmin.Post("/user/:id/password/reset", userController.ForcePasswordReset, userManage)
+	apiAdmin.Delete("/user/:id", userController.DeleteUser, userManage)
+	//manage roles
+	roleManage := user.RequirePermission(models.ROLE_MANAGE_PERMISSION)
+	apiAdmin.Get("/permission", roleController.GetPermissions, roleManage)
+	apiAdmin.Get("/role", roleController.GetRoles, roleManage)
+	apiAdmin.Post("/role", roleController.CreateRole, roleManage)
+	apiAdmin.Get("/role/:name", roleController.GetRoleByName, roleManage)
+	apiAdmin.Put("/role/:name", roleController.UpdateRoleByName, roleManage)
+	apiAdmin.Delete("/role/:name", roleController.DeleteRoleByName, roleManage)
 
-	apiUser := app.Group("/api", user.JWTAuthenticationMiddleware(mongoSession, mongoDBDialInfo.Database), user.AuthorizeUserByRolesMiddleware([]string{"user"}))
+	//the account of user only needs authentication and the account scope, the articles need the scopes and the permissions
+	apiUser := app.Group("/api", jwtAuthentication)
 	//user profile
-	apiUser.Put("/user/profile", userController.UpdateUserProfile)
-	apiUser.Put("/user/password", userController.ChangeUserPassword)
+	apiUser.Put("/user/profile", userController.UpdateUserProfile, accountScope)
+	apiUser.Put("/user/profile/image", userController.UpdateProfileImage, accountScope)
+	apiUser.Put("/user/password", userController.ChangeUserPassword, accountScope)
+	//devices (trusted apps) of user
+	apiUser.Get("/user/devices", userController.GetDevices, accountScope)
+	apiUser.Delete("/user/devices/:id", userController.RevokeDevice, accountScope)
+	apiUser.Post("/user/email/verification", userController.ResendEmailVerification, accountScope)
+	//the middlewares of routes that read or change articles, the scope of token is checked before the permissions of user
+	articleRead := []echo.MiddlewareFunc{user.RequireScope(models.ARTICLES_READ_SCOPE), user.RequirePermission(models.ARTICLE_READ_PERMISSION)}
+	articleWrite := []echo.MiddlewareFunc{user.RequireScope(models.ARTICLES_WRITE_SCOPE), user.RequirePermission(models.ARTICLE_WRITE_PERMISSION)}
+	commentWrite := []echo.MiddlewareFunc{user.RequireScope(models.COMMENTS_WRITE_SCOPE), user.RequirePermission(models.COMMENT_WRITE_PERMISSION)}
+	if cfg.Account.RequireVerifiedEmail {
+		articleWrite = append(articleWrite, user.RequireVerifiedEmailMiddleware())
+		commentWrite = append(commentWrite, user.RequireVerifiedEmailMiddleware())
+	}
+	//the author of comment, the owner of article or the moderators can delete the comments
+	commentDelete := []echo.MiddlewareFunc{user.RequireScope(models.COMMENTS_WRITE_SCOPE), user.RequireAnyPermission(models.COMMENT_WRITE_PERMISSION, models.ARTICLE_WRITE_PERMISSION, models.COMMENT_MODERATE_PERMISSION)}
+	//the owner of article or the moderators can hide the comments
+	commentModerate := []echo.MiddlewareFunc{user.RequireScope(models.COMMENTS_WRITE_SCOPE), user.RequireAnyPermission(models.ARTICLE_WRITE_PERMISSION, models.COMMENT_MODERATE_PERMISSION)}
 	//article
-	apiUser.Get("/article", articleController.GetArticlesOfUser)
-	apiUser.Post("/article", articleController.CreateArticle)
-	apiUser.Get("/article/:id", articleController.GetArticleById)
-	apiUser.Put("/article/:id", articleController.UpdateArticleById)
-	apiUser.Delete("/article/:id", articleController.DeleteArticleById)
+	apiUser.Get("/article", articleController.GetArticlesOfUser, articleRead...)
+	apiUser.Post("/article", articleController.CreateArticle, articleWrite...)
+	apiUser.Get("/article/search", articleController.SearchArticles, articleRead...)
+	apiUser.Get("/article/trash", articleController.GetTrash, articleRead...)
+	apiUser.Get("/article/export", articleController.ExportArticles, articleRead...)
+	apiUser.Post("/article/import", articleController.ImportArticles, articleWrite...)
+	apiUser.Delete("/article/trash/:id", articleController.PurgeArticle, articleWrite...)
+	apiUser.Get("/article/:id", articleController.GetArticleById, articleRead...)
+	apiUser.Put("/article/:id", articleController.UpdateArticleById, articleWrite...)
+	apiUser.Delete("/article/:id", articleController.DeleteArticleById, articleWrite...)
+	apiUser.Post("/article/:id/restore", articleController.RestoreArticle, articleWrite...)
+	//publishing of article
+	apiUser.Post("/article/:id/publish", articleController.PublishArticle, articleWrite...)
+	apiUser.Post("/article/:id/unpublish", articleController.UnpublishArticle, articleWrite...)
+	apiUser.Post("/article/:id/archive", articleController.ArchiveArticle, articleWrite...)
+	//sharing of article with other users
+	apiUser.Get("/article/:id/collaborators", articleController.GetCollaborators, articleRead...)
+	apiUser.Put("/article/:id/collaborators", articleController.ShareArticle, articleWrite...)
+	apiUser.Delete("/article/:id/collaborators/:user_id", articleController.RemoveCollaborator, articleWrite...)
+	//comments of article
+	apiUser.Get("/article/:id/comments", articleController.GetComments, articleRead...)
+	apiUser.Post("/article/:id/comments", articleController.CreateComment, commentWrite...)
+	apiUser.Put("/article/:id/comments/:comment_id", articleController.UpdateComment, commentWrite...)
+	apiUser.Delete("/article/:id/comments/:comment_id", articleController.DeleteComment, commentDelete...)
+	apiUser.Post("/article/:id/comments/:comment_id/hide", articleController.HideComment, commentModerate...)
+	apiUser.Post("/article/:id/comments/:comment_id/unhide", articleController.UnhideComment, commentModerate...)
+	//tags of articles
+	apiUser.Get("/tags", articleController.GetTags, articleRead...)
+	apiUser.Post("/tags/rename", articleController.RenameTags, articleWrite...)
+	//article revisions
+	apiUser.Get("/article/:id/revisions", articleController.GetArticleRevisions, articleRead...)
+	apiUser.Get("/article/:id/revisions/diff", articleController.GetArticleRevisionsDiff, articleRead...)
+	apiUser.Get("/article/:id/revisions/:rev", articleController.GetArticleRevision, articleRead...)
+	apiUser.Post("/article/:id/revisions/:rev/restore", articleController.RestoreArticleRevision, articleWrite...)
 
 	//start server
-	fmt.Printf("API Management Listen to %s port in %s\n", port, applicationEnv)
-	app.Run(standard.New(fmt.Sprint(":", port)))
+	fmt.Printf("API Management Listen to %s in %s\n", cfg.ListenAddress, cfg.Environment)
+	app.Run(standard.New(cfg.ListenAddress))
 }
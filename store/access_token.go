@@ -0,0 +1,136 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+//storage of issued access tokens, expired ones removed by TTL index on expire_at
+type AccessTokenStore interface {
+	Insert(t *models.AccessToken) error
+	FindByToken(token string) (*models.AccessToken, error)
+	RemoveById(id bson.ObjectId) error
+	//remove all of access tokens issued for this trusted app
+	RemoveByTrustedAppId(trustedAppId bson.ObjectId) error
+	//remove all of access tokens of this user
+	RemoveByUserId(userId bson.ObjectId) error
+}
+
+type mongoAccessTokenStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoAccessTokenStore(s *mgo.Session, dbName string) AccessTokenStore {
+	return &mongoAccessTokenStore{s, dbName}
+}
+
+func (s *mongoAccessTokenStore) Insert(t *models.AccessToken) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return session.DB(s.dbName).C(ACCESS_TOKEN_COLLECTION_NAME).Insert(t)
+}
+
+func (s *mongoAccessTokenStore) FindByToken(token string) (*models.AccessToken, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	accessToken := models.AccessToken{}
+	if err := session.DB(s.dbName).C(ACCESS_TOKEN_COLLECTION_NAME).Find(bson.M{"token": token}).One(&accessToken); err != nil {
+		return nil, mongoError(err)
+	}
+	return &accessToken, nil
+}
+
+func (s *mongoAccessTokenStore) RemoveById(id bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(ACCESS_TOKEN_COLLECTION_NAME).RemoveId(id))
+}
+
+func (s *mongoAccessTokenStore) RemoveByTrustedAppId(trustedAppId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	_, err := session.DB(s.dbName).C(ACCESS_TOKEN_COLLECTION_NAME).RemoveAll(bson.M{"trusted_app_id": trustedAppId})
+	return err
+}
+
+func (s *mongoAccessTokenStore) RemoveByUserId(userId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	_, err := session.DB(s.dbName).C(ACCESS_TOKEN_COLLECTION_NAME).RemoveAll(bson.M{"user_id": userId})
+	return err
+}
+
+type memoryAccessTokenStore struct {
+	mutex        sync.RWMutex
+	accessTokens []models.AccessToken
+}
+
+func NewMemoryAccessTokenStore() AccessTokenStore {
+	return &memoryAccessTokenStore{}
+}
+
+func (s *memoryAccessTokenStore) Insert(t *models.AccessToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	accessToken := *t
+	accessToken.Scopes = copyStrings(t.Scopes)
+	s.accessTokens = append(s.accessTokens, accessToken)
+	return nil
+}
+
+func (s *memoryAccessTokenStore) FindByToken(token string) (*models.AccessToken, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, accessToken := range s.accessTokens {
+		//act like TTL index and ignore expired tokens
+		if accessToken.Token == token && accessToken.ExpireAt.After(time.Now()) {
+			accessToken.Scopes = copyStrings(accessToken.Scopes)
+			return &accessToken, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryAccessTokenStore) RemoveById(id bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, accessToken := range s.accessTokens {
+		if accessToken.Id == id {
+			s.accessTokens = append(s.accessTokens[:i], s.accessTokens[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryAccessTokenStore) RemoveByTrustedAppId(trustedAppId bson.ObjectId) error {
+	s.removeWhere(func(accessToken models.AccessToken) bool {
+		return accessToken.TrustedAppId == trustedAppId
+	})
+	return nil
+}
+
+func (s *memoryAccessTokenStore) RemoveByUserId(userId bson.ObjectId) error {
+	s.removeWhere(func(accessToken models.AccessToken) bool {
+		return accessToken.UserId == userId
+	})
+	return nil
+}
+
+func (s *memoryAccessTokenStore) removeWhere(match func(models.AccessToken) bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	kept := s.accessTokens[:0]
+	for _, accessToken := range s.accessTokens {
+		if !match(accessToken) {
+			kept = append(kept, accessToken)
+		}
+	}
+	s.accessTokens = kept
+}
@@ -0,0 +1,923 @@
+package store
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/util/textsearch"
+)
+
+const (
+	ARTICLE_TEXT_INDEX_NAME     = "article_text"
+	ARTICLE_TITLE_TEXT_WEIGHT   = 3
+	ARTICLE_CONTENT_TEXT_WEIGHT = 1
+)
+
+//storage of articles, the owned functions are scoped to the owner user
+//the articles in trash are excluded from all of functions except the trash ones
+type ArticleStore interface {
+	//ErrDuplicate if the user has another article with this external id, even in trash
+	Insert(a *models.Article) error
+	FindById(id bson.ObjectId) (*models.Article, error)
+	//the article of user that imported with the external id, the external id of the others is their id
+	//the articles in trash are also found like the unique index of external ids, so callers check their DeletedAt
+	FindByExternalId(userId bson.ObjectId, externalId string) (*models.Article, error)
+	//call fn for each article that match the filter, oldest first, it stops at the first error of fn
+	ForEach(filter ArticleFilter, fn func(a *models.Article) error) error
+	//page of the articles that match the filter, the items are []models.Article
+	FindPage(filter ArticleFilter, page PageRequest) (*models.Page, error)
+	//full text search in title and content of articles of user, the results sorted by score
+	Search(userId bson.ObjectId, query string, limit int) ([]models.ArticleSearchResult, error)
+	//update title, content and visibility of article if it's own by this user, empty visibility doesn't change
+	//the versions are the conditions of If-Match, nil means any version and the version increased by each update
+	UpdateOwned(id, userId bson.ObjectId, a *models.Article, versions []int) error
+	//move the article to trash, it's removed by the TTL index after the retention
+	TrashOwned(id, userId bson.ObjectId, deletedAt time.Time, versions []int) error
+	//move all of articles of user to trash, it returns the ids of them
+	TrashAllOwned(userId bson.ObjectId, deletedAt time.Time) ([]bson.ObjectId, error)
+	//bring back the article from trash, ErrNotFound if it's not in trash
+	RestoreOwned(id, userId bson.ObjectId) error
+	//remove the article that is in trash for ever
+	PurgeOwned(id, userId bson.ObjectId) error
+	//change the status of article if it's own by this user and its current status is one of from statuses
+	ChangeStatusOwned(id, userId bson.ObjectId, from []string, change ArticleStatusChange) error
+	//publish the scheduled articles that their time is come, the articles that published by this call returned
+	//each article published by one conditional update, so it's safe to run on multiple instances
+	PublishScheduled(now time.Time) ([]models.Article, error)
+	//the tags of articles of user with the number of articles, the most used first
+	TagCounts(userId bson.ObjectId) ([]models.TagCount, error)
+	//replace the from tags with to tag in the articles of user, it returns the number of changed articles
+	RenameTags(userId bson.ObjectId, from []string, to string) (int, error)
+	//add delta to the number of comments of article, it's not an update of article so the version doesn't change
+	IncCommentCount(id bson.ObjectId, delta int) error
+	//add the collaborator to article of owner or change its role, the version doesn't change
+	SetCollaboratorOwned(id, userId bson.ObjectId, collaborator models.Collaborator) error
+	//ErrNotFound if the article of owner is not shared with the collaborator
+	RemoveCollaboratorOwned(id, userId, collaboratorId bson.ObjectId) error
+	//remove the user from the collaborators of all of articles
+	RemoveCollaboratorFromAll(collaboratorId bson.ObjectId) error
+}
+
+//the nil times removed from article
+type ArticleStatusChange struct {
+	Status       string
+	PublishedAt  *time.Time
+	ScheduledFor *time.Time
+}
+
+//the zero values are not used in filter
+type ArticleFilter struct {
+	UserId bson.ObjectId
+	//the articles that shared with this user instead of the articles of UserId
+	SharedWith bson.ObjectId
+	Visibility string
+	Status     string
+	Tag        string
+	//case insensitive prefix of title
+	TitlePrefix string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+	//only the articles in trash instead of the others
+	Trashed bool
+}
+
+func (f ArticleFilter) mongoQuery() bson.M {
+	query := bson.M{"user_id": f.UserId, "deleted_at": inTrash(f.Trashed)}
+	if f.SharedWith != "" {
+		delete(query, "user_id")
+		query["collaborators.user_id"] = f.SharedWith
+	}
+	if f.Visibility == models.PRIVATE_VISIBILITY {
+		//the old articles don't have visibility and they are private
+		query["visibility"] = bson.M{"$in": []interface{}{models.PRIVATE_VISIBILITY, nil}}
+	} else if f.Visibility != "" {
+		query["visibility"] = f.Visibility
+	}
+	if f.Status != "" {
+		query["status"] = statusCondition([]string{f.Status})
+	}
+	if f.Tag != "" {
+		query["tags"] = f.Tag
+	}
+	if f.TitlePrefix != "" {
+		query["title"] = bson.RegEx{Pattern: "^" + regexp.QuoteMeta(f.TitlePrefix), Options: "i"}
+	}
+	if r := timeRange(f.CreatedFrom, f.CreatedTo); r != nil {
+		query["created_at"] = r
+	}
+	if r := timeRange(f.UpdatedFrom, f.UpdatedTo); r != nil {
+		query["updated_at"] = r
+	}
+	return query
+}
+
+func (f ArticleFilter) match(a *models.Article) bool {
+	if (a.DeletedAt != nil) != f.Trashed {
+		return false
+	}
+	if f.SharedWith != "" {
+		if a.CollaboratorRole(f.SharedWith) == "" {
+			return false
+		}
+	} else if a.UserId != f.UserId {
+		return false
+	}
+	if f.Visibility != "" && a.Visibility != f.Visibility && !(f.Visibility == models.PRIVATE_VISIBILITY && a.Visibility == "") {
+		return false
+	}
+	if f.Status != "" && !hasStatus(a, []string{f.Status}) {
+		return false
+	}
+	if f.Tag != "" && !hasTag(a.Tags, f.Tag) {
+		return false
+	}
+	if f.TitlePrefix != "" && !strings.HasPrefix(strings.ToLower(a.Title), strings.ToLower(f.TitlePrefix)) {
+		return false
+	}
+	return inTimeRange(a.CreatedAt, f.CreatedFrom, f.CreatedTo) && inTimeRange(a.UpdatedAt, f.UpdatedFrom, f.UpdatedTo)
+}
+
+//the condition of deleted_at
+func inTrash(trashed bool) bson.M {
+	return bson.M{"$exists": trashed}
+}
+
+//the articles without status are published
+func statusCondition(statuses []string) bson.M {
+	in := []interface{}{}
+	for _, status := range statuses {
+		in = append(in, status)
+		if status == models.PUBLISHED_STATUS {
+			in = append(in, nil)
+		}
+	}
+	return bson.M{"$in": in}
+}
+
+func hasStatus(a *models.Article, statuses []string) bool {
+	for _, status := range statuses {
+		if a.Status == status || (status == models.PUBLISHED_STATUS && a.IsPublished()) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+//the from tags except the to tag, nil if there is nothing to rename
+func renamedTags(from []string, to string) []string {
+	var result []string
+	for _, tag := range from {
+		if tag != to {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func (change ArticleStatusChange) mongoUpdate() bson.M {
+	set := bson.M{"status": change.Status, "updated_at": time.Now()}
+	unset := bson.M{}
+	if change.PublishedAt != nil {
+		set["published_at"] = change.PublishedAt
+	} else {
+		unset["published_at"] = ""
+	}
+	if change.ScheduledFor != nil {
+		set["scheduled_for"] = change.ScheduledFor
+	} else {
+		unset["scheduled_for"] = ""
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) != 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+func (change ArticleStatusChange) apply(a *models.Article) {
+	a.Status = change.Status
+	a.PublishedAt = change.PublishedAt
+	a.ScheduledFor = change.ScheduledFor
+	a.UpdatedAt = time.Now()
+	a.Version++
+}
+
+//the scheduler finds the due articles by this index
+func ArticleScheduleIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"status", "scheduled_for"},
+		Background: true,
+	}
+}
+
+//sort key of article by the sort field of page request
+func articlePageKey(a *models.Article, sortField string) pageKey {
+	switch sortField {
+	case "updated_at":
+		return pageKey{a.UpdatedAt, a.Id}
+	case "title":
+		return pageKey{a.Title, a.Id}
+	case "deleted_at":
+		if a.DeletedAt != nil {
+			return pageKey{*a.DeletedAt, a.Id}
+		}
+	}
+	return pageKey{a.CreatedAt, a.Id}
+}
+
+//keep limit items and set the cursor of the next page
+func newArticlePage(articles []models.Article, p PageRequest, total *int) *models.Page {
+	page := &models.Page{Items: articles, Total: total}
+	if len(articles) > p.Limit {
+		page.Items = articles[:p.Limit]
+		page.NextCursor = newCursor(articlePageKey(&articles[p.Limit-1], p.SortField), p).Encode()
+	}
+	return page
+}
+
+//the external ids are unique in articles of user, the articles without external id are not indexed
+//mgo doesn't support partial index so it's created by command
+func EnsureArticleExternalIdIndex(db *mgo.Database) error {
+	return db.Run(bson.D{
+		{Name: "createIndexes", Value: ARTICLE_COLLECTION_NAME},
+		{Name: "indexes", Value: []bson.M{{
+			"key":                     bson.D{{Name: "user_id", Value: 1}, {Name: "external_id", Value: 1}},
+			"name":                    "user_id_1_external_id_1",
+			"unique":                  true,
+			"background":              true,
+			"partialFilterExpression": bson.M{"external_id": bson.M{"$exists": true}},
+		}}},
+	}, nil)
+}
+
+//the articles without external id are found by their id
+func externalIdQuery(userId bson.ObjectId, externalId string) bson.M {
+	query := bson.M{"user_id": userId, "external_id": externalId}
+	if bson.IsObjectIdHex(externalId) {
+		delete(query, "external_id")
+		query["$or"] = []bson.M{
+			{"external_id": externalId},
+			{"_id": bson.ObjectIdHex(externalId), "external_id": bson.M{"$exists": false}},
+		}
+	}
+	return query
+}
+
+func hasExternalId(a *models.Article, externalId string) bool {
+	return a.ExternalId == externalId || (a.ExternalId == "" && a.Id.Hex() == externalId)
+}
+
+//text index of title and content, the title matches are more relevant
+func ArticleTextIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"$text:title", "$text:content"},
+		Name:       ARTICLE_TEXT_INDEX_NAME,
+		Weights:    map[string]int{"title": ARTICLE_TITLE_TEXT_WEIGHT, "content": ARTICLE_CONTENT_TEXT_WEIGHT},
+		Background: true,
+	}
+}
+
+type mongoArticleStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoArticleStore(s *mgo.Session, dbName string) ArticleStore {
+	return &mongoArticleStore{s, dbName}
+}
+
+func (s *mongoArticleStore) Insert(a *models.Article) error {
+	session := s.session.Copy()
+	defer session.Close()
+	err := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Insert(a)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *mongoArticleStore) FindById(id bson.ObjectId) (*models.Article, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	article := models.Article{}
+	if err := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Find(bson.M{"_id": id, "deleted_at": inTrash(false)}).One(&article); err != nil {
+		return nil, mongoError(err)
+	}
+	return &article, nil
+}
+
+func (s *mongoArticleStore) FindByExternalId(userId bson.ObjectId, externalId string) (*models.Article, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	article := models.Article{}
+	if err := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Find(externalIdQuery(userId, externalId)).One(&article); err != nil {
+		return nil, mongoError(err)
+	}
+	return &article, nil
+}
+
+func (s *mongoArticleStore) ForEach(filter ArticleFilter, fn func(a *models.Article) error) error {
+	session := s.session.Copy()
+	defer session.Close()
+	iter := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Find(filter.mongoQuery()).Sort("created_at", "_id").Iter()
+	article := models.Article{}
+	for iter.Next(&article) {
+		if err := fn(&article); err != nil {
+			iter.Close()
+			return err
+		}
+		article = models.Article{}
+	}
+	return iter.Close()
+}
+
+func (s *mongoArticleStore) FindPage(filter ArticleFilter, page PageRequest) (*models.Page, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.Article{}
+	total, err := findMongoPage(session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME), filter.mongoQuery(), page, &result)
+	if err != nil {
+		return nil, err
+	}
+	return newArticlePage(result, page, total), nil
+}
+
+//the query can have phrases in quotes and negated words such as -word
+func (s *mongoArticleStore) Search(userId bson.ObjectId, query string, limit int) ([]models.ArticleSearchResult, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.ArticleSearchResult{}
+	q := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Find(bson.M{"user_id": userId, "deleted_at": inTrash(false), "$text": bson.M{"$search": query}})
+	if err := q.Select(bson.M{"score": bson.M{"$meta": "textScore"}}).Sort("$textScore:score").Limit(limit).All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mongoArticleStore) UpdateOwned(id, userId bson.ObjectId, a *models.Article, versions []int) error {
+	session := s.session.Copy()
+	defer session.Close()
+	articleUpdateSet := bson.M{
+		"title":      a.Title,
+		"content":    a.Content,
+		"updated_at": time.Now(),
+	}
+	if a.Visibility != "" {
+		articleUpdateSet["visibility"] = a.Visibility
+	}
+	if a.Tags != nil {
+		articleUpdateSet["tags"] = a.Tags
+	}
+	c := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME)
+	query := bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(false)}
+	//NOTE: since we want to update article with one query we don't check is article own by this user separately
+	err := c.Update(withVersions(query, versions), bson.M{"$set": articleUpdateSet, "$inc": bson.M{"version": 1}})
+	return mongoVersionError(c, query, versions, err)
+}
+
+func (s *mongoArticleStore) TrashOwned(id, userId bson.ObjectId, deletedAt time.Time, versions []int) error {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME)
+	query := bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(false)}
+	err := c.Update(withVersions(query, versions), bson.M{"$set": bson.M{"deleted_at": deletedAt}, "$inc": bson.M{"version": 1}})
+	return mongoVersionError(c, query, versions, err)
+}
+
+func (s *mongoArticleStore) TrashAllOwned(userId bson.ObjectId, deletedAt time.Time) ([]bson.ObjectId, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME)
+	query := bson.M{"user_id": userId, "deleted_at": inTrash(false)}
+	articles := []models.Article{}
+	if err := c.Find(query).Select(bson.M{"_id": 1}).All(&articles); err != nil {
+		return nil, err
+	}
+	ids := []bson.ObjectId{}
+	for _, article := range articles {
+		ids = append(ids, article.Id)
+	}
+	if _, err := c.UpdateAll(bson.M{"_id": bson.M{"$in": ids}, "deleted_at": inTrash(false)}, bson.M{"$set": bson.M{"deleted_at": deletedAt}, "$inc": bson.M{"version": 1}}); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *mongoArticleStore) RestoreOwned(id, userId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	query := bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(true)}
+	return mongoError(session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Update(query, bson.M{"$unset": bson.M{"deleted_at": ""}, "$inc": bson.M{"version": 1}}))
+}
+
+func (s *mongoArticleStore) PurgeOwned(id, userId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Remove(bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(true)}))
+}
+
+func (s *mongoArticleStore) ChangeStatusOwned(id, userId bson.ObjectId, from []string, change ArticleStatusChange) error {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME)
+	query := bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(false)}
+	err := c.Update(bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(false), "status": statusCondition(from)}, change.mongoUpdate())
+	return mongoConditionError(c, query, ErrStatusConflict, err)
+}
+
+func (s *mongoArticleStore) PublishScheduled(now time.Time) ([]models.Article, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME)
+	due := []models.Article{}
+	if err := c.Find(bson.M{"status": models.SCHEDULED_STATUS, "scheduled_for": bson.M{"$lte": now}, "deleted_at": inTrash(false)}).All(&due); err != nil {
+		return nil, err
+	}
+	published := []models.Article{}
+	for _, article := range due {
+		change := ArticleStatusChange{Status: models.PUBLISHED_STATUS, PublishedAt: article.ScheduledFor}
+		//the article that published by another instance or changed meanwhile doesn't match
+		err := c.Update(bson.M{"_id": article.Id, "status": models.SCHEDULED_STATUS, "scheduled_for": article.ScheduledFor, "deleted_at": inTrash(false)}, change.mongoUpdate())
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+		change.apply(&article)
+		published = append(published, article)
+	}
+	return published, nil
+}
+
+func (s *mongoArticleStore) TagCounts(userId bson.ObjectId) ([]models.TagCount, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.TagCount{}
+	pipeline := []bson.M{
+		{"$match": bson.M{"user_id": userId, "deleted_at": inTrash(false), "tags": bson.M{"$exists": true}}},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Name: "count", Value: -1}, {Name: "_id", Value: 1}}},
+	}
+	if err := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Pipe(pipeline).All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mongoArticleStore) RenameTags(userId bson.ObjectId, from []string, to string) (int, error) {
+	renamed := renamedTags(from, to)
+	if renamed == nil {
+		return 0, nil
+	}
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME)
+	//add the to tag then remove the from tags, they can't change the same field in one update
+	info, err := c.UpdateAll(bson.M{"user_id": userId, "deleted_at": inTrash(false), "tags": bson.M{"$in": renamed}}, bson.M{
+		"$addToSet": bson.M{"tags": to},
+		"$set":      bson.M{"updated_at": time.Now()},
+		"$inc":      bson.M{"version": 1},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.UpdateAll(bson.M{"user_id": userId, "deleted_at": inTrash(false), "tags": bson.M{"$in": renamed}}, bson.M{"$pullAll": bson.M{"tags": renamed}}); err != nil {
+		return 0, err
+	}
+	return info.Updated, nil
+}
+
+func (s *mongoArticleStore) IncCommentCount(id bson.ObjectId, delta int) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).UpdateId(id, bson.M{"$inc": bson.M{"comment_count": delta}}))
+}
+
+func (s *mongoArticleStore) SetCollaboratorOwned(id, userId bson.ObjectId, collaborator models.Collaborator) error {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME)
+	query := bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(false), "collaborators.user_id": collaborator.UserId}
+	err := c.Update(query, bson.M{"$set": bson.M{"collaborators.$.role": collaborator.Role}})
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	//the article is not shared with this user yet
+	query["collaborators.user_id"] = bson.M{"$ne": collaborator.UserId}
+	return mongoError(c.Update(query, bson.M{"$push": bson.M{"collaborators": collaborator}}))
+}
+
+func (s *mongoArticleStore) RemoveCollaboratorOwned(id, userId, collaboratorId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	query := bson.M{"_id": id, "user_id": userId, "deleted_at": inTrash(false), "collaborators.user_id": collaboratorId}
+	return mongoError(session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).Update(query, bson.M{"$pull": bson.M{"collaborators": bson.M{"user_id": collaboratorId}}}))
+}
+
+func (s *mongoArticleStore) RemoveCollaboratorFromAll(collaboratorId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	_, err := session.DB(s.dbName).C(ARTICLE_COLLECTION_NAME).UpdateAll(bson.M{"collaborators.user_id": collaboratorId}, bson.M{"$pull": bson.M{"collaborators": bson.M{"user_id": collaboratorId}}})
+	return err
+}
+
+type memoryArticleStore struct {
+	mutex    sync.RWMutex
+	articles []models.Article
+}
+
+func NewMemoryArticleStore() ArticleStore {
+	return &memoryArticleStore{}
+}
+
+//copy the slices and times of article so the callers can't change the stored article
+func copyArticle(a models.Article) *models.Article {
+	a.Tags = copyStrings(a.Tags)
+	if a.Collaborators != nil {
+		a.Collaborators = append([]models.Collaborator{}, a.Collaborators...)
+	}
+	a.PublishedAt = copyTime(a.PublishedAt)
+	a.ScheduledFor = copyTime(a.ScheduledFor)
+	a.DeletedAt = copyTime(a.DeletedAt)
+	return &a
+}
+
+func (s *memoryArticleStore) Insert(a *models.Article) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	//the same as the unique index of external ids
+	for _, article := range s.articles {
+		if a.ExternalId != "" && article.UserId == a.UserId && article.ExternalId == a.ExternalId {
+			return ErrDuplicate
+		}
+	}
+	s.articles = append(s.articles, *copyArticle(*a))
+	return nil
+}
+
+func (s *memoryArticleStore) FindById(id bson.ObjectId) (*models.Article, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, article := range s.articles {
+		if article.Id == id && article.DeletedAt == nil {
+			return copyArticle(article), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryArticleStore) FindByExternalId(userId bson.ObjectId, externalId string) (*models.Article, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, article := range s.articles {
+		if article.UserId == userId && hasExternalId(&article, externalId) {
+			return copyArticle(article), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+//fn is called on the copies of articles after unlock, so it can use the store
+func (s *memoryArticleStore) ForEach(filter ArticleFilter, fn func(a *models.Article) error) error {
+	s.mutex.RLock()
+	matched := []models.Article{}
+	keys := []pageKey{}
+	for i := range s.articles {
+		if filter.match(&s.articles[i]) {
+			matched = append(matched, *copyArticle(s.articles[i]))
+			keys = append(keys, articlePageKey(&s.articles[i], "created_at"))
+		}
+	}
+	s.mutex.RUnlock()
+	indexes, _ := memoryPage(keys, PageRequest{Limit: len(keys), SortField: "created_at"})
+	for _, i := range indexes {
+		if err := fn(&matched[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryArticleStore) FindPage(filter ArticleFilter, page PageRequest) (*models.Page, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	matched := []models.Article{}
+	keys := []pageKey{}
+	for i := range s.articles {
+		if filter.match(&s.articles[i]) {
+			matched = append(matched, *copyArticle(s.articles[i]))
+			keys = append(keys, articlePageKey(&s.articles[i], page.SortField))
+		}
+	}
+	indexes, total := memoryPage(keys, page)
+	result := []models.Article{}
+	for _, i := range indexes {
+		result = append(result, matched[i])
+	}
+	return newArticlePage(result, page, total), nil
+}
+
+//fallback of mongodb text search, the articles that have any of the query terms
+func (s *memoryArticleStore) Search(userId bson.ObjectId, query string, limit int) ([]models.ArticleSearchResult, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	terms := textsearch.Terms(query)
+	result := []models.ArticleSearchResult{}
+	for _, article := range s.articles {
+		if article.UserId != userId || article.DeletedAt != nil {
+			continue
+		}
+		score := textsearch.Score(article.Title, terms, ARTICLE_TITLE_TEXT_WEIGHT) + textsearch.Score(article.Content, terms, ARTICLE_CONTENT_TEXT_WEIGHT)
+		if score > 0 {
+			result = append(result, models.ArticleSearchResult{Article: *copyArticle(article), Score: score})
+		}
+	}
+	sort.Sort(byScore(result))
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+//sort search results by score, the newer article first on equal scores
+type byScore []models.ArticleSearchResult
+
+func (r byScore) Len() int {
+	return len(r)
+}
+
+func (r byScore) Less(i, j int) bool {
+	if r[i].Score != r[j].Score {
+		return r[i].Score > r[j].Score
+	}
+	return r[i].CreatedAt.After(r[j].CreatedAt)
+}
+
+func (r byScore) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+func (s *memoryArticleStore) UpdateOwned(id, userId bson.ObjectId, a *models.Article, versions []int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id && s.articles[i].UserId == userId && s.articles[i].DeletedAt == nil {
+			if !matchVersion(s.articles[i].Version, versions) {
+				return ErrVersionConflict
+			}
+			s.articles[i].Version++
+			s.articles[i].Title = a.Title
+			s.articles[i].Content = a.Content
+			if a.Visibility != "" {
+				s.articles[i].Visibility = a.Visibility
+			}
+			if a.Tags != nil {
+				s.articles[i].Tags = copyStrings(a.Tags)
+			}
+			s.articles[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) TrashOwned(id, userId bson.ObjectId, deletedAt time.Time, versions []int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id && s.articles[i].UserId == userId && s.articles[i].DeletedAt == nil {
+			if !matchVersion(s.articles[i].Version, versions) {
+				return ErrVersionConflict
+			}
+			s.articles[i].DeletedAt = &deletedAt
+			s.articles[i].Version++
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) TrashAllOwned(userId bson.ObjectId, deletedAt time.Time) ([]bson.ObjectId, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := []bson.ObjectId{}
+	for i := range s.articles {
+		if s.articles[i].UserId == userId && s.articles[i].DeletedAt == nil {
+			s.articles[i].DeletedAt = &deletedAt
+			s.articles[i].Version++
+			ids = append(ids, s.articles[i].Id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *memoryArticleStore) RestoreOwned(id, userId bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id && s.articles[i].UserId == userId && s.articles[i].DeletedAt != nil {
+			s.articles[i].DeletedAt = nil
+			s.articles[i].Version++
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) PurgeOwned(id, userId bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id && s.articles[i].UserId == userId && s.articles[i].DeletedAt != nil {
+			s.articles = append(s.articles[:i], s.articles[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) ChangeStatusOwned(id, userId bson.ObjectId, from []string, change ArticleStatusChange) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id && s.articles[i].UserId == userId && s.articles[i].DeletedAt == nil {
+			if !hasStatus(&s.articles[i], from) {
+				return ErrStatusConflict
+			}
+			change.apply(&s.articles[i])
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) PublishScheduled(now time.Time) ([]models.Article, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	published := []models.Article{}
+	for i := range s.articles {
+		a := &s.articles[i]
+		if a.Status == models.SCHEDULED_STATUS && a.ScheduledFor != nil && a.DeletedAt == nil && !a.ScheduledFor.After(now) {
+			ArticleStatusChange{Status: models.PUBLISHED_STATUS, PublishedAt: a.ScheduledFor}.apply(a)
+			published = append(published, *copyArticle(*a))
+		}
+	}
+	return published, nil
+}
+
+func (s *memoryArticleStore) TagCounts(userId bson.ObjectId) ([]models.TagCount, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	counts := map[string]int{}
+	for _, article := range s.articles {
+		if article.UserId != userId || article.DeletedAt != nil {
+			continue
+		}
+		for _, tag := range article.Tags {
+			counts[tag]++
+		}
+	}
+	result := []models.TagCount{}
+	for name, count := range counts {
+		result = append(result, models.TagCount{Name: name, Count: count})
+	}
+	sort.Sort(byCount(result))
+	return result, nil
+}
+
+func (s *memoryArticleStore) RenameTags(userId bson.ObjectId, from []string, to string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	renamed := renamedTags(from, to)
+	updated := 0
+	for i := range s.articles {
+		a := &s.articles[i]
+		if a.UserId != userId || a.DeletedAt != nil {
+			continue
+		}
+		tags := []string{}
+		for _, tag := range a.Tags {
+			if !hasTag(renamed, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == len(a.Tags) {
+			continue
+		}
+		if !hasTag(tags, to) {
+			tags = append(tags, to)
+		}
+		a.Tags = tags
+		a.UpdatedAt = time.Now()
+		a.Version++
+		updated++
+	}
+	return updated, nil
+}
+
+//sort tags by count, the same counts sorted by name
+type byCount []models.TagCount
+
+func (r byCount) Len() int {
+	return len(r)
+}
+
+func (r byCount) Less(i, j int) bool {
+	if r[i].Count != r[j].Count {
+		return r[i].Count > r[j].Count
+	}
+	return r[i].Name < r[j].Name
+}
+
+func (r byCount) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+func (s *memoryArticleStore) IncCommentCount(id bson.ObjectId, delta int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id {
+			s.articles[i].CommentCount += delta
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) SetCollaboratorOwned(id, userId bson.ObjectId, collaborator models.Collaborator) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id && s.articles[i].UserId == userId && s.articles[i].DeletedAt == nil {
+			collaborators := []models.Collaborator{}
+			for _, c := range s.articles[i].Collaborators {
+				if c.UserId == collaborator.UserId {
+					c.Role = collaborator.Role
+					collaborator.UserId = ""
+				}
+				collaborators = append(collaborators, c)
+			}
+			if collaborator.UserId != "" {
+				collaborators = append(collaborators, collaborator)
+			}
+			s.articles[i].Collaborators = collaborators
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) RemoveCollaboratorOwned(id, userId, collaboratorId bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].Id == id && s.articles[i].UserId == userId && s.articles[i].DeletedAt == nil && s.articles[i].CollaboratorRole(collaboratorId) != "" {
+			s.articles[i].Collaborators = withoutCollaborator(s.articles[i].Collaborators, collaboratorId)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryArticleStore) RemoveCollaboratorFromAll(collaboratorId bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.articles {
+		if s.articles[i].CollaboratorRole(collaboratorId) != "" {
+			s.articles[i].Collaborators = withoutCollaborator(s.articles[i].Collaborators, collaboratorId)
+		}
+	}
+	return nil
+}
+
+//new slice of collaborators, the articles returned by find share the old one
+func withoutCollaborator(collaborators []models.Collaborator, collaboratorId bson.ObjectId) []models.Collaborator {
+	result := []models.Collaborator{}
+	for _, c := range collaborators {
+		if c.UserId != collaboratorId {
+			result = append(result, c)
+		}
+	}
+	return result
+}
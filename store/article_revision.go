@@ -0,0 +1,168 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+const (
+	//the concurrent changes of article get the same revision number, so the insert tried again
+	MAX_REVISION_INSERT_ATTEMPTS = 5
+)
+
+//storage of article revisions, the revisions are never updated
+type ArticleRevisionStore interface {
+	//insert the revision with the next revision number of article
+	Insert(r *models.ArticleRevision) error
+	//the revisions of article, newest first
+	FindByArticleId(articleId bson.ObjectId) ([]models.ArticleRevision, error)
+	FindByRevision(articleId bson.ObjectId, revision int) (*models.ArticleRevision, error)
+	RemoveByArticleId(articleId bson.ObjectId) error
+	//move the revisions to trash with their article, nil deletedAt restores them
+	SetDeletedAtByArticleId(articleId bson.ObjectId, deletedAt *time.Time) error
+}
+
+//the revision number is unique in article
+func ArticleRevisionIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"article_id", "-revision"},
+		Unique:     true,
+		Background: true,
+	}
+}
+
+type mongoArticleRevisionStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoArticleRevisionStore(s *mgo.Session, dbName string) ArticleRevisionStore {
+	return &mongoArticleRevisionStore{s, dbName}
+}
+
+func (s *mongoArticleRevisionStore) Insert(r *models.ArticleRevision) error {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(ARTICLE_REVISION_COLLECTION_NAME)
+	var err error
+	for i := 0; i < MAX_REVISION_INSERT_ATTEMPTS; i++ {
+		last := models.ArticleRevision{}
+		if err = c.Find(bson.M{"article_id": r.ArticleId}).Sort("-revision").Select(bson.M{"revision": 1}).One(&last); err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		r.Revision = last.Revision + 1
+		//the unique index rejects the revision number that inserted by another change
+		if err = c.Insert(r); !mgo.IsDup(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *mongoArticleRevisionStore) FindByArticleId(articleId bson.ObjectId) ([]models.ArticleRevision, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.ArticleRevision{}
+	if err := session.DB(s.dbName).C(ARTICLE_REVISION_COLLECTION_NAME).Find(bson.M{"article_id": articleId}).Sort("-revision").All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mongoArticleRevisionStore) FindByRevision(articleId bson.ObjectId, revision int) (*models.ArticleRevision, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := models.ArticleRevision{}
+	if err := session.DB(s.dbName).C(ARTICLE_REVISION_COLLECTION_NAME).Find(bson.M{"article_id": articleId, "revision": revision}).One(&result); err != nil {
+		return nil, mongoError(err)
+	}
+	return &result, nil
+}
+
+func (s *mongoArticleRevisionStore) RemoveByArticleId(articleId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	_, err := session.DB(s.dbName).C(ARTICLE_REVISION_COLLECTION_NAME).RemoveAll(bson.M{"article_id": articleId})
+	return err
+}
+
+func (s *mongoArticleRevisionStore) SetDeletedAtByArticleId(articleId bson.ObjectId, deletedAt *time.Time) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return setDeletedAtByArticleId(session.DB(s.dbName).C(ARTICLE_REVISION_COLLECTION_NAME), articleId, deletedAt)
+}
+
+type memoryArticleRevisionStore struct {
+	mutex     sync.RWMutex
+	revisions []models.ArticleRevision
+}
+
+func NewMemoryArticleRevisionStore() ArticleRevisionStore {
+	return &memoryArticleRevisionStore{}
+}
+
+func (s *memoryArticleRevisionStore) Insert(r *models.ArticleRevision) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	r.Revision = 1
+	for _, revision := range s.revisions {
+		if revision.ArticleId == r.ArticleId && revision.Revision >= r.Revision {
+			r.Revision = revision.Revision + 1
+		}
+	}
+	s.revisions = append(s.revisions, *r)
+	return nil
+}
+
+func (s *memoryArticleRevisionStore) FindByArticleId(articleId bson.ObjectId) ([]models.ArticleRevision, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	result := []models.ArticleRevision{}
+	//iterate backward since the revisions appended in order of number
+	for i := len(s.revisions) - 1; i >= 0; i-- {
+		if s.revisions[i].ArticleId == articleId {
+			result = append(result, s.revisions[i])
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryArticleRevisionStore) FindByRevision(articleId bson.ObjectId, revision int) (*models.ArticleRevision, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, r := range s.revisions {
+		if r.ArticleId == articleId && r.Revision == revision {
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryArticleRevisionStore) RemoveByArticleId(articleId bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	revisions := []models.ArticleRevision{}
+	for _, r := range s.revisions {
+		if r.ArticleId != articleId {
+			revisions = append(revisions, r)
+		}
+	}
+	s.revisions = revisions
+	return nil
+}
+
+func (s *memoryArticleRevisionStore) SetDeletedAtByArticleId(articleId bson.ObjectId, deletedAt *time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.revisions {
+		if s.revisions[i].ArticleId == articleId {
+			s.revisions[i].DeletedAt = deletedAt
+		}
+	}
+	return nil
+}
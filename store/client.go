@@ -0,0 +1,198 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+//storage of clients (applications) that can communicate with API
+type ClientStore interface {
+	Insert(c *models.Client) error
+	FindById(id bson.ObjectId) (*models.Client, error)
+	//page of all clients, the items are []models.Client
+	FindPage(page PageRequest) (*models.Page, error)
+	//update name, description, enable status, platform type, redirect URIs and allowed scopes
+	//the allowed scopes are kept when they're nil, so a client can't get all of the scopes by an update that doesn't have them
+	//the versions are the conditions of If-Match, nil means any version and the version increased by each update
+	Update(id bson.ObjectId, c *models.Client, versions []int) error
+	Remove(id bson.ObjectId, versions []int) error
+}
+
+//sort key of client by the sort field of page request
+func clientPageKey(c *models.Client, sortField string) pageKey {
+	switch sortField {
+	case "updated_at":
+		return pageKey{c.UpdatedAt, c.AppId}
+	case "name":
+		return pageKey{c.Name, c.AppId}
+	}
+	return pageKey{c.CreatedAt, c.AppId}
+}
+
+//keep limit items and set the cursor of the next page
+func newClientPage(clients []models.Client, p PageRequest, total *int) *models.Page {
+	page := &models.Page{Items: clients, Total: total}
+	if len(clients) > p.Limit {
+		page.Items = clients[:p.Limit]
+		page.NextCursor = newCursor(clientPageKey(&clients[p.Limit-1], p.SortField), p).Encode()
+	}
+	return page
+}
+
+type mongoClientStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoClientStore(s *mgo.Session, dbName string) ClientStore {
+	return &mongoClientStore{s, dbName}
+}
+
+func (s *mongoClientStore) Insert(c *models.Client) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return session.DB(s.dbName).C(CLIENT_COLLECTION_NAME).Insert(c)
+}
+
+func (s *mongoClientStore) FindById(id bson.ObjectId) (*models.Client, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	client := models.Client{}
+	if err := session.DB(s.dbName).C(CLIENT_COLLECTION_NAME).FindId(id).One(&client); err != nil {
+		return nil, mongoError(err)
+	}
+	return &client, nil
+}
+
+func (s *mongoClientStore) FindPage(page PageRequest) (*models.Page, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.Client{}
+	total, err := findMongoPage(session.DB(s.dbName).C(CLIENT_COLLECTION_NAME), bson.M{}, page, &result)
+	if err != nil {
+		return nil, err
+	}
+	return newClientPage(result, page, total), nil
+}
+
+func (s *mongoClientStore) Update(id bson.ObjectId, cli *models.Client, versions []int) error {
+	session := s.session.Copy()
+	defer session.Close()
+	clientUpdateSet := bson.M{
+		"name":          cli.Name,
+		"description":   cli.Description,
+		"enable_status": cli.IsEnable,
+		"platform_type": cli.PlatformType,
+		"redirect_uris": cli.RedirectURIs,
+		"updated_at":    time.Now(),
+	}
+	if cli.AllowedScopes != nil {
+		clientUpdateSet["allowed_scopes"] = cli.AllowedScopes
+	}
+	c := session.DB(s.dbName).C(CLIENT_COLLECTION_NAME)
+	query := bson.M{"_id": id}
+	//update the client information by one query
+	err := c.Update(withVersions(query, versions), bson.M{"$set": clientUpdateSet, "$inc": bson.M{"version": 1}})
+	return mongoVersionError(c, query, versions, err)
+}
+
+func (s *mongoClientStore) Remove(id bson.ObjectId, versions []int) error {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(CLIENT_COLLECTION_NAME)
+	query := bson.M{"_id": id}
+	return mongoVersionError(c, query, versions, c.Remove(withVersions(query, versions)))
+}
+
+type memoryClientStore struct {
+	mutex   sync.RWMutex
+	clients []models.Client
+}
+
+func NewMemoryClientStore() ClientStore {
+	return &memoryClientStore{}
+}
+
+//copy the slices of client so the callers can't change the stored client
+func copyClient(c models.Client) *models.Client {
+	c.RedirectURIs = copyStrings(c.RedirectURIs)
+	c.AllowedScopes = copyStrings(c.AllowedScopes)
+	return &c
+}
+
+func (s *memoryClientStore) Insert(c *models.Client) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clients = append(s.clients, *copyClient(*c))
+	return nil
+}
+
+func (s *memoryClientStore) FindById(id bson.ObjectId) (*models.Client, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, client := range s.clients {
+		if client.AppId == id {
+			return copyClient(client), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryClientStore) FindPage(page PageRequest) (*models.Page, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := []pageKey{}
+	for i := range s.clients {
+		keys = append(keys, clientPageKey(&s.clients[i], page.SortField))
+	}
+	indexes, total := memoryPage(keys, page)
+	result := []models.Client{}
+	for _, i := range indexes {
+		result = append(result, *copyClient(s.clients[i]))
+	}
+	return newClientPage(result, page, total), nil
+}
+
+func (s *memoryClientStore) Update(id bson.ObjectId, c *models.Client, versions []int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.clients {
+		if s.clients[i].AppId == id {
+			if !matchVersion(s.clients[i].Version, versions) {
+				return ErrVersionConflict
+			}
+			s.clients[i].Version++
+			s.clients[i].Name = c.Name
+			s.clients[i].Description = c.Description
+			s.clients[i].IsEnable = c.IsEnable
+			s.clients[i].PlatformType = c.PlatformType
+			s.clients[i].RedirectURIs = copyStrings(c.RedirectURIs)
+			if c.AllowedScopes != nil {
+				s.clients[i].AllowedScopes = copyStrings(c.AllowedScopes)
+			}
+			s.clients[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryClientStore) Remove(id bson.ObjectId, versions []int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.clients {
+		if s.clients[i].AppId == id {
+			if !matchVersion(s.clients[i].Version, versions) {
+				return ErrVersionConflict
+			}
+			s.clients = append(s.clients[:i], s.clients[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+//storage of comments of articles
+type CommentStore interface {
+	Insert(c *models.Comment) error
+	FindById(id bson.ObjectId) (*models.Comment, error)
+	//page of the top level comments of article, the items are []models.Comment
+	FindPage(filter CommentFilter, page PageRequest) (*models.Page, error)
+	//the replies of comments that match the filter, oldest first
+	FindReplies(parentIds []bson.ObjectId, filter CommentFilter) ([]models.Comment, error)
+	//update content of comment if it's written by this user
+	UpdateContentOwned(id, userId bson.ObjectId, content string) error
+	//ErrStatusConflict when the comment is already hidden or not hidden
+	SetHidden(id bson.ObjectId, hidden bool) error
+	//remove the comment with its replies, it returns the number of removed comments that were not hidden
+	Remove(id bson.ObjectId) (int, error)
+	RemoveByArticleId(articleId bson.ObjectId) error
+	//move the comments to trash with their article, nil deletedAt restores them
+	SetDeletedAtByArticleId(articleId bson.ObjectId, deletedAt *time.Time) error
+}
+
+//the comments of article that the viewer can see
+type CommentFilter struct {
+	ArticleId     bson.ObjectId
+	//the viewer always see its own hidden comments
+	ViewerId      bson.ObjectId
+	IncludeHidden bool
+}
+
+func (f CommentFilter) mongoQuery() bson.M {
+	query := bson.M{"article_id": f.ArticleId}
+	if !f.IncludeHidden {
+		query["$or"] = []bson.M{{"hidden": false}, {"user_id": f.ViewerId}}
+	}
+	return query
+}
+
+func (f CommentFilter) match(c *models.Comment) bool {
+	return c.ArticleId == f.ArticleId && (f.IncludeHidden || !c.IsHidden || c.UserId == f.ViewerId)
+}
+
+//comments sorted by created_at
+func commentPageKey(c *models.Comment) pageKey {
+	return pageKey{c.CreatedAt, c.Id}
+}
+
+//keep limit items and set the cursor of the next page
+func newCommentPage(comments []models.Comment, p PageRequest, total *int) *models.Page {
+	page := &models.Page{Items: comments, Total: total}
+	if len(comments) > p.Limit {
+		page.Items = comments[:p.Limit]
+		page.NextCursor = newCursor(commentPageKey(&comments[p.Limit-1]), p).Encode()
+	}
+	return page
+}
+
+type mongoCommentStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoCommentStore(s *mgo.Session, dbName string) CommentStore {
+	return &mongoCommentStore{s, dbName}
+}
+
+func (s *mongoCommentStore) Insert(c *models.Comment) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return session.DB(s.dbName).C(COMMENT_COLLECTION_NAME).Insert(c)
+}
+
+func (s *mongoCommentStore) FindById(id bson.ObjectId) (*models.Comment, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	comment := models.Comment{}
+	if err := session.DB(s.dbName).C(COMMENT_COLLECTION_NAME).FindId(id).One(&comment); err != nil {
+		return nil, mongoError(err)
+	}
+	return &comment, nil
+}
+
+func (s *mongoCommentStore) FindPage(filter CommentFilter, page PageRequest) (*models.Page, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	query := filter.mongoQuery()
+	query["parent_id"] = bson.M{"$exists": false}
+	result := []models.Comment{}
+	total, err := findMongoPage(session.DB(s.dbName).C(COMMENT_COLLECTION_NAME), query, page, &result)
+	if err != nil {
+		return nil, err
+	}
+	return newCommentPage(result, page, total), nil
+}
+
+func (s *mongoCommentStore) FindReplies(parentIds []bson.ObjectId, filter CommentFilter) ([]models.Comment, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	query := filter.mongoQuery()
+	query["parent_id"] = bson.M{"$in": parentIds}
+	result := []models.Comment{}
+	if err := session.DB(s.dbName).C(COMMENT_COLLECTION_NAME).Find(query).Sort("created_at", "_id").All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mongoCommentStore) UpdateContentOwned(id, userId bson.ObjectId, content string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(COMMENT_COLLECTION_NAME).Update(bson.M{"_id": id, "user_id": userId}, bson.M{"$set": bson.M{"content": content, "updated_at": time.Now()}}))
+}
+
+func (s *mongoCommentStore) SetHidden(id bson.ObjectId, hidden bool) error {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(COMMENT_COLLECTION_NAME)
+	err := c.Update(bson.M{"_id": id, "hidden": !hidden}, bson.M{"$set": bson.M{"hidden": hidden}})
+	return mongoConditionError(c, bson.M{"_id": id}, ErrStatusConflict, err)
+}
+
+func (s *mongoCommentStore) Remove(id bson.ObjectId) (int, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(COMMENT_COLLECTION_NAME)
+	query := bson.M{"$or": []bson.M{{"_id": id}, {"parent_id": id}}}
+	visible, err := c.Find(bson.M{"$and": []bson.M{query, {"hidden": false}}}).Count()
+	if err != nil {
+		return 0, err
+	}
+	info, err := c.RemoveAll(query)
+	if err != nil {
+		return 0, err
+	}
+	if info.Removed == 0 {
+		return 0, ErrNotFound
+	}
+	return visible, nil
+}
+
+func (s *mongoCommentStore) RemoveByArticleId(articleId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	_, err := session.DB(s.dbName).C(COMMENT_COLLECTION_NAME).RemoveAll(bson.M{"article_id": articleId})
+	return err
+}
+
+func (s *mongoCommentStore) SetDeletedAtByArticleId(articleId bson.ObjectId, deletedAt *time.Time) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return setDeletedAtByArticleId(session.DB(s.dbName).C(COMMENT_COLLECTION_NAME), articleId, deletedAt)
+}
+
+type memoryCommentStore struct {
+	mutex    sync.RWMutex
+	comments []models.Comment
+}
+
+func NewMemoryCommentStore() CommentStore {
+	return &memoryCommentStore{}
+}
+
+func (s *memoryCommentStore) Insert(c *models.Comment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.comments = append(s.comments, *c)
+	return nil
+}
+
+func (s *memoryCommentStore) FindById(id bson.ObjectId) (*models.Comment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, comment := range s.comments {
+		if comment.Id == id {
+			return &comment, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryCommentStore) FindPage(filter CommentFilter, page PageRequest) (*models.Page, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	matched := []models.Comment{}
+	keys := []pageKey{}
+	for i := range s.comments {
+		if s.comments[i].ParentId == "" && filter.match(&s.comments[i]) {
+			matched = append(matched, s.comments[i])
+			keys = append(keys, commentPageKey(&s.comments[i]))
+		}
+	}
+	indexes, total := memoryPage(keys, page)
+	result := []models.Comment{}
+	for _, i := range indexes {
+		result = append(result, matched[i])
+	}
+	return newCommentPage(result, page, total), nil
+}
+
+//the comments appended in order of time
+func (s *memoryCommentStore) FindReplies(parentIds []bson.ObjectId, filter CommentFilter) ([]models.Comment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	parents := map[bson.ObjectId]bool{}
+	for _, id := range parentIds {
+		parents[id] = true
+	}
+	result := []models.Comment{}
+	for i := range s.comments {
+		if parents[s.comments[i].ParentId] && filter.match(&s.comments[i]) {
+			result = append(result, s.comments[i])
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryCommentStore) UpdateContentOwned(id, userId bson.ObjectId, content string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.comments {
+		if s.comments[i].Id == id && s.comments[i].UserId == userId {
+			s.comments[i].Content = content
+			s.comments[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryCommentStore) SetHidden(id bson.ObjectId, hidden bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.comments {
+		if s.comments[i].Id == id {
+			if s.comments[i].IsHidden == hidden {
+				return ErrStatusConflict
+			}
+			s.comments[i].IsHidden = hidden
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryCommentStore) Remove(id bson.ObjectId) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	comments := []models.Comment{}
+	removed, visible := 0, 0
+	for _, comment := range s.comments {
+		if comment.Id != id && comment.ParentId != id {
+			comments = append(comments, comment)
+			continue
+		}
+		removed++
+		if !comment.IsHidden {
+			visible++
+		}
+	}
+	if removed == 0 {
+		return 0, ErrNotFound
+	}
+	s.comments = comments
+	return visible, nil
+}
+
+func (s *memoryCommentStore) RemoveByArticleId(articleId bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	comments := []models.Comment{}
+	for _, comment := range s.comments {
+		if comment.ArticleId != articleId {
+			comments = append(comments, comment)
+		}
+	}
+	s.comments = comments
+	return nil
+}
+
+func (s *memoryCommentStore) SetDeletedAtByArticleId(articleId bson.ObjectId, deletedAt *time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.comments {
+		if s.comments[i].ArticleId == articleId {
+			s.comments[i].DeletedAt = deletedAt
+		}
+	}
+	return nil
+}
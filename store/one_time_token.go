@@ -0,0 +1,96 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+//storage of the tokens sent by email, expired ones removed by TTL index on expire_at
+type OneTimeTokenStore interface {
+	Insert(t *models.OneTimeToken) error
+	//find the not expired token and remove it in one operation, so it can be used only once
+	Consume(purpose, hashedToken string) (*models.OneTimeToken, error)
+	//remove the tokens of user for this purpose
+	RemoveByUserId(userId bson.ObjectId, purpose string) error
+}
+
+type mongoOneTimeTokenStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoOneTimeTokenStore(s *mgo.Session, dbName string) OneTimeTokenStore {
+	return &mongoOneTimeTokenStore{s, dbName}
+}
+
+func (s *mongoOneTimeTokenStore) Insert(t *models.OneTimeToken) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return session.DB(s.dbName).C(ONE_TIME_TOKEN_COLLECTION_NAME).Insert(t)
+}
+
+func (s *mongoOneTimeTokenStore) Consume(purpose, hashedToken string) (*models.OneTimeToken, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	t := models.OneTimeToken{}
+	query := bson.M{"purpose": purpose, "hashed_token": hashedToken, "expire_at": bson.M{"$gt": time.Now()}}
+	if _, err := session.DB(s.dbName).C(ONE_TIME_TOKEN_COLLECTION_NAME).Find(query).Apply(mgo.Change{Remove: true}, &t); err != nil {
+		return nil, mongoError(err)
+	}
+	return &t, nil
+}
+
+func (s *mongoOneTimeTokenStore) RemoveByUserId(userId bson.ObjectId, purpose string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	_, err := session.DB(s.dbName).C(ONE_TIME_TOKEN_COLLECTION_NAME).RemoveAll(bson.M{"user_id": userId, "purpose": purpose})
+	return err
+}
+
+type memoryOneTimeTokenStore struct {
+	mutex  sync.Mutex
+	tokens []models.OneTimeToken
+}
+
+func NewMemoryOneTimeTokenStore() OneTimeTokenStore {
+	return &memoryOneTimeTokenStore{}
+}
+
+func (s *memoryOneTimeTokenStore) Insert(t *models.OneTimeToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	token := *t
+	token.Scopes = copyStrings(t.Scopes)
+	s.tokens = append(s.tokens, token)
+	return nil
+}
+
+func (s *memoryOneTimeTokenStore) Consume(purpose, hashedToken string) (*models.OneTimeToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, t := range s.tokens {
+		if t.Purpose == purpose && t.HashedToken == hashedToken && t.ExpireAt.After(time.Now()) {
+			s.tokens = append(s.tokens[:i], s.tokens[i+1:]...)
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryOneTimeTokenStore) RemoveByUserId(userId bson.ObjectId, purpose string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	kept := s.tokens[:0]
+	for _, t := range s.tokens {
+		if t.UserId != userId || t.Purpose != purpose {
+			kept = append(kept, t)
+		}
+	}
+	s.tokens = kept
+	return nil
+}
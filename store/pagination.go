@@ -0,0 +1,219 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	DEFAULT_PAGE_LIMIT = 20
+	MAX_PAGE_LIMIT     = 100
+)
+
+var (
+	ErrNotValidCursor = errors.New("store: cursor is not valid")
+)
+
+//keyset pagination, the page starts after the cursor item in the sort order and _id breaks the ties
+type PageRequest struct {
+	Limit      int
+	//bson field that items sorted by such as created_at
+	SortField  string
+	Descending bool
+	//nil for the first page
+	Cursor     *Cursor
+	//count all of the items that match the filter, it's an extra query
+	WithTotal  bool
+}
+
+//position of the last item of page
+type Cursor struct {
+	Field string        `bson:"f"`
+	Value interface{}   `bson:"v"`
+	Id    bson.ObjectId `bson:"id"`
+}
+
+//opaque string that clients send back as cursor query parameter
+func (c *Cursor) Encode() string {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+//decode the cursor, it should be made for the same sort field
+func DecodeCursor(value, sortField string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrNotValidCursor
+	}
+	c := Cursor{}
+	if err := bson.Unmarshal(data, &c); err != nil || c.Field != sortField || !c.Id.Valid() {
+		return nil, ErrNotValidCursor
+	}
+	//the value is used in the query, so the forged cursors with operators such as {"$ne": ""} are rejected
+	switch c.Value.(type) {
+	case time.Time, string:
+		return &c, nil
+	}
+	return nil, ErrNotValidCursor
+}
+
+//the filter with the condition of items after cursor
+func (p PageRequest) mongoFilter(filter bson.M) bson.M {
+	if p.Cursor == nil {
+		return filter
+	}
+	op := "$gt"
+	if p.Descending {
+		op = "$lt"
+	}
+	after := bson.M{"$or": []bson.M{
+		{p.SortField: bson.M{op: p.Cursor.Value}},
+		{p.SortField: p.Cursor.Value, "_id": bson.M{op: p.Cursor.Id}},
+	}}
+	return bson.M{"$and": []bson.M{filter, after}}
+}
+
+func (p PageRequest) mongoSort() []string {
+	if p.Descending {
+		return []string{"-" + p.SortField, "-_id"}
+	}
+	return []string{p.SortField, "_id"}
+}
+
+//find one more item than limit to find out there is next page, result should be pointer to slice
+func findMongoPage(c *mgo.Collection, filter bson.M, p PageRequest, result interface{}) (*int, error) {
+	if err := c.Find(p.mongoFilter(filter)).Sort(p.mongoSort()...).Limit(p.Limit + 1).All(result); err != nil {
+		return nil, err
+	}
+	if !p.WithTotal {
+		return nil, nil
+	}
+	total, err := c.Find(filter).Count()
+	if err != nil {
+		return nil, err
+	}
+	return &total, nil
+}
+
+//sort value and id of an item, used to make the cursor and sort in memory
+type pageKey struct {
+	value interface{}
+	id    bson.ObjectId
+}
+
+func newCursor(key pageKey, p PageRequest) *Cursor {
+	return &Cursor{p.SortField, key.value, key.id}
+}
+
+//indexes of the items of page in memory stores, the keys are the sort keys of all matched items
+func memoryPage(keys []pageKey, p PageRequest) ([]int, *int) {
+	indexes := make([]int, len(keys))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.Sort(&pageSorter{keys, indexes, p.Descending})
+	result := []int{}
+	for _, i := range indexes {
+		if p.Cursor != nil && !isAfterCursor(keys[i], p) {
+			continue
+		}
+		result = append(result, i)
+		//one more item to find out there is next page
+		if len(result) > p.Limit {
+			break
+		}
+	}
+	if !p.WithTotal {
+		return result, nil
+	}
+	total := len(keys)
+	return result, &total
+}
+
+func isAfterCursor(key pageKey, p PageRequest) bool {
+	cmp := compareKeys(key, pageKey{p.Cursor.Value, p.Cursor.Id})
+	if p.Descending {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+//compare the same way as mongodb, the times saved in milliseconds
+func compareKeys(a, b pageKey) int {
+	cmp := 0
+	switch av := a.value.(type) {
+	case time.Time:
+		bv, _ := b.value.(time.Time)
+		am, bm := av.UnixNano()/int64(time.Millisecond), bv.UnixNano()/int64(time.Millisecond)
+		if am < bm {
+			cmp = -1
+		} else if am > bm {
+			cmp = 1
+		}
+	case string:
+		bv, _ := b.value.(string)
+		if av < bv {
+			cmp = -1
+		} else if av > bv {
+			cmp = 1
+		}
+	}
+	if cmp != 0 {
+		return cmp
+	}
+	if a.id < b.id {
+		return -1
+	} else if a.id > b.id {
+		return 1
+	}
+	return 0
+}
+
+type pageSorter struct {
+	keys       []pageKey
+	indexes    []int
+	descending bool
+}
+
+func (s *pageSorter) Len() int {
+	return len(s.indexes)
+}
+
+func (s *pageSorter) Less(i, j int) bool {
+	cmp := compareKeys(s.keys[s.indexes[i]], s.keys[s.indexes[j]])
+	if s.descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (s *pageSorter) Swap(i, j int) {
+	s.indexes[i], s.indexes[j] = s.indexes[j], s.indexes[i]
+}
+
+//the condition of time range, nil when both of them are zero
+func timeRange(from, to time.Time) bson.M {
+	if from.IsZero() && to.IsZero() {
+		return nil
+	}
+	r := bson.M{}
+	if !from.IsZero() {
+		r["$gte"] = from
+	}
+	if !to.IsZero() {
+		r["$lt"] = to
+	}
+	return r
+}
+
+func inTimeRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+//storage of role definitions, the name of role is its id
+type RoleStore interface {
+	//ErrDuplicate if there is a role with this name
+	Insert(r *models.Role) error
+	//all of roles sorted by name
+	FindAll() ([]models.Role, error)
+	FindByName(name string) (*models.Role, error)
+	//update description and permissions
+	Update(name string, r *models.Role) error
+	Remove(name string) error
+}
+
+type rolesByName []models.Role
+
+func (r rolesByName) Len() int           { return len(r) }
+func (r rolesByName) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r rolesByName) Less(i, j int) bool { return r[i].Name < r[j].Name }
+
+type mongoRoleStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoRoleStore(s *mgo.Session, dbName string) RoleStore {
+	return &mongoRoleStore{s, dbName}
+}
+
+func (s *mongoRoleStore) Insert(r *models.Role) error {
+	session := s.session.Copy()
+	defer session.Close()
+	err := session.DB(s.dbName).C(ROLE_COLLECTION_NAME).Insert(r)
+	if mgo.IsDup(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *mongoRoleStore) FindAll() ([]models.Role, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.Role{}
+	if err := session.DB(s.dbName).C(ROLE_COLLECTION_NAME).Find(nil).Sort("_id").All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mongoRoleStore) FindByName(name string) (*models.Role, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	role := models.Role{}
+	if err := session.DB(s.dbName).C(ROLE_COLLECTION_NAME).FindId(name).One(&role); err != nil {
+		return nil, mongoError(err)
+	}
+	return &role, nil
+}
+
+func (s *mongoRoleStore) Update(name string, r *models.Role) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(ROLE_COLLECTION_NAME).UpdateId(name, bson.M{"$set": bson.M{
+		"description": r.Description,
+		"permissions": r.Permissions,
+		"updated_at":  time.Now(),
+	}}))
+}
+
+func (s *mongoRoleStore) Remove(name string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(ROLE_COLLECTION_NAME).RemoveId(name))
+}
+
+type memoryRoleStore struct {
+	mutex sync.RWMutex
+	roles []models.Role
+}
+
+func NewMemoryRoleStore() RoleStore {
+	return &memoryRoleStore{}
+}
+
+func (s *memoryRoleStore) Insert(r *models.Role) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, role := range s.roles {
+		if role.Name == r.Name {
+			return ErrDuplicate
+		}
+	}
+	role := *r
+	role.Permissions = copyStrings(r.Permissions)
+	s.roles = append(s.roles, role)
+	return nil
+}
+
+func (s *memoryRoleStore) FindAll() ([]models.Role, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	result := []models.Role{}
+	for _, role := range s.roles {
+		role.Permissions = copyStrings(role.Permissions)
+		result = append(result, role)
+	}
+	sort.Sort(rolesByName(result))
+	return result, nil
+}
+
+func (s *memoryRoleStore) FindByName(name string) (*models.Role, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, role := range s.roles {
+		if role.Name == name {
+			role.Permissions = copyStrings(role.Permissions)
+			return &role, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryRoleStore) Update(name string, r *models.Role) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.roles {
+		if s.roles[i].Name == name {
+			s.roles[i].Description = r.Description
+			s.roles[i].Permissions = append([]string{}, r.Permissions...)
+			s.roles[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryRoleStore) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.roles {
+		if s.roles[i].Name == name {
+			s.roles = append(s.roles[:i], s.roles[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"sync"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+//storage of security events such as refresh token reuse
+type SecurityEventStore interface {
+	Insert(e *models.SecurityEvent) error
+	//the events of user, newest first
+	FindByUserId(userId bson.ObjectId) ([]models.SecurityEvent, error)
+}
+
+type mongoSecurityEventStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoSecurityEventStore(s *mgo.Session, dbName string) SecurityEventStore {
+	return &mongoSecurityEventStore{s, dbName}
+}
+
+func (s *mongoSecurityEventStore) Insert(e *models.SecurityEvent) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return session.DB(s.dbName).C(SECURITY_EVENT_COLLECTION_NAME).Insert(e)
+}
+
+func (s *mongoSecurityEventStore) FindByUserId(userId bson.ObjectId) ([]models.SecurityEvent, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.SecurityEvent{}
+	if err := session.DB(s.dbName).C(SECURITY_EVENT_COLLECTION_NAME).Find(bson.M{"user_id": userId}).Sort("-created_at").All(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type memorySecurityEventStore struct {
+	mutex  sync.RWMutex
+	events []models.SecurityEvent
+}
+
+func NewMemorySecurityEventStore() SecurityEventStore {
+	return &memorySecurityEventStore{}
+}
+
+func (s *memorySecurityEventStore) Insert(e *models.SecurityEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, *e)
+	return nil
+}
+
+func (s *memorySecurityEventStore) FindByUserId(userId bson.ObjectId) ([]models.SecurityEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	result := []models.SecurityEvent{}
+	//iterate backward since the events appended in order of time
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].UserId == userId {
+			result = append(result, s.events[i])
+		}
+	}
+	return result, nil
+}
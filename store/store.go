@@ -0,0 +1,177 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	USER_COLLECTION_NAME             = "users"
+	ACCESS_TOKEN_COLLECTION_NAME     = "accessTokens"
+	ARTICLE_COLLECTION_NAME          = "articles"
+	CLIENT_COLLECTION_NAME           = "clients"
+	SECURITY_EVENT_COLLECTION_NAME   = "securityEvents"
+	ONE_TIME_TOKEN_COLLECTION_NAME   = "oneTimeTokens"
+	ARTICLE_REVISION_COLLECTION_NAME = "articleRevisions"
+	COMMENT_COLLECTION_NAME          = "comments"
+	ROLE_COLLECTION_NAME             = "roles"
+)
+
+var (
+	ErrNotFound        = errors.New("store: not found")
+	ErrVersionConflict = errors.New("store: version of item is changed")
+	ErrStatusConflict  = errors.New("store: status of item doesn't allow this change")
+	ErrDuplicate       = errors.New("store: item already exists")
+)
+
+//all of the stores that controllers and middlewares need
+type Stores struct {
+	Users            UserStore
+	Articles         ArticleStore
+	Clients          ClientStore
+	AccessTokens     AccessTokenStore
+	SecurityEvents   SecurityEventStore
+	OneTimeTokens    OneTimeTokenStore
+	ArticleRevisions ArticleRevisionStore
+	Comments         CommentStore
+	Roles            RoleStore
+}
+
+//stores backed by mongodb, the session copied in each operation
+func NewMongoStores(s *mgo.Session, dbName string) *Stores {
+	return &Stores{
+		Users:            NewMongoUserStore(s, dbName),
+		Articles:         NewMongoArticleStore(s, dbName),
+		Clients:          NewMongoClientStore(s, dbName),
+		AccessTokens:     NewMongoAccessTokenStore(s, dbName),
+		SecurityEvents:   NewMongoSecurityEventStore(s, dbName),
+		OneTimeTokens:    NewMongoOneTimeTokenStore(s, dbName),
+		ArticleRevisions: NewMongoArticleRevisionStore(s, dbName),
+		Comments:         NewMongoCommentStore(s, dbName),
+		Roles:            NewMongoRoleStore(s, dbName),
+	}
+}
+
+//stores that keep everything in memory, used in unit testing
+func NewMemoryStores() *Stores {
+	return &Stores{
+		Users:            NewMemoryUserStore(),
+		Articles:         NewMemoryArticleStore(),
+		Clients:          NewMemoryClientStore(),
+		AccessTokens:     NewMemoryAccessTokenStore(),
+		SecurityEvents:   NewMemorySecurityEventStore(),
+		OneTimeTokens:    NewMemoryOneTimeTokenStore(),
+		ArticleRevisions: NewMemoryArticleRevisionStore(),
+		Comments:         NewMemoryCommentStore(),
+		Roles:            NewMemoryRoleStore(),
+	}
+}
+
+//copy of slice so the memory stores don't share it with the callers, nil stays nil
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+//convert mgo errors to store errors
+func mongoError(err error) error {
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+//the condition of versions for the conditional updates, nil versions means any version
+//the items that saved before versioning don't have version and they are version 0
+func withVersions(query bson.M, versions []int) bson.M {
+	if versions == nil {
+		return query
+	}
+	in := []interface{}{}
+	for _, v := range versions {
+		in = append(in, v)
+		if v == 0 {
+			in = append(in, nil)
+		}
+	}
+	result := bson.M{"version": bson.M{"$in": in}}
+	for k, v := range query {
+		result[k] = v
+	}
+	return result
+}
+
+func matchVersion(version int, versions []int) bool {
+	if versions == nil {
+		return true
+	}
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+//the conditional update that doesn't find the item returns ErrVersionConflict if the item exists with other version
+func mongoVersionError(c *mgo.Collection, query bson.M, versions []int, err error) error {
+	if versions == nil {
+		return mongoError(err)
+	}
+	return mongoConditionError(c, query, ErrVersionConflict, err)
+}
+
+//the update by query and extra conditions that doesn't find the item returns conflict if the item of query exists
+func mongoConditionError(c *mgo.Collection, query bson.M, conflict error, err error) error {
+	if err != mgo.ErrNotFound {
+		return mongoError(err)
+	}
+	n, err := c.Find(query).Count()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return conflict
+	}
+	return ErrNotFound
+}
+
+//mark the documents of article with the deleted_at of article, nil removes the mark
+func setDeletedAtByArticleId(c *mgo.Collection, articleId bson.ObjectId, deletedAt *time.Time) error {
+	update := bson.M{"$unset": bson.M{"deleted_at": ""}}
+	if deletedAt != nil {
+		update = bson.M{"$set": bson.M{"deleted_at": deletedAt}}
+	}
+	_, err := c.UpdateAll(bson.M{"article_id": articleId}, update)
+	return err
+}
+
+//the documents that have deleted_at removed by mongodb after the retention
+//the retention of existing index can't be changed by EnsureIndex, so it's changed by collMod
+func EnsureTrashIndex(c *mgo.Collection, retention time.Duration) error {
+	err := c.EnsureIndex(mgo.Index{
+		Key:         []string{"deleted_at"},
+		Background:  true,
+		ExpireAfter: retention,
+	})
+	if err == nil {
+		return nil
+	}
+	return c.Database.Run(bson.D{
+		{Name: "collMod", Value: c.Name},
+		{Name: "index", Value: bson.M{"keyPattern": bson.M{"deleted_at": 1}, "expireAfterSeconds": int(retention / time.Second)}},
+	}, nil)
+}
@@ -0,0 +1,606 @@
+package store
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+const (
+	//the mongodb URL of tests such as mongodb://localhost, the mongo stores are skipped without it
+	TEST_MONGO_URL_ENV = "APP_TEST_MONGO_URL"
+)
+
+//run the same test against the memory and the mongo stores, the mongo stores use a new database that dropped after test
+//the mongo stores are skipped when the mongodb URL is not set or mongodb is not available
+func forEachStores(t *testing.T, test func(name string, s *Stores)) {
+	test("memory", NewMemoryStores())
+	url := os.Getenv(TEST_MONGO_URL_ENV)
+	if url == "" {
+		t.Logf("mongo stores skipped, %v is not set", TEST_MONGO_URL_ENV)
+		return
+	}
+	session, err := mgo.DialWithTimeout(url, 5*time.Second)
+	if err != nil {
+		t.Logf("mongo stores skipped, mongodb is not available: %v", err)
+		return
+	}
+	defer session.Close()
+	dbName := "test_" + bson.NewObjectId().Hex()
+	defer session.DB(dbName).DropDatabase()
+	if err := EnsureArticleExternalIdIndex(session.DB(dbName)); err != nil {
+		t.Fatalf("Error should %v \t but get %v", nil, err)
+	}
+	test("mongo", NewMongoStores(session, dbName))
+}
+
+//the times saved in milliseconds by mongodb
+func testTime(t time.Time) time.Time {
+	return t.Truncate(time.Millisecond)
+}
+
+//find the article even if it's in trash
+func findTestArticle(s *Stores, a *models.Article) (*models.Article, error) {
+	if a.DeletedAt == nil {
+		return s.Articles.FindById(a.Id)
+	}
+	var found *models.Article
+	err := s.Articles.ForEach(ArticleFilter{UserId: a.UserId, Trashed: true}, func(trashed *models.Article) error {
+		if trashed.Id == a.Id {
+			found = trashed
+		}
+		return nil
+	})
+	if err == nil && found == nil {
+		err = ErrNotFound
+	}
+	return found, err
+}
+
+func TestRefreshTokenQueries(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		clientId, otherClientId := bson.NewObjectId(), bson.NewObjectId()
+		u := models.User{
+			Id:    bson.NewObjectId(),
+			Email: "refresh@test.com",
+			TrustedApps: []models.TrustedApp{
+				{Id: bson.NewObjectId(), ClientId: clientId, RefreshToken: "first", RetiredRefreshTokens: []string{"first-retired"}},
+				{Id: bson.NewObjectId(), ClientId: otherClientId, RefreshToken: "second", RetiredRefreshTokens: []string{"second-retired"}},
+			},
+		}
+		if err := s.Users.Insert(&u); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		cases := []struct {
+			retired      bool
+			clientId     bson.ObjectId
+			refreshToken string
+			err          error
+		}{
+			{false, clientId, "first", nil},
+			{false, otherClientId, "second", nil},
+			//the token of the other trusted app of the same user
+			{false, clientId, "second", ErrNotFound},
+			{false, otherClientId, "first", ErrNotFound},
+			{false, clientId, "first-retired", ErrNotFound},
+			{true, clientId, "first-retired", nil},
+			{true, otherClientId, "first-retired", ErrNotFound},
+			{true, clientId, "first", ErrNotFound},
+		}
+		for _, cas := range cases {
+			var found *models.User
+			var err error
+			if cas.retired {
+				found, err = s.Users.FindByRetiredRefreshToken(cas.clientId, cas.refreshToken)
+			} else {
+				found, err = s.Users.FindByRefreshToken(cas.clientId, cas.refreshToken)
+			}
+			if err != cas.err {
+				t.Errorf("%v: Error should %v \t but get %v", name, cas.err, err)
+				continue
+			}
+			if err == nil && found.Id != u.Id {
+				t.Errorf("%v: Error should %v \t but get %v", name, u.Id, found.Id)
+			}
+		}
+	})
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		trustedAppId := bson.NewObjectId()
+		u := models.User{
+			Id:          bson.NewObjectId(),
+			Email:       "rotate@test.com",
+			IsEnable:    true,
+			TrustedApps: []models.TrustedApp{{Id: trustedAppId, ClientId: bson.NewObjectId(), RefreshToken: "first", RetiredRefreshTokens: []string{"zero"}}},
+		}
+		if err := s.Users.Insert(&u); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		//the admin disables the user meanwhile
+		if err := s.Users.SetEnabled(u.Id, false); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		usedAt := testTime(time.Now())
+		cases := []struct {
+			trustedAppId bson.ObjectId
+			oldToken     string
+			newToken     string
+			err          error
+			retired      string
+		}{
+			{trustedAppId, "first", "second", nil, "[zero first]"},
+			//the concurrent refresh by the same token loses
+			{trustedAppId, "first", "other", ErrNotFound, "[zero first]"},
+			{bson.NewObjectId(), "second", "other", ErrNotFound, "[zero first]"},
+			//only the newest retired refresh tokens kept
+			{trustedAppId, "second", "third", nil, "[first second]"},
+		}
+		for _, cas := range cases {
+			err := s.Users.RotateRefreshToken(u.Id, cas.trustedAppId, cas.oldToken, cas.newToken, 2, usedAt)
+			if err != cas.err {
+				t.Errorf("%v: Error should %v \t but get %v", name, cas.err, err)
+			}
+			found, err := s.Users.FindById(u.Id)
+			if err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+			if fmt.Sprint(found.TrustedApps[0].RetiredRefreshTokens) != cas.retired {
+				t.Errorf("%v: Error should %v \t but get %v", name, cas.retired, found.TrustedApps[0].RetiredRefreshTokens)
+			}
+			if found.IsEnable {
+				t.Errorf("%v: Error should %v \t but get %v", name, false, found.IsEnable)
+			}
+		}
+		found, err := s.Users.FindById(u.Id)
+		if err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		if found.TrustedApps[0].RefreshToken != "third" || !found.TrustedApps[0].LastUsedAt.Equal(usedAt) {
+			t.Errorf("%v: Error should %v \t but get %v", name, "third", found.TrustedApps[0].RefreshToken)
+		}
+	})
+}
+
+func TestSaveTrustedApp(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		u := models.User{Id: bson.NewObjectId(), Email: "save@test.com", IsEnable: true}
+		if err := s.Users.Insert(&u); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		//the admin disables the user meanwhile
+		if err := s.Users.SetEnabled(u.Id, false); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		first := models.TrustedApp{Id: bson.NewObjectId(), ClientId: bson.NewObjectId(), RefreshToken: "first"}
+		second := models.TrustedApp{Id: bson.NewObjectId(), ClientId: bson.NewObjectId(), RefreshToken: "second"}
+		changed := first
+		changed.RefreshToken = "changed"
+		cases := []struct {
+			id         bson.ObjectId
+			trustedApp models.TrustedApp
+			err        error
+			tokens     string
+		}{
+			{u.Id, first, nil, "[first]"},
+			{u.Id, second, nil, "[first second]"},
+			{u.Id, changed, nil, "[changed second]"},
+			{bson.NewObjectId(), first, ErrNotFound, "[changed second]"},
+		}
+		for _, cas := range cases {
+			if err := s.Users.SaveTrustedApp(cas.id, &cas.trustedApp); err != cas.err {
+				t.Errorf("%v: Error should %v \t but get %v", name, cas.err, err)
+			}
+			found, err := s.Users.FindById(u.Id)
+			if err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+			tokens := []string{}
+			for _, trustedApp := range found.TrustedApps {
+				tokens = append(tokens, trustedApp.RefreshToken)
+			}
+			if fmt.Sprint(tokens) != cas.tokens || found.IsEnable {
+				t.Errorf("%v: Error should %v \t but get %v", name, cas.tokens, tokens)
+			}
+		}
+	})
+}
+
+func TestSetPassword(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		u := models.User{
+			Id:                    bson.NewObjectId(),
+			Email:                 "password@test.com",
+			HashedPassword:        "old",
+			IsEnable:              true,
+			PasswordResetRequired: true,
+			TrustedApps:           []models.TrustedApp{{Id: bson.NewObjectId(), ClientId: bson.NewObjectId(), RefreshToken: "token"}},
+			Roles:                 []string{models.USER_ROLE},
+		}
+		if err := s.Users.Insert(&u); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		//the admin changes the user meanwhile
+		if err := s.Users.SetEnabled(u.Id, false); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		if err := s.Users.SetRoles(u.Id, []string{models.USER_ROLE, models.ADMIN_ROLE}); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		cases := []struct {
+			reset                 bool
+			password              string
+			passwordResetRequired bool
+			trustedApps           int
+		}{
+			{false, "changed", true, 1},
+			{true, "reset", false, 0},
+		}
+		for _, cas := range cases {
+			var err error
+			if cas.reset {
+				err = s.Users.ResetPassword(u.Id, cas.password)
+			} else {
+				err = s.Users.SetPassword(u.Id, cas.password)
+			}
+			if err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+			found, err := s.Users.FindById(u.Id)
+			if err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+			if found.HashedPassword != cas.password || found.PasswordResetRequired != cas.passwordResetRequired || len(found.TrustedApps) != cas.trustedApps {
+				t.Errorf("%v: Error should %v %v %v \t but get %v %v %v", name, cas.password, cas.passwordResetRequired, cas.trustedApps, found.HashedPassword, found.PasswordResetRequired, len(found.TrustedApps))
+			}
+			if found.IsEnable || fmt.Sprint(found.Roles) != fmt.Sprint([]string{models.USER_ROLE, models.ADMIN_ROLE}) {
+				t.Errorf("%v: Error should %v \t but get %v %v", name, "the changes of admin kept", found.IsEnable, found.Roles)
+			}
+		}
+		if err := s.Users.SetPassword(bson.NewObjectId(), "password"); err != ErrNotFound {
+			t.Errorf("%v: Error should %v \t but get %v", name, ErrNotFound, err)
+		}
+		if err := s.Users.ResetPassword(bson.NewObjectId(), "password"); err != ErrNotFound {
+			t.Errorf("%v: Error should %v \t but get %v", name, ErrNotFound, err)
+		}
+	})
+}
+
+func TestArticlePage(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		userId := bson.NewObjectId()
+		base := testTime(time.Now())
+		//the third and fourth articles have the same created_at, the id breaks the tie
+		offsets := []time.Duration{0, time.Minute, 2 * time.Minute, 2 * time.Minute, 3 * time.Minute}
+		ids := []bson.ObjectId{}
+		for i, offset := range offsets {
+			a := models.Article{
+				Id:        bson.NewObjectId(),
+				Title:     fmt.Sprintf("article %v", i),
+				Content:   "content",
+				UserId:    userId,
+				Tags:      []string{"all"},
+				CreatedAt: base.Add(offset),
+				UpdatedAt: base.Add(offset),
+			}
+			if i%2 == 0 {
+				a.Tags = append(a.Tags, "even")
+			}
+			if err := s.Articles.Insert(&a); err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+			ids = append(ids, a.Id)
+		}
+		deletedAt := base
+		others := []models.Article{
+			{Id: bson.NewObjectId(), Title: "other user", Content: "content", UserId: bson.NewObjectId(), Tags: []string{"all"}, CreatedAt: base},
+			{Id: bson.NewObjectId(), Title: "trashed", Content: "content", UserId: userId, Tags: []string{"all"}, CreatedAt: base, DeletedAt: &deletedAt},
+		}
+		for i := range others {
+			if err := s.Articles.Insert(&others[i]); err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+		}
+		cases := []struct {
+			filter     ArticleFilter
+			descending bool
+			expected   []bson.ObjectId
+		}{
+			{ArticleFilter{UserId: userId}, false, ids},
+			{ArticleFilter{UserId: userId}, true, []bson.ObjectId{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+			{ArticleFilter{UserId: userId, Tag: "even"}, false, []bson.ObjectId{ids[0], ids[2], ids[4]}},
+			{ArticleFilter{UserId: userId, CreatedFrom: base.Add(time.Minute), CreatedTo: base.Add(3 * time.Minute)}, true, []bson.ObjectId{ids[3], ids[2], ids[1]}},
+			{ArticleFilter{UserId: userId, Trashed: true}, false, []bson.ObjectId{others[1].Id}},
+		}
+		for _, cas := range cases {
+			//walk through the pages by the encoded cursors as clients do
+			found := []bson.ObjectId{}
+			p := PageRequest{Limit: 2, SortField: "created_at", Descending: cas.descending, WithTotal: true}
+			for {
+				page, err := s.Articles.FindPage(cas.filter, p)
+				if err != nil {
+					t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+				}
+				if page.Total == nil || *page.Total != len(cas.expected) {
+					t.Errorf("%v: Error should %v \t but get %v", name, len(cas.expected), page.Total)
+				}
+				for _, a := range page.Items.([]models.Article) {
+					found = append(found, a.Id)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				if p.Cursor, err = DecodeCursor(page.NextCursor, p.SortField); err != nil {
+					t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+				}
+			}
+			if fmt.Sprint(found) != fmt.Sprint(cas.expected) {
+				t.Errorf("%v: Error should %v \t but get %v", name, cas.expected, found)
+			}
+		}
+	})
+}
+
+func TestDecodeCursor(t *testing.T) {
+	id := bson.NewObjectId()
+	//the cursors that are not made by newCursor
+	forge := func(value interface{}) string {
+		data, _ := bson.Marshal(bson.M{"f": "created_at", "v": value, "id": id})
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	cases := []struct {
+		value     string
+		sortField string
+		err       error
+	}{
+		{(&Cursor{"created_at", time.Now(), id}).Encode(), "created_at", nil},
+		{(&Cursor{"title", "title", id}).Encode(), "title", nil},
+		{(&Cursor{"title", "title", id}).Encode(), "created_at", ErrNotValidCursor},
+		{forge(bson.M{"$ne": ""}), "created_at", ErrNotValidCursor},
+		{forge(bson.M{"$gt": time.Time{}}), "created_at", ErrNotValidCursor},
+		{forge([]string{"title"}), "created_at", ErrNotValidCursor},
+		{forge(10), "created_at", ErrNotValidCursor},
+		{forge(nil), "created_at", ErrNotValidCursor},
+		{"not a cursor", "created_at", ErrNotValidCursor},
+	}
+	for _, cas := range cases {
+		if _, err := DecodeCursor(cas.value, cas.sortField); err != cas.err {
+			t.Errorf("Error should %v \t but get %v", cas.err, err)
+		}
+	}
+}
+
+func TestArticleExternalId(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		userId := bson.NewObjectId()
+		deletedAt := time.Now()
+		trashed := models.Article{Id: bson.NewObjectId(), ExternalId: "post", Title: "trashed", Content: "content", UserId: userId, DeletedAt: &deletedAt}
+		if err := s.Articles.Insert(&trashed); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		cases := []struct {
+			article models.Article
+			err     error
+		}{
+			//the article in trash keeps its external id
+			{models.Article{Id: bson.NewObjectId(), ExternalId: "post", UserId: userId}, ErrDuplicate},
+			{models.Article{Id: bson.NewObjectId(), ExternalId: "post", UserId: bson.NewObjectId()}, nil},
+			{models.Article{Id: bson.NewObjectId(), ExternalId: "other", UserId: userId}, nil},
+			//the articles without external id are not unique
+			{models.Article{Id: bson.NewObjectId(), UserId: userId}, nil},
+			{models.Article{Id: bson.NewObjectId(), UserId: userId}, nil},
+		}
+		for _, cas := range cases {
+			cas.article.Title = "title"
+			cas.article.Content = "content"
+			if err := s.Articles.Insert(&cas.article); err != cas.err {
+				t.Errorf("%v: Error should %v \t but get %v", name, cas.err, err)
+			}
+		}
+		found, err := s.Articles.FindByExternalId(userId, "post")
+		if err != nil || found.Id != trashed.Id || found.DeletedAt == nil {
+			t.Errorf("%v: Error should %v in trash \t but get %+v, %v", name, trashed.Id, found, err)
+		}
+	})
+}
+
+func TestArticleIsNotShared(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		userId := bson.NewObjectId()
+		publishedAt := testTime(time.Now())
+		insertedPublishedAt := publishedAt
+		article := models.Article{
+			Id:            bson.NewObjectId(),
+			Title:         "shared slices",
+			Content:       "content",
+			UserId:        userId,
+			Tags:          []string{"first", "second"},
+			Collaborators: []models.Collaborator{{UserId: bson.NewObjectId(), Role: models.VIEWER_COLLABORATOR_ROLE}},
+			PublishedAt:   &publishedAt,
+		}
+		if err := s.Articles.Insert(&article); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		//change the inserted article and the found ones, the stored article should not change
+		change := func(a *models.Article) {
+			a.Tags[0] = "changed"
+			a.Collaborators[0].Role = models.EDITOR_COLLABORATOR_ROLE
+			*a.PublishedAt = publishedAt.Add(time.Hour)
+		}
+		change(&article)
+		found, err := s.Articles.FindById(article.Id)
+		if err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		change(found)
+		if found, err = s.Articles.FindByExternalId(userId, article.Id.Hex()); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		change(found)
+		s.Articles.ForEach(ArticleFilter{UserId: userId}, func(a *models.Article) error {
+			change(a)
+			return nil
+		})
+		page, err := s.Articles.FindPage(ArticleFilter{UserId: userId}, PageRequest{Limit: 1, SortField: "created_at"})
+		if err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		change(&page.Items.([]models.Article)[0])
+		if found, err = s.Articles.FindById(article.Id); err != nil {
+			t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+		}
+		if fmt.Sprint(found.Tags) != "[first second]" || found.Collaborators[0].Role != models.VIEWER_COLLABORATOR_ROLE || !found.PublishedAt.Equal(insertedPublishedAt) {
+			t.Errorf("%v: Error should %v \t but get %+v", name, "the inserted article", found)
+		}
+	})
+}
+
+func TestRenameTags(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		userId := bson.NewObjectId()
+		deletedAt := time.Now()
+		articles := []models.Article{
+			{Id: bson.NewObjectId(), Title: "both", Content: "content", UserId: userId, Tags: []string{"go", "golang"}},
+			{Id: bson.NewObjectId(), Title: "one", Content: "content", UserId: userId, Tags: []string{"web", "golang"}},
+			{Id: bson.NewObjectId(), Title: "already renamed", Content: "content", UserId: userId, Tags: []string{"go-lang", "go"}},
+			{Id: bson.NewObjectId(), Title: "not tagged", Content: "content", UserId: userId, Tags: []string{"web"}},
+			{Id: bson.NewObjectId(), Title: "trashed", Content: "content", UserId: userId, Tags: []string{"go"}, DeletedAt: &deletedAt},
+			{Id: bson.NewObjectId(), Title: "other user", Content: "content", UserId: bson.NewObjectId(), Tags: []string{"go"}},
+		}
+		for i := range articles {
+			if err := s.Articles.Insert(&articles[i]); err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+		}
+		updated, err := s.Articles.RenameTags(userId, []string{"go", "golang", "go-lang"}, "go-lang")
+		if err != nil || updated != 3 {
+			t.Errorf("%v: Error should %v \t but get %v, %v", name, 3, updated, err)
+		}
+		expected := []struct {
+			tags    string
+			version int
+		}{
+			{"[go-lang]", 1},
+			{"[web go-lang]", 1},
+			{"[go-lang]", 1},
+			{"[web]", 0},
+			{"[go]", 0},
+			{"[go]", 0},
+		}
+		for i, exp := range expected {
+			a, err := findTestArticle(s, &articles[i])
+			if err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+			if fmt.Sprint(a.Tags) != exp.tags || a.Version != exp.version {
+				t.Errorf("%v: Error should %v, %v \t but get %v, %v", name, exp.tags, exp.version, a.Tags, a.Version)
+			}
+		}
+	})
+}
+
+func TestPublishScheduled(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		now := testTime(time.Now())
+		past, future := now.Add(-time.Hour), now.Add(time.Hour)
+		deletedAt := now
+		articles := []models.Article{
+			{Id: bson.NewObjectId(), Title: "due", Status: models.SCHEDULED_STATUS, ScheduledFor: &past},
+			{Id: bson.NewObjectId(), Title: "due now", Status: models.SCHEDULED_STATUS, ScheduledFor: &now},
+			{Id: bson.NewObjectId(), Title: "not due", Status: models.SCHEDULED_STATUS, ScheduledFor: &future},
+			{Id: bson.NewObjectId(), Title: "trashed", Status: models.SCHEDULED_STATUS, ScheduledFor: &past, DeletedAt: &deletedAt},
+			{Id: bson.NewObjectId(), Title: "draft", Status: models.DRAFT_STATUS},
+		}
+		for i := range articles {
+			articles[i].UserId = bson.NewObjectId()
+			articles[i].Content = "content"
+			if err := s.Articles.Insert(&articles[i]); err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+		}
+		published, err := s.Articles.PublishScheduled(now)
+		if err != nil || len(published) != 2 {
+			t.Fatalf("%v: Error should %v \t but get %v, %v", name, 2, len(published), err)
+		}
+		//the second call doesn't publish them again
+		if again, err := s.Articles.PublishScheduled(now); err != nil || len(again) != 0 {
+			t.Errorf("%v: Error should %v \t but get %v, %v", name, 0, len(again), err)
+		}
+		expected := []struct {
+			status      string
+			publishedAt *time.Time
+		}{
+			{models.PUBLISHED_STATUS, &past},
+			{models.PUBLISHED_STATUS, &now},
+			{models.SCHEDULED_STATUS, nil},
+			{models.SCHEDULED_STATUS, nil},
+			{models.DRAFT_STATUS, nil},
+		}
+		for i, exp := range expected {
+			a, err := findTestArticle(s, &articles[i])
+			if err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+			if a.Status != exp.status {
+				t.Errorf("%v: Error should %v \t but get %v", name, exp.status, a.Status)
+			}
+			if exp.publishedAt == nil {
+				continue
+			}
+			if a.PublishedAt == nil || !a.PublishedAt.Equal(*exp.publishedAt) || a.ScheduledFor != nil {
+				t.Errorf("%v: Error should %v \t but get %v", name, exp.publishedAt, a.PublishedAt)
+			}
+		}
+	})
+}
+
+func TestRemoveComment(t *testing.T) {
+	forEachStores(t, func(name string, s *Stores) {
+		articleId := bson.NewObjectId()
+		parentId := bson.NewObjectId()
+		comments := []models.Comment{
+			{Id: parentId},
+			{Id: bson.NewObjectId(), ParentId: parentId},
+			{Id: bson.NewObjectId(), ParentId: parentId, IsHidden: true},
+			{Id: bson.NewObjectId()},
+		}
+		for i := range comments {
+			comments[i].ArticleId = articleId
+			comments[i].UserId = bson.NewObjectId()
+			comments[i].Content = "comment"
+			if err := s.Comments.Insert(&comments[i]); err != nil {
+				t.Fatalf("%v: Error should %v \t but get %v", name, nil, err)
+			}
+		}
+		cases := []struct {
+			id      bson.ObjectId
+			visible int
+			err     error
+		}{
+			//the parent with its replies, the hidden reply is not counted
+			{parentId, 2, nil},
+			{parentId, 0, ErrNotFound},
+			{comments[3].Id, 1, nil},
+			{bson.NewObjectId(), 0, ErrNotFound},
+		}
+		for _, cas := range cases {
+			visible, err := s.Comments.Remove(cas.id)
+			if err != cas.err || visible != cas.visible {
+				t.Errorf("%v: Error should %v, %v \t but get %v, %v", name, cas.visible, cas.err, visible, err)
+			}
+		}
+		for _, comment := range comments {
+			if _, err := s.Comments.FindById(comment.Id); err != ErrNotFound {
+				t.Errorf("%v: Error should %v \t but get %v", name, ErrNotFound, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,683 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+//storage of users with their trusted apps
+type UserStore interface {
+	Insert(u *models.User) error
+	FindById(id bson.ObjectId) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	FindByRefreshToken(clientId bson.ObjectId, refreshToken string) (*models.User, error)
+	//find the user that this refresh token rotated before in one of its trusted apps
+	FindByRetiredRefreshToken(clientId bson.ObjectId, refreshToken string) (*models.User, error)
+	//check is any other user have this email address, exceptId can be empty
+	IsEmailTaken(email string, exceptId bson.ObjectId) (bool, error)
+	//replace the whole user document
+	Update(u *models.User) error
+	//update only first, last and display name, the email changed after verification
+	UpdateProfile(id bson.ObjectId, profile *models.User) error
+	//keep the new email until it's verified
+	SetPendingEmail(id bson.ObjectId, email string) error
+	//change the key of profile image in blob storage
+	SetImageFileName(id bson.ObjectId, fileName string) error
+	//mark the current email verified, ErrNotFound when the email of user is changed
+	SetEmailVerified(id bson.ObjectId, email string) error
+	//replace the email by the pending email, ErrNotFound when the pending email is changed
+	ConfirmPendingEmail(id bson.ObjectId, email string) error
+	//replace the trusted app of user that has the same id or add it, the other fields of user don't change
+	SaveTrustedApp(id bson.ObjectId, trustedApp *models.TrustedApp) error
+	//replace the refresh token of trusted app only if it's still the old one, the old one kept as the newest retired refresh token
+	//ErrNotFound when the refresh token is rotated meanwhile, so only one of the concurrent refreshes wins
+	RotateRefreshToken(id, trustedAppId bson.ObjectId, oldToken, newToken string, retiredLimit int, usedAt time.Time) error
+	//remove one trusted app of user, so its refresh token is not valid anymore
+	RemoveTrustedApp(id, trustedAppId bson.ObjectId) error
+	//remove all of trusted apps of user
+	RemoveAllTrustedApps(id bson.ObjectId) error
+	//page of the users that match the filter, the items are []models.User
+	FindPage(filter UserFilter, page PageRequest) (*models.Page, error)
+	SetEnabled(id bson.ObjectId, enabled bool) error
+	SetRoles(id bson.ObjectId, roles []string) error
+	//the user can't sign in until reset the password, the trusted apps removed
+	RequirePasswordReset(id bson.ObjectId) error
+	//change only the hashed password, the other fields of user don't change
+	SetPassword(id bson.ObjectId, hashedPassword string) error
+	//change the hashed password, the password is not required to reset anymore and the trusted apps removed
+	ResetPassword(id bson.ObjectId, hashedPassword string) error
+	Remove(id bson.ObjectId) error
+}
+
+//the zero values are not used in filter
+type UserFilter struct {
+	//case insensitive part of email, display name, first name or last name
+	Query    string
+	Role     string
+	//nil for both enabled and disabled users
+	IsEnable *bool
+}
+
+func (f UserFilter) mongoQuery() bson.M {
+	query := bson.M{}
+	if f.Query != "" {
+		pattern := bson.RegEx{Pattern: regexp.QuoteMeta(f.Query), Options: "i"}
+		query["$or"] = []bson.M{{"email": pattern}, {"display_name": pattern}, {"first_name": pattern}, {"last_name": pattern}}
+	}
+	if f.Role != "" {
+		query["roles"] = f.Role
+	}
+	if f.IsEnable != nil {
+		query["enable_status"] = *f.IsEnable
+	}
+	return query
+}
+
+func (f UserFilter) match(u *models.User) bool {
+	if f.Query != "" {
+		query := strings.ToLower(f.Query)
+		found := false
+		for _, field := range []string{u.Email, u.DisplayName, u.FirstName, u.LastName} {
+			if strings.Contains(strings.ToLower(field), query) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Role != "" && !hasTag(u.Roles, f.Role) {
+		return false
+	}
+	return f.IsEnable == nil || u.IsEnable == *f.IsEnable
+}
+
+//sort key of user by the sort field of page request
+func userPageKey(u *models.User, sortField string) pageKey {
+	switch sortField {
+	case "email":
+		return pageKey{u.Email, u.Id}
+	case "display_name":
+		return pageKey{u.DisplayName, u.Id}
+	}
+	return pageKey{u.JoinedAt, u.Id}
+}
+
+//keep limit items and set the cursor of the next page
+func newUserPage(users []models.User, p PageRequest, total *int) *models.Page {
+	page := &models.Page{Items: users, Total: total}
+	if len(users) > p.Limit {
+		page.Items = users[:p.Limit]
+		page.NextCursor = newCursor(userPageKey(&users[p.Limit-1], p.SortField), p).Encode()
+	}
+	return page
+}
+
+type mongoUserStore struct {
+	session *mgo.Session
+	dbName  string
+}
+
+func NewMongoUserStore(s *mgo.Session, dbName string) UserStore {
+	return &mongoUserStore{s, dbName}
+}
+
+func (s *mongoUserStore) Insert(u *models.User) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return session.DB(s.dbName).C(USER_COLLECTION_NAME).Insert(u)
+}
+
+func (s *mongoUserStore) FindById(id bson.ObjectId) (*models.User, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	u := models.User{}
+	if err := session.DB(s.dbName).C(USER_COLLECTION_NAME).FindId(id).One(&u); err != nil {
+		return nil, mongoError(err)
+	}
+	return &u, nil
+}
+
+func (s *mongoUserStore) FindByEmail(email string) (*models.User, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	u := models.User{}
+	if err := session.DB(s.dbName).C(USER_COLLECTION_NAME).Find(bson.M{"email": email}).One(&u); err != nil {
+		return nil, mongoError(err)
+	}
+	return &u, nil
+}
+
+func (s *mongoUserStore) FindByRefreshToken(clientId bson.ObjectId, refreshToken string) (*models.User, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	u := models.User{}
+	//the client and the token should be in the same trusted app
+	query := bson.M{"trusted_apps": bson.M{"$elemMatch": bson.M{"_client": clientId, "refresh_token": refreshToken}}}
+	if err := session.DB(s.dbName).C(USER_COLLECTION_NAME).Find(query).One(&u); err != nil {
+		return nil, mongoError(err)
+	}
+	return &u, nil
+}
+
+func (s *mongoUserStore) FindByRetiredRefreshToken(clientId bson.ObjectId, refreshToken string) (*models.User, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	u := models.User{}
+	query := bson.M{"trusted_apps": bson.M{"$elemMatch": bson.M{"_client": clientId, "retired_refresh_tokens": refreshToken}}}
+	if err := session.DB(s.dbName).C(USER_COLLECTION_NAME).Find(query).One(&u); err != nil {
+		return nil, mongoError(err)
+	}
+	return &u, nil
+}
+
+func (s *mongoUserStore) IsEmailTaken(email string, exceptId bson.ObjectId) (bool, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	query := bson.M{"email": email}
+	if exceptId != "" {
+		query["_id"] = bson.M{"$ne": exceptId}
+	}
+	count, err := session.DB(s.dbName).C(USER_COLLECTION_NAME).Find(query).Count()
+	if err != nil {
+		return false, err
+	}
+	return count != 0, nil
+}
+
+func (s *mongoUserStore) Update(u *models.User) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(u.Id, u))
+}
+
+func (s *mongoUserStore) UpdateProfile(id bson.ObjectId, profile *models.User) error {
+	session := s.session.Copy()
+	defer session.Close()
+	userUpdateSet := bson.M{
+		"first_name":   profile.FirstName,
+		"last_name":    profile.LastName,
+		"display_name": profile.DisplayName,
+		"updated_at":   time.Now(),
+	}
+	//update the user profile in one query
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$set": userUpdateSet}))
+}
+
+func (s *mongoUserStore) SetPendingEmail(id bson.ObjectId, email string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$set": bson.M{"pending_email": email, "updated_at": time.Now()}}))
+}
+
+func (s *mongoUserStore) SetImageFileName(id bson.ObjectId, fileName string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$set": bson.M{"image_profile_file_name": fileName, "updated_at": time.Now()}}))
+}
+
+func (s *mongoUserStore) SetEmailVerified(id bson.ObjectId, email string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).Update(bson.M{"_id": id, "email": email}, bson.M{"$set": bson.M{"email_verified": true, "updated_at": time.Now()}}))
+}
+
+func (s *mongoUserStore) ConfirmPendingEmail(id bson.ObjectId, email string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	update := bson.M{
+		"$set":   bson.M{"email": email, "email_verified": true, "updated_at": time.Now()},
+		"$unset": bson.M{"pending_email": ""},
+	}
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).Update(bson.M{"_id": id, "pending_email": email}, update))
+}
+
+func (s *mongoUserStore) SaveTrustedApp(id bson.ObjectId, trustedApp *models.TrustedApp) error {
+	session := s.session.Copy()
+	defer session.Close()
+	c := session.DB(s.dbName).C(USER_COLLECTION_NAME)
+	err := c.Update(bson.M{"_id": id, "trusted_apps._id": trustedApp.Id}, bson.M{"$set": bson.M{"trusted_apps.$": trustedApp}})
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	//the trusted app is new
+	return mongoError(c.Update(bson.M{"_id": id, "trusted_apps._id": bson.M{"$ne": trustedApp.Id}}, bson.M{"$push": bson.M{"trusted_apps": trustedApp}}))
+}
+
+func (s *mongoUserStore) RotateRefreshToken(id, trustedAppId bson.ObjectId, oldToken, newToken string, retiredLimit int, usedAt time.Time) error {
+	session := s.session.Copy()
+	defer session.Close()
+	//the positional operator updates the trusted app that matched by $elemMatch
+	query := bson.M{"_id": id, "trusted_apps": bson.M{"$elemMatch": bson.M{"_id": trustedAppId, "refresh_token": oldToken}}}
+	retired := bson.M{"$each": []string{oldToken}}
+	if retiredLimit > 0 {
+		//only keep the newest ones
+		retired["$slice"] = -retiredLimit
+	}
+	update := bson.M{
+		"$set":  bson.M{"trusted_apps.$.refresh_token": newToken, "trusted_apps.$.last_used_at": usedAt},
+		"$push": bson.M{"trusted_apps.$.retired_refresh_tokens": retired},
+	}
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).Update(query, update))
+}
+
+func (s *mongoUserStore) RemoveTrustedApp(id, trustedAppId bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$pull": bson.M{"trusted_apps": bson.M{"_id": trustedAppId}}}))
+}
+
+func (s *mongoUserStore) RemoveAllTrustedApps(id bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$unset": bson.M{"trusted_apps": ""}}))
+}
+
+func (s *mongoUserStore) FindPage(filter UserFilter, page PageRequest) (*models.Page, error) {
+	session := s.session.Copy()
+	defer session.Close()
+	result := []models.User{}
+	total, err := findMongoPage(session.DB(s.dbName).C(USER_COLLECTION_NAME), filter.mongoQuery(), page, &result)
+	if err != nil {
+		return nil, err
+	}
+	return newUserPage(result, page, total), nil
+}
+
+func (s *mongoUserStore) SetEnabled(id bson.ObjectId, enabled bool) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$set": bson.M{"enable_status": enabled, "updated_at": time.Now()}}))
+}
+
+func (s *mongoUserStore) SetRoles(id bson.ObjectId, roles []string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$set": bson.M{"roles": roles, "updated_at": time.Now()}}))
+}
+
+func (s *mongoUserStore) RequirePasswordReset(id bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	update := bson.M{
+		"$set":   bson.M{"password_reset_required": true, "updated_at": time.Now()},
+		"$unset": bson.M{"trusted_apps": ""},
+	}
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, update))
+}
+
+func (s *mongoUserStore) SetPassword(id bson.ObjectId, hashedPassword string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, bson.M{"$set": bson.M{"hashed_password": hashedPassword, "updated_at": time.Now()}}))
+}
+
+func (s *mongoUserStore) ResetPassword(id bson.ObjectId, hashedPassword string) error {
+	session := s.session.Copy()
+	defer session.Close()
+	update := bson.M{
+		"$set":   bson.M{"hashed_password": hashedPassword, "updated_at": time.Now()},
+		"$unset": bson.M{"password_reset_required": "", "trusted_apps": ""},
+	}
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).UpdateId(id, update))
+}
+
+func (s *mongoUserStore) Remove(id bson.ObjectId) error {
+	session := s.session.Copy()
+	defer session.Close()
+	return mongoError(session.DB(s.dbName).C(USER_COLLECTION_NAME).RemoveId(id))
+}
+
+type memoryUserStore struct {
+	mutex sync.RWMutex
+	users []models.User
+}
+
+func NewMemoryUserStore() UserStore {
+	return &memoryUserStore{}
+}
+
+//copy the slices of user so the callers can't change the stored user
+func copyUser(u models.User) *models.User {
+	u.TrustedApps = append([]models.TrustedApp(nil), u.TrustedApps...)
+	for i := range u.TrustedApps {
+		u.TrustedApps[i].RetiredRefreshTokens = append([]string(nil), u.TrustedApps[i].RetiredRefreshTokens...)
+		u.TrustedApps[i].Scopes = copyStrings(u.TrustedApps[i].Scopes)
+	}
+	u.Roles = append([]string(nil), u.Roles...)
+	return &u
+}
+
+func (s *memoryUserStore) Insert(u *models.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.users = append(s.users, *copyUser(*u))
+	return nil
+}
+
+func (s *memoryUserStore) FindById(id bson.ObjectId) (*models.User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, u := range s.users {
+		if u.Id == id {
+			return copyUser(u), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUserStore) FindByEmail(email string) (*models.User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return copyUser(u), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUserStore) FindByRefreshToken(clientId bson.ObjectId, refreshToken string) (*models.User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, u := range s.users {
+		for _, trustedApp := range u.TrustedApps {
+			if trustedApp.ClientId == clientId && trustedApp.RefreshToken == refreshToken {
+				return copyUser(u), nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUserStore) FindByRetiredRefreshToken(clientId bson.ObjectId, refreshToken string) (*models.User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, u := range s.users {
+		for _, trustedApp := range u.TrustedApps {
+			if trustedApp.ClientId != clientId {
+				continue
+			}
+			for _, retired := range trustedApp.RetiredRefreshTokens {
+				if retired == refreshToken {
+					return copyUser(u), nil
+				}
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUserStore) IsEmailTaken(email string, exceptId bson.ObjectId) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, u := range s.users {
+		if u.Email == email && u.Id != exceptId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryUserStore) Update(u *models.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == u.Id {
+			s.users[i] = *copyUser(*u)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) UpdateProfile(id bson.ObjectId, profile *models.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].FirstName = profile.FirstName
+			s.users[i].LastName = profile.LastName
+			s.users[i].DisplayName = profile.DisplayName
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) SetPendingEmail(id bson.ObjectId, email string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].PendingEmail = email
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) SetImageFileName(id bson.ObjectId, fileName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].ImageFileName = fileName
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) SetEmailVerified(id bson.ObjectId, email string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id && s.users[i].Email == email {
+			s.users[i].EmailVerified = true
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) ConfirmPendingEmail(id bson.ObjectId, email string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id && s.users[i].PendingEmail == email {
+			s.users[i].Email = email
+			s.users[i].EmailVerified = true
+			s.users[i].PendingEmail = ""
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) SaveTrustedApp(id bson.ObjectId, trustedApp *models.TrustedApp) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	saved := *trustedApp
+	saved.RetiredRefreshTokens = append([]string(nil), trustedApp.RetiredRefreshTokens...)
+	saved.Scopes = copyStrings(trustedApp.Scopes)
+	for i := range s.users {
+		if s.users[i].Id != id {
+			continue
+		}
+		for j := range s.users[i].TrustedApps {
+			if s.users[i].TrustedApps[j].Id == trustedApp.Id {
+				s.users[i].TrustedApps[j] = saved
+				return nil
+			}
+		}
+		s.users[i].TrustedApps = append(s.users[i].TrustedApps, saved)
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) RotateRefreshToken(id, trustedAppId bson.ObjectId, oldToken, newToken string, retiredLimit int, usedAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id != id {
+			continue
+		}
+		for j := range s.users[i].TrustedApps {
+			trustedApp := &s.users[i].TrustedApps[j]
+			if trustedApp.Id != trustedAppId || trustedApp.RefreshToken != oldToken {
+				continue
+			}
+			trustedApp.RetiredRefreshTokens = append(trustedApp.RetiredRefreshTokens, oldToken)
+			if retiredLimit > 0 && len(trustedApp.RetiredRefreshTokens) > retiredLimit {
+				trustedApp.RetiredRefreshTokens = trustedApp.RetiredRefreshTokens[len(trustedApp.RetiredRefreshTokens)-retiredLimit:]
+			}
+			trustedApp.RefreshToken = newToken
+			trustedApp.LastUsedAt = usedAt
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) RemoveTrustedApp(id, trustedAppId bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			trustedApps := []models.TrustedApp{}
+			for _, trustedApp := range s.users[i].TrustedApps {
+				if trustedApp.Id != trustedAppId {
+					trustedApps = append(trustedApps, trustedApp)
+				}
+			}
+			s.users[i].TrustedApps = trustedApps
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) RemoveAllTrustedApps(id bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].TrustedApps = nil
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) FindPage(filter UserFilter, page PageRequest) (*models.Page, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	matched := []models.User{}
+	keys := []pageKey{}
+	for i := range s.users {
+		if filter.match(&s.users[i]) {
+			matched = append(matched, *copyUser(s.users[i]))
+			keys = append(keys, userPageKey(&s.users[i], page.SortField))
+		}
+	}
+	indexes, total := memoryPage(keys, page)
+	result := []models.User{}
+	for _, i := range indexes {
+		result = append(result, matched[i])
+	}
+	return newUserPage(result, page, total), nil
+}
+
+func (s *memoryUserStore) SetEnabled(id bson.ObjectId, enabled bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].IsEnable = enabled
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) SetRoles(id bson.ObjectId, roles []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].Roles = append([]string(nil), roles...)
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) RequirePasswordReset(id bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].PasswordResetRequired = true
+			s.users[i].TrustedApps = nil
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) SetPassword(id bson.ObjectId, hashedPassword string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].HashedPassword = hashedPassword
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) ResetPassword(id bson.ObjectId, hashedPassword string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].HashedPassword = hashedPassword
+			s.users[i].PasswordResetRequired = false
+			s.users[i].TrustedApps = nil
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) Remove(id bson.ObjectId) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
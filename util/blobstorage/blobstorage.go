@@ -0,0 +1,63 @@
+package blobstorage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+)
+
+var (
+	ErrNotFound    = errors.New("blobstorage: not found")
+	ErrNotValidKey = errors.New("blobstorage: key is not valid")
+)
+
+//information of stored object
+type Object struct {
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+	ETag        string
+}
+
+//keep the uploaded files, the keys are slash separated paths such as profile/id/name.jpeg
+type Storage interface {
+	//save the content, the object with same key replaced
+	Put(key string, content []byte, contentType string) error
+	//the caller should close the reader, ErrNotFound when there isn't any object with this key
+	Get(key string) (io.ReadCloser, *Object, error)
+	//remove the object, it's not an error when the object doesn't exist
+	Delete(key string) error
+}
+
+//storage of the media config driver
+func New(cfg config.MediaConfig) (Storage, error) {
+	switch cfg.Driver {
+	case config.STORAGE_LOCAL_DRIVER:
+		return NewLocalStorage(cfg.Dir)
+	case config.STORAGE_S3_DRIVER:
+		return NewS3Storage(cfg.S3), nil
+	}
+	return nil, fmt.Errorf("blobstorage: unknown driver %s", cfg.Driver)
+}
+
+//the key should be a clean relative path, so it can't get out of the directory or bucket
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return ErrNotValidKey
+	}
+	return nil
+}
+
+//content type by the extension of the key
+func contentTypeByKey(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,67 @@
+package blobstorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	content []byte
+	info    Object
+}
+
+//keep the objects in memory, it's used in testing
+type MemoryStorage struct {
+	mutex   sync.Mutex
+	objects map[string]memoryObject
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: map[string]memoryObject{}}
+}
+
+func (s *MemoryStorage) Put(key string, content []byte, contentType string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cp := make([]byte, len(content))
+	copy(cp, content)
+	s.objects[key] = memoryObject{cp, Object{
+		ContentType: contentType,
+		Size:        int64(len(cp)),
+		ModTime:     time.Now(),
+		ETag:        fmt.Sprintf(`"%x"`, sha256.Sum256(cp)),
+	}}
+	return nil
+}
+
+func (s *MemoryStorage) Get(key string) (io.ReadCloser, *Object, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	info := obj.info
+	return ioutil.NopCloser(bytes.NewReader(obj.content)), &info, nil
+}
+
+func (s *MemoryStorage) Delete(key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.objects, key)
+	return nil
+}
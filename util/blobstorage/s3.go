@@ -0,0 +1,223 @@
+package blobstorage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+)
+
+const (
+	SIGNATURE_ALGORITHM = "AWS4-HMAC-SHA256"
+	AMZ_DATE_FORMAT     = "20060102T150405Z"
+	SCOPE_DATE_FORMAT   = "20060102"
+	S3_SERVICE          = "s3"
+)
+
+//any S3 compatible storage such as AWS S3 or minio, requests signed by signature version 4
+type S3Storage struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func NewS3Storage(cfg config.S3Config) *S3Storage {
+	return &S3Storage{
+		endpoint:  strings.TrimRight(cfg.Endpoint, "/"),
+		region:    cfg.Region,
+		bucket:    cfg.Bucket,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *S3Storage) Put(key string, content []byte, contentType string) error {
+	req, err := s.newRequest("PUT", key, content)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := s.do(req, content)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError(res)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(key string) (io.ReadCloser, *Object, error) {
+	req, err := s.newRequest("GET", key, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := s.do(req, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, nil, responseError(res)
+	}
+	obj := &Object{
+		ContentType: res.Header.Get("Content-Type"),
+		ETag:        res.Header.Get("ETag"),
+	}
+	obj.Size, _ = strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	obj.ModTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	if obj.ContentType == "" {
+		obj.ContentType = contentTypeByKey(key)
+	}
+	return res.Body, obj, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	req, err := s.newRequest("DELETE", key, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(req, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return responseError(res)
+	}
+	return nil
+}
+
+//path style request to endpoint/bucket/key
+func (s *S3Storage) newRequest(method, key string, content []byte) (*http.Request, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = u.Path + "/" + s.bucket + "/" + key
+	u.RawPath = escapePath(u.Path)
+	var body io.Reader
+	if content != nil {
+		body = bytes.NewReader(content)
+	}
+	return http.NewRequest(method, u.String(), body)
+}
+
+func (s *S3Storage) do(req *http.Request, content []byte) (*http.Response, error) {
+	sum := sha256.Sum256(content)
+	Sign(req, hex.EncodeToString(sum[:]), s.accessKey, s.secretKey, s.region, time.Now())
+	return s.client.Do(req)
+}
+
+//add the signature version 4 headers to request, the host and all of the request headers are signed
+func Sign(req *http.Request, payloadHash, accessKey, secretKey, region string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(AMZ_DATE_FORMAT))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{t.Format(SCOPE_DATE_FORMAT), region, S3_SERVICE, "aws4_request"}, "/")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{SIGNATURE_ALGORITHM, t.Format(AMZ_DATE_FORMAT), scope, hex.EncodeToString(hashedRequest[:])}, "\n")
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), t.Format(SCOPE_DATE_FORMAT))
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, S3_SERVICE)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", SIGNATURE_ALGORITHM, accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, k := range keys {
+		vs := values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+//encode each segment of path, the slashes are kept
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+//percent encode everything except the unreserved characters of RFC 3986
+func uriEncode(value string) string {
+	buf := bytes.Buffer{}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+//the S3 error response is XML, keep a part of it to find out the reason
+func responseError(res *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("blobstorage: %s responded %s %s", res.Request.URL.Host, res.Status, strings.TrimSpace(string(body)))
+}
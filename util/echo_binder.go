@@ -8,11 +8,35 @@ import (
 	"github.com/labstack/echo"
 	"github.com/asaskevich/govalidator"
 
+	"github.com/atahani/golang-rest-api-sample/models"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 )
 
 //this is custom bind function for echo to validate struct
 
+//the payloads that clean up their fields before validation such as the tags of article
+type Normalizer interface {
+	Normalize()
+}
+
+func init() {
+	//the validators of article tags
+	govalidator.TagMap["tag"] = govalidator.Validator(models.IsValidTag)
+	govalidator.CustomTypeTagMap.Set("tags", govalidator.CustomTypeValidator(func(i interface{}, o interface{}) bool {
+		tags, ok := i.([]string)
+		return ok && models.IsValidTags(tags)
+	}))
+	//the OAuth2 settings of clients
+	govalidator.CustomTypeTagMap.Set("redirect_uris", govalidator.CustomTypeValidator(func(i interface{}, o interface{}) bool {
+		uris, ok := i.([]string)
+		return ok && models.IsValidRedirectURIs(uris)
+	}))
+	govalidator.CustomTypeTagMap.Set("scopes", govalidator.CustomTypeValidator(func(i interface{}, o interface{}) bool {
+		scopes, ok := i.([]string)
+		return ok && models.IsValidScopes(scopes)
+	}))
+}
+
 type customBinderWithValidation struct {
 }
 
@@ -30,6 +54,14 @@ func (customBinderWithValidation) Bind(i interface{}, c echo.Context) error {
 	if err := json.NewDecoder(rq.Body()).Decode(i); err != nil {
 		return specialerror.ErrSomeFieldAreNotValid
 	}
+	return ValidateStruct(i)
+}
+
+//normalize and validate the payload the same as Bind, it's used for the payloads that are not in request body as JSON
+func ValidateStruct(i interface{}) error {
+	if n, ok := i.(Normalizer); ok {
+		n.Normalize()
+	}
 	//data decoded now should check validation if it's struct
 	val := reflect.ValueOf(i)
 	if val.Kind() == reflect.Interface || val.Kind() == reflect.Ptr {
@@ -0,0 +1,56 @@
+package etag
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	ETAG_HEADER          = "ETag"
+	IF_MATCH_HEADER      = "If-Match"
+	IF_NONE_MATCH_HEADER = "If-None-Match"
+	WEAK_PREFIX          = "W/"
+)
+
+//strong entity tag of the version of item
+func Version(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+//the versions of If-Match header, nil when the header is empty or * that means any version
+//the weak tags and the tags that are not version never match
+func IfMatchVersions(header string) []int {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+//If-None-Match header has the tag or it's *, the tags compared by the weak comparison
+func Match(header, tag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" || tag == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	tag = strings.TrimPrefix(tag, WEAK_PREFIX)
+	for _, t := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), WEAK_PREFIX) == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package etag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIfMatchVersions(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected []int
+	}{
+		{"", nil},
+		{"*", nil},
+		{`"3"`, []int{3}},
+		{` "3", "5" `, []int{3, 5}},
+		{`W/"3"`, []int{}},
+		{`"abc", 3`, []int{}},
+	}
+	for _, c := range cases {
+		if result := IfMatchVersions(c.header); !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("Error should %v \t but get %v", c.expected, result)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		header   string
+		tag      string
+		expected bool
+	}{
+		{"", Version(1), false},
+		{"*", Version(1), true},
+		{`"1"`, Version(1), true},
+		{`W/"1"`, Version(1), true},
+		{`"2", "1"`, Version(1), true},
+		{`"2"`, Version(1), false},
+		{`"1"`, "", false},
+	}
+	for _, c := range cases {
+		if result := Match(c.header, c.tag); result != c.expected {
+			t.Errorf("%s: Error should %v \t but get %v", c.header, c.expected, result)
+		}
+	}
+}
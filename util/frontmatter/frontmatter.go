@@ -0,0 +1,48 @@
+package frontmatter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	//the line before and after the YAML front matter
+	DELIMITER = "---"
+)
+
+var ErrNotClosed = errors.New("frontmatter: the front matter is not closed by --- line")
+
+//write the meta as YAML front matter and the body after it
+func Encode(w io.Writer, meta interface{}, body string) error {
+	data, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBufferString(DELIMITER + "\n")
+	buf.Write(data)
+	buf.WriteString(DELIMITER + "\n")
+	buf.WriteString(body)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+//decode the front matter into meta and return the body after it, the file without front matter is only body
+func Decode(data []byte, meta interface{}) (string, error) {
+	lines := strings.SplitAfter(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	if strings.TrimRight(lines[0], "\n") != DELIMITER {
+		return strings.Join(lines, ""), nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\n") == DELIMITER {
+			if err := yaml.Unmarshal([]byte(strings.Join(lines[1:i], "")), meta); err != nil {
+				return "", err
+			}
+			return strings.Join(lines[i+1:], ""), nil
+		}
+	}
+	return "", ErrNotClosed
+}
@@ -0,0 +1,53 @@
+package frontmatter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type meta struct {
+	Title string   `yaml:"title"`
+	Tags  []string `yaml:"tags,omitempty"`
+}
+
+func TestEncode(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, meta{Title: "hello", Tags: []string{"go"}}, "# hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "---\ntitle: hello\ntags:\n- go\n---\n# hello\n"
+	if buf.String() != expected {
+		t.Errorf("Error should %q \t but get %q", expected, buf.String())
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		data         string
+		expectedMeta meta
+		expectedBody string
+		expectError  bool
+	}{
+		{"---\ntitle: hello\ntags: [go, rest]\n---\n# hello\n", meta{"hello", []string{"go", "rest"}}, "# hello\n", false},
+		{"---\r\ntitle: windows\r\n---\r\nbody\r\n", meta{Title: "windows"}, "body\n", false},
+		{"---\n---\nempty front matter", meta{}, "empty front matter", false},
+		{"---\ntitle: at end\n---", meta{Title: "at end"}, "", false},
+		{"# only body\n---\n", meta{}, "# only body\n---\n", false},
+		{"---\ntitle: not closed\n", meta{}, "", true},
+		{"---\ntitle: [not valid\n---\n", meta{}, "", true},
+	}
+	for _, c := range cases {
+		m := meta{}
+		body, err := Decode([]byte(c.data), &m)
+		if (err != nil) != c.expectError {
+			t.Errorf("Error should error %v \t but get %v", c.expectError, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(m, c.expectedMeta) || body != c.expectedBody {
+			t.Errorf("Error should %+v %q \t but get %+v %q", c.expectedMeta, c.expectedBody, m, body)
+		}
+	}
+}
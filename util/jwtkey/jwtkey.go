@@ -0,0 +1,247 @@
+package jwtkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+const (
+	//id of the key made from jwt.signing_key, tokens without kid header verified by this key
+	DEFAULT_KEY_ID = "default"
+	KEY_ID_HEADER = "kid"
+)
+
+var (
+	ErrUnknownKey = errors.New("jwtkey: unknown or retired key")
+	ErrAlgorithmMismatch = errors.New("jwtkey: token algorithm doesn't match the key")
+)
+
+//signing key with the key that verify it, for HS algorithms both are the same secret
+type Key struct {
+	Id        string
+	Method    jwt.SigningMethod
+	Status    string
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+//keep the signing keys, sign with the active key and verify with any not retired key
+type Manager struct {
+	keys   map[string]*Key
+	//the keys in order of config
+	list   []*Key
+	active *Key
+}
+
+func NewManager(keys []*Key) (*Manager, error) {
+	m := &Manager{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if _, ok := m.keys[key.Id]; ok {
+			return nil, fmt.Errorf("jwtkey: duplicated key %s", key.Id)
+		}
+		m.keys[key.Id] = key
+		m.list = append(m.list, key)
+		if key.Status == config.JWT_KEY_ACTIVE_STATUS {
+			if m.active != nil {
+				return nil, errors.New("jwtkey: more than one active key")
+			}
+			if key.SignKey == nil {
+				return nil, fmt.Errorf("jwtkey: active key %s doesn't have private key", key.Id)
+			}
+			m.active = key
+		}
+	}
+	if m.active == nil {
+		return nil, errors.New("jwtkey: there isn't any active key")
+	}
+	return m, nil
+}
+
+//load keys in jwt config from disk, when there isn't any key the signing_key used as HS256 key
+func LoadManager(cfg config.JWTConfig) (*Manager, error) {
+	if len(cfg.Keys) == 0 {
+		return NewManager([]*Key{{
+			Id:        DEFAULT_KEY_ID,
+			Method:    jwt.SigningMethodHS256,
+			Status:    config.JWT_KEY_ACTIVE_STATUS,
+			SignKey:   []byte(cfg.SigningKey),
+			VerifyKey: []byte(cfg.SigningKey),
+		}})
+	}
+	keys := []*Key{}
+	for _, keyConfig := range cfg.Keys {
+		key, err := loadKey(keyConfig)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewManager(keys)
+}
+
+func loadKey(keyConfig config.JWTKeyConfig) (*Key, error) {
+	key := &Key{
+		Id:     keyConfig.Id,
+		Method: jwt.GetSigningMethod(keyConfig.Algorithm),
+		Status: keyConfig.Status,
+	}
+	if key.Method == nil {
+		return nil, fmt.Errorf("jwtkey: key %s has unsupported algorithm %s", key.Id, keyConfig.Algorithm)
+	}
+	//retired keys only kept to reject their tokens
+	if key.Status == config.JWT_KEY_RETIRED_STATUS {
+		return key, nil
+	}
+	data, err := ioutil.ReadFile(keyConfig.File)
+	if err != nil {
+		return nil, fmt.Errorf("jwtkey: can't read key %s: %s", key.Id, err)
+	}
+	switch key.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		secret := []byte(strings.TrimSpace(string(data)))
+		key.SignKey, key.VerifyKey = secret, secret
+	case *jwt.SigningMethodRSA:
+		if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			key.SignKey, key.VerifyKey = privateKey, &privateKey.PublicKey
+		} else if publicKey, err2 := jwt.ParseRSAPublicKeyFromPEM(data); err2 == nil && key.Status != config.JWT_KEY_ACTIVE_STATUS {
+			//verify keys can be only public key
+			key.VerifyKey = publicKey
+		} else {
+			return nil, fmt.Errorf("jwtkey: key %s is not valid RSA key: %s", key.Id, err)
+		}
+	case *jwt.SigningMethodECDSA:
+		if privateKey, err := jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+			key.SignKey, key.VerifyKey = privateKey, &privateKey.PublicKey
+		} else if publicKey, err2 := jwt.ParseECPublicKeyFromPEM(data); err2 == nil && key.Status != config.JWT_KEY_ACTIVE_STATUS {
+			key.VerifyKey = publicKey
+		} else {
+			return nil, fmt.Errorf("jwtkey: key %s is not valid ECDSA key: %s", key.Id, err)
+		}
+	default:
+		return nil, fmt.Errorf("jwtkey: key %s has unsupported algorithm %s", key.Id, keyConfig.Algorithm)
+	}
+	return key, nil
+}
+
+//new token that should be signed by the active key, the kid header set
+func (m *Manager) NewToken() *jwt.Token {
+	token := jwt.New(m.active.Method)
+	token.Header[KEY_ID_HEADER] = m.active.Id
+	return token
+}
+
+//sign the token made by NewToken with the active key
+func (m *Manager) Sign(token *jwt.Token) (string, error) {
+	return token.SignedString(m.active.SignKey)
+}
+
+//parse and verify the token with the key of its kid header
+func (m *Manager) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header[KEY_ID_HEADER].(string)
+		if kid == "" {
+			//the tokens issued before having kid header
+			kid = DEFAULT_KEY_ID
+		}
+		key, ok := m.keys[kid]
+		if !ok || key.Status == config.JWT_KEY_RETIRED_STATUS {
+			return nil, ErrUnknownKey
+		}
+		//always check the signing method
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, ErrAlgorithmMismatch
+		}
+		return key.VerifyKey, nil
+	})
+}
+
+//the not retired keys that have public key, the HS keys are secret so not included
+func (m *Manager) PublicKeys() []*Key {
+	keys := []*Key{}
+	for _, key := range m.list {
+		if key.Status == config.JWT_KEY_RETIRED_STATUS {
+			continue
+		}
+		switch key.VerifyKey.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+//the active key that sign new tokens
+func (m *Manager) ActiveKey() *Key {
+	return m.active
+}
+
+//algorithms of the not retired keys in order of config
+func (m *Manager) Algorithms() []string {
+	algs := []string{}
+	seen := map[string]bool{}
+	for _, key := range m.list {
+		if key.Status == config.JWT_KEY_RETIRED_STATUS || seen[key.Method.Alg()] {
+			continue
+		}
+		seen[key.Method.Alg()] = true
+		algs = append(algs, key.Method.Alg())
+	}
+	return algs
+}
+
+//JSON web key set of the public keys for jwks endpoint
+func (m *Manager) JWKS() models.JSONWebKeySet {
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	for _, key := range m.PublicKeys() {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+//public part of the key in JWK format, only for RSA and ECDSA keys
+func (k *Key) JWK() models.JSONWebKey {
+	jwk := models.JSONWebKey{
+		KeyId:     k.Id,
+		Use:       "sig",
+		Algorithm: k.Method.Alg(),
+	}
+	switch publicKey := k.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBase64URL(publicKey.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.KeyType = "EC"
+		params := publicKey.Curve.Params()
+		jwk.Curve = params.Name
+		//the coordinates should be full size of the curve
+		size := (params.BitSize + 7) / 8
+		jwk.X = encodeBase64URL(padBytes(publicKey.X.Bytes(), size))
+		jwk.Y = encodeBase64URL(padBytes(publicKey.Y.Bytes(), size))
+	}
+	return jwk
+}
+
+func encodeBase64URL(data []byte) string {
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(data), "=")
+}
+
+func padBytes(data []byte, size int) []byte {
+	if len(data) >= size {
+		return data
+	}
+	padded := make([]byte, size)
+	copy(padded[size - len(data):], data)
+	return padded
+}
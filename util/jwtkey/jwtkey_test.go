@@ -0,0 +1,132 @@
+package jwtkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+)
+
+//write new RSA, ECDSA and HMAC keys into dir and return their paths
+func writeKeys(t *testing.T, dir string) (string, string, string) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPath := filepath.Join(dir, "rsa.pem")
+	ioutil.WriteFile(rsaPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), 0600)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecBytes, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPath := filepath.Join(dir, "ec.pem")
+	ioutil.WriteFile(ecPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecBytes}), 0600)
+	hmacPath := filepath.Join(dir, "hmac.key")
+	ioutil.WriteFile(hmacPath, []byte("some secret for hmac\n"), 0600)
+	return rsaPath, ecPath, hmacPath
+}
+
+func signedToken(t *testing.T, m *Manager) string {
+	token := m.NewToken()
+	token.Claims["uid"] = "user"
+	signed, err := m.Sign(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rsaPath, ecPath, hmacPath := writeKeys(t, dir)
+	//each manager sign one token and every token verified by all of managers
+	managerConfigs := [][]config.JWTKeyConfig{
+		{
+			{Id: "rsa", Algorithm: "RS256", File: rsaPath, Status: config.JWT_KEY_ACTIVE_STATUS},
+		},
+		{
+			{Id: "rsa", Algorithm: "RS256", File: rsaPath, Status: config.JWT_KEY_VERIFY_STATUS},
+			{Id: "ec", Algorithm: "ES256", File: ecPath, Status: config.JWT_KEY_ACTIVE_STATUS},
+		},
+		{
+			{Id: "rsa", Algorithm: "RS256", Status: config.JWT_KEY_RETIRED_STATUS},
+			{Id: "ec", Algorithm: "ES256", File: ecPath, Status: config.JWT_KEY_VERIFY_STATUS},
+			{Id: "hmac", Algorithm: "HS256", File: hmacPath, Status: config.JWT_KEY_ACTIVE_STATUS},
+		},
+	}
+	managers := []*Manager{}
+	tokens := []string{}
+	for _, keys := range managerConfigs {
+		m, err := LoadManager(config.JWTConfig{Keys: keys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		managers = append(managers, m)
+		tokens = append(tokens, signedToken(t, m))
+	}
+	cases := []struct {
+		manager  int
+		token    int
+		expected bool
+	}{
+		{manager: 0, token: 0, expected: true},
+		{manager: 0, token: 1, expected: false},
+		{manager: 1, token: 0, expected: true},
+		{manager: 1, token: 1, expected: true},
+		{manager: 2, token: 0, expected: false},
+		{manager: 2, token: 1, expected: true},
+		{manager: 2, token: 2, expected: true},
+	}
+	for _, c := range cases {
+		to, err := managers[c.manager].Parse(tokens[c.token])
+		if valid := err == nil && to.Valid; valid != c.expected {
+			t.Errorf("manager %d verify token %d should be %t \t but get %v", c.manager, c.token, c.expected, err)
+		}
+	}
+	//the kid header should be the active key
+	if to, err := managers[1].Parse(tokens[1]); err != nil || to.Header[KEY_ID_HEADER] != "ec" {
+		t.Errorf("kid header should %q \t but get %v", "ec", to.Header[KEY_ID_HEADER])
+	}
+	//only the asymmetric keys that are not retired have public key
+	if keys := managers[2].PublicKeys(); len(keys) != 1 || keys[0].Id != "ec" {
+		t.Errorf("public keys should only have ec key \t but get %d keys", len(keys))
+	}
+}
+
+func TestParseRejectAlgorithmMismatch(t *testing.T) {
+	m, err := LoadManager(config.JWTConfig{SigningKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//token with the default kid but signed by none algorithm
+	token := jwt.New(jwt.SigningMethodNone)
+	token.Header[KEY_ID_HEADER] = DEFAULT_KEY_ID
+	signed, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := m.Parse(signed); err == nil {
+		t.Error("token with different algorithm should not be valid")
+	}
+	//tokens without kid verified by default key
+	token = jwt.New(jwt.SigningMethodHS256)
+	signed, _ = token.SignedString([]byte("secret"))
+	if _, err := m.Parse(signed); err != nil {
+		t.Errorf("token without kid should be valid \t but get %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+)
+
+var (
+	ErrNotValidHeader = errors.New("mailer: header should not have new line")
+)
+
+//plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+//send emails, the implementation selected by mail.driver config
+type Mailer interface {
+	Send(m *Message) error
+}
+
+//mailer of the mail config driver
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case config.MAIL_SMTP_DRIVER:
+		return NewSMTPMailer(cfg), nil
+	case config.MAIL_FILE_DRIVER:
+		return NewFileMailer(cfg.From, cfg.File)
+	case config.MAIL_LOG_DRIVER:
+		return NewWriterMailer(cfg.From, os.Stdout), nil
+	}
+	return nil, fmt.Errorf("mailer: unknown driver %s", cfg.Driver)
+}
+
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(cfg.SMTP.Host, strconv.Itoa(cfg.SMTP.Port)),
+		from: cfg.From,
+	}
+	//the server without authentication used in local networks
+	if cfg.SMTP.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
+	}
+	return m
+}
+
+//send by smtp, STARTTLS used when the server support it
+func (m *SMTPMailer) Send(msg *Message) error {
+	data, err := Build(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, data)
+}
+
+//write the whole messages to writer, it's used to develop and test without any mail server
+type WriterMailer struct {
+	mutex sync.Mutex
+	from  string
+	w     io.Writer
+}
+
+func NewWriterMailer(from string, w io.Writer) *WriterMailer {
+	return &WriterMailer{from: from, w: w}
+}
+
+//mailer that append the messages to file
+func NewFileMailer(from, path string) (*WriterMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: can't open %s: %s", path, err)
+	}
+	return NewWriterMailer(from, f), nil
+}
+
+func (m *WriterMailer) Send(msg *Message) error {
+	data, err := Build(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	//separate the messages by empty line
+	_, err = m.w.Write(append(data, '\r', '\n'))
+	return err
+}
+
+//the message in RFC 5322 format
+func Build(from string, msg *Message, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrNotValidHeader
+		}
+	}
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	//the lines of body should end with CRLF
+	buf.WriteString(strings.Replace(strings.Replace(msg.Body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	buf.WriteString("\r\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atahani/golang-rest-api-sample/config"
+)
+
+func TestBuild(t *testing.T) {
+	date := time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		msg           Message
+		expected      string
+		expectedError error
+	}{
+		{
+			msg:      Message{To: "user@example.com", Subject: "Reset your password", Body: "first line\nsecond line"},
+			expected: "From: no-reply@example.com\r\nTo: user@example.com\r\nSubject: Reset your password\r\nDate: Sun, 01 May 2016 10:00:00 +0000\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nfirst line\r\nsecond line\r\n",
+		},
+		{
+			//header injection
+			msg:           Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "subject"},
+			expectedError: ErrNotValidHeader,
+		},
+		{
+			msg:           Message{To: "user@example.com", Subject: "subject\nBcc: other@example.com"},
+			expectedError: ErrNotValidHeader,
+		},
+	}
+	for _, c := range cases {
+		data, err := Build("no-reply@example.com", &c.msg, date)
+		if err != c.expectedError {
+			t.Errorf("Error should %v \t but get %v", c.expectedError, err)
+		}
+		if err == nil && string(data) != c.expected {
+			t.Errorf("message should %q \t but get %q", c.expected, string(data))
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mails.txt")
+	m, err := New(config.MailConfig{Driver: config.MAIL_FILE_DRIVER, From: "no-reply@example.com", File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, to := range []string{"first@example.com", "second@example.com"} {
+		if err := m.Send(&Message{To: to, Subject: "hello", Body: "token: 1234"}); err != nil {
+			t.Errorf("Error should %v \t but get %v", nil, err)
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: first@example.com") || !strings.Contains(string(data), "To: second@example.com") || strings.Count(string(data), "token: 1234") != 2 {
+		t.Errorf("file should have both of messages \t but get %q", string(data))
+	}
+}
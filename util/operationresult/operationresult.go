@@ -4,6 +4,14 @@ var (
 	SuccessfullyRemoved = New("SUCCESSFULLY_REMOVED", "the item successfully removed")
 	SuccessfullyUpdated = New("SUCCESSFULLY_UPDATED", "the item successfuly updated")
 	PasswordSuccessfullyChanged = New("PASSWORD_SUCCESSFULLY_CHANGE", "user password successfully changed")
+	PasswordResetEmailSent = New("PASSWORD_RESET_EMAIL_SENT", "if there is an account with this email address, the reset password link sent to it")
+	PasswordSuccessfullyReset = New("PASSWORD_SUCCESSFULLY_RESET", "user password successfully reset, please sign in again")
+	EmailVerificationSent = New("EMAIL_VERIFICATION_SENT", "the verification link sent to email address")
+	EmailSuccessfullyVerified = New("EMAIL_SUCCESSFULLY_VERIFIED", "email address successfully verified")
+	ProfileUpdatedEmailPending = New("PROFILE_UPDATED_EMAIL_PENDING", "the profile updated, the new email address changed after verification")
+	SuccessfullySignedOut = New("SUCCESSFULLY_SIGNED_OUT", "the access token and refresh token successfully revoked")
+	SuccessfullySignedOutAll = New("SUCCESSFULLY_SIGNED_OUT_ALL", "all of access tokens and trusted apps successfully revoked")
+	PasswordResetRequired = New("PASSWORD_RESET_REQUIRED", "the user signed out and the reset password link sent to the email address")
 )
 
 type OperationResult struct {
@@ -0,0 +1,78 @@
+package pagination
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/atahani/golang-rest-api-sample/store"
+	"github.com/atahani/golang-rest-api-sample/util/specialerror"
+)
+
+const (
+	LIMIT_PARAM      = "limit"
+	CURSOR_PARAM     = "cursor"
+	SORT_PARAM       = "sort"
+	ORDER_PARAM      = "order"
+	TOTAL_PARAM      = "total"
+	ASCENDING_ORDER  = "asc"
+	DESCENDING_ORDER = "desc"
+)
+
+//the page request of limit, cursor, sort, order and total query parameters
+//sortFields maps the sort parameter to the bson field such as created to created_at, the default order is descending
+func ParsePageRequest(c echo.Context, sortFields map[string]string, defaultSort string) (*store.PageRequest, error) {
+	page := store.PageRequest{Limit: store.DEFAULT_PAGE_LIMIT, Descending: true}
+	if value := c.QueryParam(LIMIT_PARAM); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > store.MAX_PAGE_LIMIT {
+			return nil, specialerror.ErrNotValidQueryParameter
+		}
+		page.Limit = limit
+	}
+	sort := c.QueryParam(SORT_PARAM)
+	if sort == "" {
+		sort = defaultSort
+	}
+	field, ok := sortFields[sort]
+	if !ok {
+		return nil, specialerror.ErrNotValidQueryParameter
+	}
+	page.SortField = field
+	switch c.QueryParam(ORDER_PARAM) {
+	case "", DESCENDING_ORDER:
+	case ASCENDING_ORDER:
+		page.Descending = false
+	default:
+		return nil, specialerror.ErrNotValidQueryParameter
+	}
+	if value := c.QueryParam(CURSOR_PARAM); value != "" {
+		cursor, err := store.DecodeCursor(value, field)
+		if err != nil {
+			return nil, specialerror.ErrNotValidCursor
+		}
+		page.Cursor = cursor
+	}
+	if value := c.QueryParam(TOTAL_PARAM); value != "" {
+		withTotal, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, specialerror.ErrNotValidQueryParameter
+		}
+		page.WithTotal = withTotal
+	}
+	return &page, nil
+}
+
+//time query parameter in RFC 3339 format such as 2016-09-20T10:00:00Z, zero time when it's not set
+func ParseTimeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, specialerror.ErrNotValidQueryParameter
+	}
+	return t, nil
+}
@@ -0,0 +1,117 @@
+package permission
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+)
+
+//the permissions of each role, loaded from database and reloaded when the roles changed
+type Policy struct {
+	roles       store.RoleStore
+	mutex       sync.RWMutex
+	permissions map[string][]string
+}
+
+func NewPolicy(roles store.RoleStore) *Policy {
+	return &Policy{roles: roles, permissions: map[string][]string{}}
+}
+
+//create the default roles that don't exist and load all of roles
+func (p *Policy) Init() error {
+	for _, role := range models.DefaultRoles() {
+		if err := p.roles.Insert(&role); err != nil && err != store.ErrDuplicate {
+			return err
+		}
+	}
+	return p.Reload()
+}
+
+//load the roles from database, the roles that changed by other instances loaded by this
+func (p *Policy) Reload() error {
+	roles, err := p.roles.FindAll()
+	if err != nil {
+		return err
+	}
+	permissions := map[string][]string{}
+	for _, role := range roles {
+		permissions[role.Name] = role.Permissions
+	}
+	p.mutex.Lock()
+	p.permissions = permissions
+	p.mutex.Unlock()
+	return nil
+}
+
+//reload the roles in each interval until stop closed, nil stop runs forever
+func (p *Policy) RunReloader(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Reload(); err != nil {
+				fmt.Printf("role reloader: %s\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (p *Policy) HasRole(name string) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	_, ok := p.permissions[name]
+	return ok
+}
+
+//union of the permissions of roles, the unknown roles don't have any permission
+func (p *Policy) Permissions(roles []string) []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	result := []string{}
+	seen := map[string]bool{}
+	for _, role := range roles {
+		for _, permission := range p.permissions[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				result = append(result, permission)
+			}
+		}
+	}
+	return result
+}
+
+//check the granted permissions have this permission
+func Has(granted []string, permission string) bool {
+	for _, g := range granted {
+		if g == permission || g == models.ALL_PERMISSIONS {
+			return true
+		}
+	}
+	return false
+}
+
+//check the granted permissions have all of required permissions
+func HasAll(granted []string, required ...string) bool {
+	for _, permission := range required {
+		if !Has(granted, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+//check the granted permissions have at least one of required permissions
+func HasAny(granted []string, required ...string) bool {
+	for _, permission := range required {
+		if Has(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package permission
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+	"github.com/atahani/golang-rest-api-sample/store"
+)
+
+func TestHasAllAndHasAny(t *testing.T) {
+	cases := []struct {
+		granted     []string
+		required    []string
+		expectedAll bool
+		expectedAny bool
+	}{
+		{granted: []string{"article:read", "article:write"}, required: []string{"article:read", "article:write"}, expectedAll: true, expectedAny: true},
+		{granted: []string{"article:read"}, required: []string{"article:read", "article:write"}, expectedAll: false, expectedAny: true},
+		{granted: []string{"client:manage"}, required: []string{"article:read", "article:write"}, expectedAll: false, expectedAny: false},
+		{granted: []string{models.ALL_PERMISSIONS}, required: []string{"article:read", "role:manage"}, expectedAll: true, expectedAny: true},
+		{granted: nil, required: []string{"article:read"}, expectedAll: false, expectedAny: false},
+		//nothing required
+		{granted: nil, required: nil, expectedAll: true, expectedAny: false},
+	}
+	for i, c := range cases {
+		if hasAll := HasAll(c.granted, c.required...); hasAll != c.expectedAll {
+			t.Errorf("case %d: HasAll should %t \t but get %t", i, c.expectedAll, hasAll)
+		}
+		if hasAny := HasAny(c.granted, c.required...); hasAny != c.expectedAny {
+			t.Errorf("case %d: HasAny should %t \t but get %t", i, c.expectedAny, hasAny)
+		}
+	}
+}
+
+func TestPolicyReload(t *testing.T) {
+	roles := store.NewMemoryRoleStore()
+	policy := NewPolicy(roles)
+	if err := policy.Init(); err != nil {
+		t.Fatal(err)
+	}
+	//init again doesn't change the existing roles
+	roles.Update(models.USER_ROLE, &models.Role{Permissions: []string{models.ARTICLE_READ_PERMISSION}})
+	if err := policy.Init(); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		roles    []string
+		expected []string
+	}{
+		{roles: []string{models.USER_ROLE}, expected: []string{models.ARTICLE_READ_PERMISSION}},
+		{roles: []string{models.USER_ROLE, models.ADMIN_ROLE}, expected: []string{models.ARTICLE_READ_PERMISSION, models.ALL_PERMISSIONS}},
+		{roles: []string{"editor"}, expected: []string{}},
+	}
+	for _, c := range cases {
+		if permissions := policy.Permissions(c.roles); fmt.Sprint(permissions) != fmt.Sprint(c.expected) {
+			t.Errorf("permissions of %v should %v \t but get %v", c.roles, c.expected, permissions)
+		}
+	}
+	//the new role is used after reload
+	roles.Insert(&models.Role{Name: "editor", Permissions: []string{models.ARTICLE_WRITE_PERMISSION}})
+	if policy.HasRole("editor") {
+		t.Error("the role should not be loaded before reload")
+	}
+	if err := policy.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if permissions := policy.Permissions([]string{"editor"}); fmt.Sprint(permissions) != fmt.Sprint([]string{models.ARTICLE_WRITE_PERMISSION}) {
+		t.Errorf("permissions of editor should %v \t but get %v", []string{models.ARTICLE_WRITE_PERMISSION}, permissions)
+	}
+}
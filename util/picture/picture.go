@@ -0,0 +1,92 @@
+package picture
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	JPEG_FORMAT  = "jpeg"
+	PNG_FORMAT   = "png"
+	JPEG_QUALITY = 90
+)
+
+var (
+	ErrNotSupportedFormat = errors.New("picture: only JPEG and PNG images are supported")
+	ErrTooLargeDimension  = errors.New("picture: image dimension is too large")
+)
+
+//decode JPEG or PNG image, the dimension checked before decoding the pixels so huge images don't fill the memory
+func Decode(data []byte, maxDimension int) (image.Image, string, error) {
+	//the format detected by content, the file name and content type of upload are not trusted
+	contentType := http.DetectContentType(data)
+	if contentType != ContentType(JPEG_FORMAT) && contentType != ContentType(PNG_FORMAT) {
+		return nil, "", ErrNotSupportedFormat
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if ContentType(format) != contentType {
+		return nil, "", ErrNotSupportedFormat
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension {
+		return nil, "", ErrTooLargeDimension
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+//square image from the center of image, the small images are not scaled up
+func Square(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, side, side))
+	offset := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	draw.Draw(cropped, cropped.Bounds(), img, offset, draw.Src)
+	if side <= size {
+		return cropped
+	}
+	return resize.Resize(uint(size), uint(size), cropped, resize.Lanczos3)
+}
+
+//encode image by the format, PNG kept as PNG so the transparency doesn't lost
+func Encode(img image.Image, format string) ([]byte, error) {
+	buf := bytes.Buffer{}
+	var err error
+	switch format {
+	case JPEG_FORMAT:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEG_QUALITY})
+	case PNG_FORMAT:
+		err = png.Encode(&buf, img)
+	default:
+		err = ErrNotSupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func ContentType(format string) string {
+	switch format {
+	case JPEG_FORMAT:
+		return "image/jpeg"
+	case PNG_FORMAT:
+		return "image/png"
+	}
+	return ""
+}
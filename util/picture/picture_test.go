@@ -0,0 +1,75 @@
+package picture
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func newPNG(width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, height/2, color.RGBA{255, 0, 0, 255})
+	}
+	buf := bytes.Buffer{}
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		data          []byte
+		expectedError error
+	}{
+		{newPNG(300, 200), nil},
+		{newPNG(300, 2000), ErrTooLargeDimension},
+		{[]byte("GIF89a not supported"), ErrNotSupportedFormat},
+		{[]byte("<html>not image</html>"), ErrNotSupportedFormat},
+	}
+	for _, c := range cases {
+		_, format, err := Decode(c.data, 1024)
+		if err != c.expectedError {
+			t.Errorf("Error should %v \t but get %v", c.expectedError, err)
+		}
+		if err == nil && format != PNG_FORMAT {
+			t.Errorf("Error should %v \t but get %v", PNG_FORMAT, format)
+		}
+	}
+}
+
+func TestSquare(t *testing.T) {
+	img, _, err := Decode(newPNG(300, 200), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		size         int
+		expectedSide int
+	}{
+		{64, 64},
+		{128, 128},
+		//not scaled up
+		{512, 200},
+	}
+	for _, c := range cases {
+		b := Square(img, c.size).Bounds()
+		if b.Dx() != c.expectedSide || b.Dy() != c.expectedSide {
+			t.Errorf("Error should %dx%d \t but get %dx%d", c.expectedSide, c.expectedSide, b.Dx(), b.Dy())
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	img, _, _ := Decode(newPNG(100, 100), 1024)
+	for _, format := range []string{JPEG_FORMAT, PNG_FORMAT} {
+		data, err := Encode(Square(img, 64), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, got, err := Decode(data, 1024); err != nil || got != format {
+			t.Errorf("Error should %v \t but get %v %v", format, got, err)
+		}
+	}
+}
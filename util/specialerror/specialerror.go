@@ -15,13 +15,40 @@ var (
 	ErrNotValidCredentialInfo = New(http.StatusNonAuthoritativeInfo, http.StatusNonAuthoritativeInfo, "CREDENTIAL_INFORMATION_IS_NOT_VALID", "credential information is not valid")
 	ErrMethodNotAllowed = New(http.StatusMethodNotAllowed, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "method not allowed, please see the API document for more information")
 	ErrNotValidItemId = New(http.StatusBadRequest, http.StatusBadRequest, "NOT_VALID_ITEM_ID", "not valid item id")
+	ErrNotValidQueryParameter = New(http.StatusBadRequest, http.StatusBadRequest, "NOT_VALID_QUERY_PARAMETER", "some query parameters are not valid, please see the API document for more information")
+	ErrNotValidCursor = New(http.StatusBadRequest, http.StatusBadRequest, "NOT_VALID_CURSOR", "cursor is not valid, use the next_cursor of previous page with the same sort")
 	ErrNotFoundAnyItemWithThisId = New(http.StatusNotFound, http.StatusNotFound, "NOT_FOUND_ANY_ITEM_WITH_THIS_ID", "not found any item with this id")
+	ErrNotFoundAnyUserWithThisEmail = New(http.StatusNotFound, http.StatusNotFound, "NOT_FOUND_ANY_USER_WITH_THIS_EMAIL", "not found any user with this email address")
+	ErrCanNotShareWithOwner = New(http.StatusBadRequest, http.StatusBadRequest, "CAN_NOT_SHARE_WITH_OWNER", "the owner of article can't be its collaborator")
 	ErrInternalServerError = New(http.StatusInternalServerError, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "internal server error")
 	ErrNotValidClientInformation = New(http.StatusNonAuthoritativeInfo, http.StatusNonAuthoritativeInfo, "CLIENT_INFORMATION_IS_NOT_VALID", "client information is not valid")
 	ErrClientIsNotValidToCommunicate = New(http.StatusForbidden, http.StatusForbidden, "CLIENT_IS_NOT_VALID_TO_COMMUNICATE", "client is not valid to communicate")
+	ErrRedirectURIIsNotValid = New(http.StatusBadRequest, http.StatusBadRequest, "REDIRECT_URI_IS_NOT_VALID", "the redirect URI is not registered for this client")
+	ErrScopeIsNotAllowed = New(http.StatusBadRequest, http.StatusBadRequest, "SCOPE_IS_NOT_ALLOWED", "the client is not allowed to request some of these scopes")
+	ErrAuthorizationCodeIsNotValid = New(http.StatusBadRequest, http.StatusBadRequest, "AUTHORIZATION_CODE_IS_NOT_VALID", "authorization code is not valid, expired or its code verifier or redirect URI doesn't match")
+	ErrGrantTypeIsNotAllowed = New(http.StatusBadRequest, http.StatusBadRequest, "GRANT_TYPE_IS_NOT_ALLOWED", "the web clients don't have secret, so they can't use client credentials")
 	ErrRefreshTokenIsNotValid = New(http.StatusBadRequest, http.StatusBadRequest, "REFRESH_TOKEN_IS_NOT_VALID", "refresh token is not valid")
+	ErrPasswordResetTokenIsNotValid = New(http.StatusBadRequest, http.StatusBadRequest, "PASSWORD_RESET_TOKEN_IS_NOT_VALID", "password reset token is not valid or expired")
+	ErrEmailVerificationTokenIsNotValid = New(http.StatusBadRequest, http.StatusBadRequest, "EMAIL_VERIFICATION_TOKEN_IS_NOT_VALID", "email verification token is not valid or expired")
+	ErrEmailIsNotVerified = New(http.StatusForbidden, http.StatusForbidden, "EMAIL_IS_NOT_VERIFIED", "please verify your email address first")
+	ErrEmailIsAlreadyVerified = New(http.StatusBadRequest, http.StatusBadRequest, "EMAIL_IS_ALREADY_VERIFIED", "email address is already verified")
+	ErrRefreshTokenIsExpired = New(http.StatusBadRequest, http.StatusBadRequest, "REFRESH_TOKEN_IS_EXPIRED", "refresh token is expired, please sign in again")
+	ErrImageIsNotValid = New(http.StatusBadRequest, http.StatusBadRequest, "IMAGE_IS_NOT_VALID", "image should be JPEG or PNG file in image field of multipart form")
+	ErrImageIsTooLarge = New(http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge, "IMAGE_IS_TOO_LARGE", "image file size or dimension is too large")
+	ErrArticleStatusCanNotChange = New(http.StatusConflict, http.StatusConflict, "ARTICLE_STATUS_CAN_NOT_CHANGE", "the article can't change to this status from its current status")
+	ErrCommentCanNotBeReplied = New(http.StatusBadRequest, http.StatusBadRequest, "COMMENT_CAN_NOT_BE_REPLIED", "only the top level comments of this article can be replied")
+	ErrPreconditionFailed = New(http.StatusPreconditionFailed, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "the item changed after you get it, please get it again and retry with the new ETag in If-Match header")
+	ErrImportIsTooLarge = New(http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge, "IMPORT_IS_TOO_LARGE", "the import file is too large, please split it to smaller files")
+	ErrArticleIsInTrash = New(http.StatusConflict, http.StatusConflict, "ARTICLE_IS_IN_TRASH", "the article of this external id is in trash, restore it first or purge it to import it again")
+	ErrImportFileIsNotValid = New(http.StatusBadRequest, http.StatusBadRequest, "IMPORT_FILE_IS_NOT_VALID", "the import file should be NDJSON or zip of markdown files base on format query parameter")
+	ErrInsufficientScope = New(http.StatusForbidden, http.StatusForbidden, "INSUFFICIENT_SCOPE", "the access token doesn't have the scope of this resource")
 	ErrCanNotAccessToTheseResource = New(http.StatusForbidden, http.StatusForbidden, "CAN_NOT_ACCESS_TO_THESE_RESOURCES", "you can't access to these resources")
 	ErrUserIsDisable = New(http.StatusForbidden, http.StatusForbidden, "USER_IS_DISABLED", "user is disabled !")
+	ErrPasswordResetRequired = New(http.StatusForbidden, http.StatusForbidden, "PASSWORD_RESET_REQUIRED", "please reset your password by the link that sent to your email address")
+	ErrCanNotManageYourself = New(http.StatusForbidden, http.StatusForbidden, "CAN_NOT_MANAGE_YOURSELF", "admins can't disable, change the roles or delete their own account")
+	ErrRoleAlreadyExists = New(http.StatusConflict, http.StatusConflict, "ROLE_ALREADY_EXISTS", "there is a role with this name")
+	ErrRoleIsInUse = New(http.StatusConflict, http.StatusConflict, "ROLE_IS_IN_USE", "the role is given to some users, remove it from them first")
+	ErrBuiltinRoleCanNotChange = New(http.StatusForbidden, http.StatusForbidden, "BUILTIN_ROLE_CAN_NOT_CHANGE", "the builtin roles can't be removed and the admin role can't be changed")
 	ErrAlreadyHaveUserWithThisEmailAddress = New(http.StatusBadRequest, http.StatusBadRequest, "ALREADY_HAVE_USER_WITH_EMAIL_ADDRESS", "already have user with this email address")
 )
 
@@ -1,30 +1,43 @@
 package testhelper
 
 import (
-	"fmt"
-	"time"
-
-	"gopkg.in/mgo.v2"
+	"sync"
 
 	"github.com/labstack/echo"
 
+	"github.com/atahani/golang-rest-api-sample/config"
+	"github.com/atahani/golang-rest-api-sample/store"
 	"github.com/atahani/golang-rest-api-sample/util"
+	"github.com/atahani/golang-rest-api-sample/util/blobstorage"
+	"github.com/atahani/golang-rest-api-sample/util/jwtkey"
+	"github.com/atahani/golang-rest-api-sample/util/mailer"
+	"github.com/atahani/golang-rest-api-sample/util/permission"
 	"github.com/atahani/golang-rest-api-sample/util/specialerror"
 )
 
-const (
-	DB_TEST_NAME = "golang_sample_test"
-)
-
 //utilities for testing used in unit testing
 type TestingProvider struct {
-	Session *mgo.Session
+	Config  *config.Config
+	Keys    *jwtkey.Manager
+	Stores  *store.Stores
+	//the policy of default roles
+	Policy  *permission.Policy
+	//the emails sent by controllers
+	MailBox *MailBox
+	Storage *blobstorage.MemoryStorage
 	Echo    *echo.Echo
 	Router  *echo.Router
 }
 
 func (provider *TestingProvider) StartTesting() {
-	provider.Session = getSession()
+	provider.Config = config.Default(config.DEVELOPMENT_ENV)
+	provider.Keys, _ = jwtkey.LoadManager(provider.Config.JWT)
+	//use in memory stores so testing doesn't need any running database
+	provider.Stores = store.NewMemoryStores()
+	provider.Policy = permission.NewPolicy(provider.Stores.Roles)
+	provider.Policy.Init()
+	provider.MailBox = &MailBox{}
+	provider.Storage = blobstorage.NewMemoryStorage()
 	//create new echo server
 	provider.Echo = echo.New()
 	provider.Router = provider.Echo.Router()
@@ -36,17 +49,27 @@ func (provider *TestingProvider) StartTesting() {
 	provider.Echo.SetDebug(true)
 }
 
-func getSession() *mgo.Session {
-	mongoDBDialInfo := &mgo.DialInfo{
-		Addrs:    []string{"localhost"},
-		Timeout:  60 * time.Second,
-		Database: DB_TEST_NAME,
-	}
-	//first should set path the fake database
-	//dbServer.SetPath(folderPath)
-	session, err := mgo.DialWithInfo(mongoDBDialInfo)
-	if err != nil {
-		fmt.Printf("connection %s\n", err)
+//mailer that keep the sent messages in memory
+type MailBox struct {
+	mutex    sync.Mutex
+	Messages []mailer.Message
+}
+
+func (box *MailBox) Send(m *mailer.Message) error {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+	box.Messages = append(box.Messages, *m)
+	return nil
+}
+
+//the last message sent to this address
+func (box *MailBox) LastMessageTo(to string) *mailer.Message {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+	for i := len(box.Messages) - 1; i >= 0; i-- {
+		if box.Messages[i].To == to {
+			return &box.Messages[i]
+		}
 	}
-	return session
+	return nil
 }
@@ -0,0 +1,92 @@
+package textdiff
+
+import (
+	"strings"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+const (
+	//the lines table of longest common subsequence more than this size is too large, the lines replaced totally
+	MAX_TABLE_SIZE = 4000000
+)
+
+//line by line diff of two texts, the deleted lines come before the inserted lines in each change
+func Lines(from, to string) []models.DiffLine {
+	a, b := split(from), split(to)
+	//the common prefix and suffix don't need the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	result := []models.DiffLine{}
+	for _, text := range a[:prefix] {
+		result = append(result, newLine(models.EQUAL_DIFF_OP, text))
+	}
+	result = append(result, diff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		result = append(result, newLine(models.EQUAL_DIFF_OP, text))
+	}
+	return result
+}
+
+func split(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+}
+
+//diff by the table of longest common subsequence
+func diff(a, b []string) []models.DiffLine {
+	result := []models.DiffLine{}
+	if (len(a)+1)*(len(b)+1) > MAX_TABLE_SIZE {
+		for _, text := range a {
+			result = append(result, newLine(models.DELETE_DIFF_OP, text))
+		}
+		for _, text := range b {
+			result = append(result, newLine(models.INSERT_DIFF_OP, text))
+		}
+		return result
+	}
+	//lcs[i][j] is the length of longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			result = append(result, newLine(models.EQUAL_DIFF_OP, a[i]))
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			result = append(result, newLine(models.DELETE_DIFF_OP, a[i]))
+			i++
+		default:
+			result = append(result, newLine(models.INSERT_DIFF_OP, b[j]))
+			j++
+		}
+	}
+	return result
+}
+
+func newLine(op, text string) models.DiffLine {
+	return models.DiffLine{Op: op, Text: text}
+}
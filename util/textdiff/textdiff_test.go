@@ -0,0 +1,30 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/atahani/golang-rest-api-sample/models"
+)
+
+func TestLines(t *testing.T) {
+	eq, ins, del := models.EQUAL_DIFF_OP, models.INSERT_DIFF_OP, models.DELETE_DIFF_OP
+	cases := []struct {
+		from     string
+		to       string
+		expected []models.DiffLine
+	}{
+		{"", "", []models.DiffLine{}},
+		{"a\nb", "a\nb", []models.DiffLine{newLine(eq, "a"), newLine(eq, "b")}},
+		{"", "a", []models.DiffLine{newLine(ins, "a")}},
+		{"a\nb\nc", "a\nc", []models.DiffLine{newLine(eq, "a"), newLine(del, "b"), newLine(eq, "c")}},
+		{"a\nb\nc", "a\nx\nc\nd", []models.DiffLine{newLine(eq, "a"), newLine(del, "b"), newLine(ins, "x"), newLine(eq, "c"), newLine(ins, "d")}},
+		{"x\na\nb", "a\nb\nx", []models.DiffLine{newLine(del, "x"), newLine(eq, "a"), newLine(eq, "b"), newLine(ins, "x")}},
+		{"a\r\nb", "a\nb", []models.DiffLine{newLine(eq, "a"), newLine(eq, "b")}},
+	}
+	for _, c := range cases {
+		if result := Lines(c.from, c.to); !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("Error should %v \t but get %v", c.expected, result)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package textsearch
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	HIGHLIGHT_START = "<mark>"
+	HIGHLIGHT_END   = "</mark>"
+	ELLIPSIS        = "..."
+)
+
+//the words that are not searched, the same as the common english stop words of mongodb
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true,
+	"for": true, "from": true, "has": true, "have": true, "in": true, "is": true, "it": true, "its": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true, "were": true, "with": true,
+}
+
+//the word of text and its position in bytes
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+//split the text to words, the terms are lower case and stemmed
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text + " " {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWordRune && start < 0 {
+			start = i
+		} else if !isWordRune && start >= 0 {
+			tokens = append(tokens, token{stem(strings.ToLower(text[start:i])), start, i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+//light stemmer that remove the common english suffixes, so search and searching match each other
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if strings.HasSuffix(word, suffix) && utf8.RuneCountInString(word)-len(suffix) >= 3 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+//the search terms of query, stop words, duplicates and negated words such as -word are removed
+func Terms(query string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, t := range tokenize(query) {
+		if stopWords[t.term] || seen[t.term] || isNegated(query, t.start) {
+			continue
+		}
+		seen[t.term] = true
+		terms = append(terms, t.term)
+	}
+	return terms
+}
+
+func isNegated(query string, start int) bool {
+	return start > 0 && query[start-1] == '-' && (start == 1 || query[start-2] == ' ')
+}
+
+func isTerm(term string, terms []string) bool {
+	for _, t := range terms {
+		if t == term {
+			return true
+		}
+	}
+	return false
+}
+
+//relevance of text for the terms, the ratio of matched words multiplied by the weight of field
+//zero means the text doesn't have any of terms
+func Score(text string, terms []string, weight float64) float64 {
+	tokens := tokenize(text)
+	matches := 0
+	for _, t := range tokens {
+		if isTerm(t.term, terms) {
+			matches++
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	return weight * float64(matches) / float64(len(tokens))
+}
+
+//part of text around the first matched word with at most size characters
+//the text is HTML escaped and the matched words wrapped in mark tag, so clients can show it as HTML
+func Snippet(text string, terms []string, size int) string {
+	tokens := tokenize(text)
+	first := -1
+	for i, t := range tokens {
+		if isTerm(t.term, terms) {
+			first = i
+			break
+		}
+	}
+	//start a few words before the first match so the snippet has some context
+	start := 0
+	if first > 3 {
+		start = tokens[first-3].start
+	}
+	end := len(text)
+	if utf8.RuneCountInString(text[start:]) > size {
+		end = start
+		for i := 0; i < size; i++ {
+			_, n := utf8.DecodeRuneInString(text[end:])
+			end += n
+		}
+		//don't cut the last word
+		for _, t := range tokens {
+			if t.start < end && t.end > end {
+				end = t.start
+				break
+			}
+		}
+	}
+	buf := []string{}
+	if start > 0 {
+		buf = append(buf, ELLIPSIS)
+	}
+	position := start
+	for _, t := range tokens {
+		if t.start < start || t.end > end || !isTerm(t.term, terms) {
+			continue
+		}
+		buf = append(buf, html.EscapeString(text[position:t.start]), HIGHLIGHT_START, html.EscapeString(text[t.start:t.end]), HIGHLIGHT_END)
+		position = t.end
+	}
+	buf = append(buf, html.EscapeString(strings.TrimRightFunc(text[position:end], unicode.IsSpace)))
+	if end < len(text) {
+		buf = append(buf, ELLIPSIS)
+	}
+	return strings.Join(buf, "")
+}
@@ -0,0 +1,60 @@
+package textsearch
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	cases := []struct {
+		query         string
+		expectedTerms []string
+	}{
+		{"Golang", []string{"golang"}},
+		{"the searching of articles", []string{"search", "articl"}},
+		{"docker -kubernetes docker", []string{"docker"}},
+		{"\"go channels\" with-select", []string{"go", "channel", "select"}},
+		{"the and of", []string{}},
+	}
+	for _, c := range cases {
+		if terms := Terms(c.query); fmt.Sprint(terms) != fmt.Sprint(c.expectedTerms) {
+			t.Errorf("Error should %v \t but get %v", c.expectedTerms, terms)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	terms := Terms("golang channels")
+	cases := []struct {
+		text          string
+		weight        float64
+		expectedScore float64
+	}{
+		{"Golang", 3, 3},
+		{"channels in golang", 1, 2.0 / 3},
+		{"docker compose", 1, 0},
+	}
+	for _, c := range cases {
+		if score := Score(c.text, terms, c.weight); score != c.expectedScore {
+			t.Errorf("Error should %v \t but get %v", c.expectedScore, score)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	terms := Terms("channel")
+	cases := []struct {
+		text            string
+		size            int
+		expectedSnippet string
+	}{
+		{"go <channels> are typed", 100, "go &lt;<mark>channels</mark>&gt; are typed"},
+		{"one two three four five six channels are typed conduits", 24, "...four five six <mark>channels</mark>..."},
+		{"no matched word in this text", 12, "no matched..."},
+	}
+	for _, c := range cases {
+		if snippet := Snippet(c.text, terms, c.size); snippet != c.expectedSnippet {
+			t.Errorf("Error should %q \t but get %q", c.expectedSnippet, snippet)
+		}
+	}
+}